@@ -0,0 +1,160 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reBandwidthGroupNotFound = regexp.MustCompile(`(?i)could not find a bandwidth group|bandwidth group .*(not found|could not be found)`)
+	reBandwidthLimit         = regexp.MustCompile(`^(\d+)([kmgKMG]?)$`)
+)
+
+var (
+	// ErrBandwidthGroupExist is returned when the machine already has a
+	// bandwidth group with the same name.
+	ErrBandwidthGroupExist = errors.New("bandwidth group already exists")
+	// ErrBandwidthGroupNotExist is returned when the machine has no bandwidth
+	// group with the given name.
+	ErrBandwidthGroupNotExist = errors.New("bandwidth group does not exist")
+)
+
+// BandwidthType is the kind of the traffic limited by a bandwidth group.
+type BandwidthType string
+
+const (
+	// BandwidthDisk limits the storage attachments.
+	BandwidthDisk = BandwidthType("disk")
+	// BandwidthNetwork limits the NICs.
+	BandwidthNetwork = BandwidthType("network")
+)
+
+// A BandwidthGroup limits the bandwidth of the NICs or the storage
+// attachments of a machine referencing it by its name.
+type BandwidthGroup struct {
+	Name  string
+	Type  BandwidthType
+	Limit uint64 // in bytes per second, 0 stops the traffic
+}
+
+// ParseBandwidthLimit parses the limit in the format of VBoxManage into bytes
+// per second. The limit is in megabytes per second without a unit, the units
+// k, m and g are kilobits, megabits and gigabits, and K, M and G are the
+// binary kilobytes, megabytes and gigabytes, e.g. 10m is 1250000 and 1G is
+// 1073741824.
+func ParseBandwidthLimit(s string) (uint64, error) {
+	res := reBandwidthLimit.FindStringSubmatch(strings.TrimSpace(s))
+	if res == nil {
+		return 0, fmt.Errorf("invalid bandwidth limit %q", s)
+	}
+	n, err := strconv.ParseUint(res[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: %w", s, err)
+	}
+	var mul, div uint64 = 1 << 20, 1
+	switch res[2] {
+	case "k":
+		mul, div = 1000, 8
+	case "m":
+		mul, div = 1000*1000, 8
+	case "g":
+		mul, div = 1000*1000*1000, 8
+	case "K":
+		mul = 1 << 10
+	case "G":
+		mul = 1 << 30
+	}
+	if n > math.MaxUint64/mul {
+		return 0, fmt.Errorf("bandwidth limit %q is too large", s)
+	}
+	return n * mul / div, nil
+}
+
+// formatBandwidthLimit returns the limit in the largest unit of VBoxManage
+// which represents it exactly, or rounded up to kilobytes.
+func formatBandwidthLimit(limit uint64) string {
+	switch {
+	case limit == 0:
+		return "0"
+	case limit%(1<<30) == 0:
+		return fmt.Sprintf("%dG", limit>>30)
+	case limit%(1<<20) == 0:
+		return fmt.Sprintf("%dM", limit>>20)
+	case limit%(1<<10) == 0:
+		return fmt.Sprintf("%dK", limit>>10)
+	case limit*8%1000000 == 0:
+		return fmt.Sprintf("%dm", limit*8/1000000)
+	case limit*8%1000 == 0:
+		return fmt.Sprintf("%dk", limit*8/1000)
+	}
+	return fmt.Sprintf("%dK", (limit+1<<10-1)>>10)
+}
+
+// bandwidthctl runs the bandwidthctl command on the machine and maps the
+// errors of the bandwidth group with the given name.
+func (m *Manager) bandwidthctl(ctx context.Context, vm, name, cmd string, args ...string) (string, error) {
+	args = append([]string{"bandwidthctl", vm, cmd}, args...)
+	stdout, stderr, err := m.runMachine(ctx, vm, args...)
+	if err != nil {
+		switch {
+		case reMachineNotFound.MatchString(stderr):
+			return "", ErrMachineNotExist
+		case strings.Contains(stderr, "already exists"):
+			return "", fmt.Errorf("%s: %w", name, ErrBandwidthGroupExist)
+		case reBandwidthGroupNotFound.MatchString(stderr):
+			return "", fmt.Errorf("%s: %w", name, ErrBandwidthGroupNotExist)
+		}
+		return "", fmt.Errorf("unable to %s bandwidth group: %w", cmd, err)
+	}
+	return stdout, nil
+}
+
+// AddBandwidthGroup adds the bandwidth group to the machine.
+func (m *Manager) AddBandwidthGroup(ctx context.Context, vm string, g BandwidthGroup) error {
+	if g.Name == "" {
+		return fmt.Errorf("bandwidth group name is empty")
+	}
+	m.log.Printf("adding bandwidth group %q to %q", g.Name, vm)
+	_, err := m.bandwidthctl(ctx, vm, g.Name, "add", g.Name,
+		"--type", string(g.Type), "--limit", formatBandwidthLimit(g.Limit))
+	return err
+}
+
+// SetBandwidthLimit changes the limit of the bandwidth group, also while the
+// machine is running.
+func (m *Manager) SetBandwidthLimit(ctx context.Context, vm, name string, limit uint64) error {
+	m.log.Printf("setting limit of bandwidth group %q of %q", name, vm)
+	_, err := m.bandwidthctl(ctx, vm, name, "set", name, "--limit", formatBandwidthLimit(limit))
+	return err
+}
+
+// RemoveBandwidthGroup removes the bandwidth group from the machine, it must
+// not be referenced by any NIC or storage attachment.
+func (m *Manager) RemoveBandwidthGroup(ctx context.Context, vm, name string) error {
+	m.log.Printf("removing bandwidth group %q from %q", name, vm)
+	_, err := m.bandwidthctl(ctx, vm, name, "remove", name)
+	return err
+}
+
+// BandwidthGroups returns the bandwidth groups of the machine keyed by their
+// name.
+func (m *Manager) BandwidthGroups(ctx context.Context, vm string) (map[string]BandwidthGroup, error) {
+	stdout, err := m.bandwidthctl(ctx, vm, "", "list", "--machinereadable")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := parseBandwidthGroups(stdout)
+	if err != nil {
+		return nil, err
+	}
+	gm := make(map[string]BandwidthGroup, len(groups))
+	for _, g := range groups {
+		gm[g.Name] = g
+	}
+	return gm, nil
+}
@@ -0,0 +1,129 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseBandwidthLimit(t *testing.T) {
+	testCases := map[string]uint64{
+		"20":   20 << 20,
+		"10m":  1250000,
+		"1g":   125000000,
+		"512k": 64000,
+		"512K": 512 << 10,
+		"1G":   1 << 30,
+	}
+	for in, want := range testCases {
+		got, err := ParseBandwidthLimit(in)
+		if err != nil || got != want {
+			t.Errorf("ParseBandwidthLimit(%q) = %d, %v; want %d", in, got, err, want)
+		}
+		if back, _ := ParseBandwidthLimit(formatBandwidthLimit(got)); back != got {
+			t.Errorf("formatBandwidthLimit(%d) = %q; does not round-trip", got, formatBandwidthLimit(got))
+		}
+	}
+	for _, in := range []string{"", "10mb", "-1", "1T", "20000000000G", "18446744073709551615k", "17592186044416"} {
+		if _, err := ParseBandwidthLimit(in); err == nil {
+			t.Errorf("ParseBandwidthLimit(%q) succeeded", in)
+		}
+	}
+	if got := formatBandwidthLimit(1001); got != "1K" {
+		t.Errorf("formatBandwidthLimit(1001) = %q; want rounded up to 1K", got)
+	}
+}
+
+func TestParseBandwidthGroups(t *testing.T) {
+	in := "name=\"Disk\"\ntype=\"Disk\"\nmaxbytespersec=20971520\nname=\"Net\"\ntype=\"Network\"\nmaxbytespersec=1250000\n" +
+		"name=\"Off\"\ntype=\"Network\"\nmaxbytespersec=0\n"
+	want := []BandwidthGroup{
+		{Name: "Disk", Type: BandwidthDisk, Limit: 20 << 20},
+		{Name: "Net", Type: BandwidthNetwork, Limit: 1250000},
+		{Name: "Off", Type: BandwidthNetwork},
+	}
+	got, err := parseBandwidthGroups(in)
+	if diff := deep.Equal(got, want); err != nil || diff != nil {
+		t.Errorf("parseBandwidthGroups() = %v, %v; diff = %v", got, err, diff)
+	}
+}
+
+func TestManagerBandwidthGroups(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"bandwidthctl", "vm", "add", "slow", "--type", "network", "--limit", "10m"}},
+		Call{Args: []string{"bandwidthctl", "vm", "add", "slow", "--type", "network", "--limit", "10m"},
+			Stderr:   "VBoxManage: error: Bandwidth group named 'slow' already exists\n",
+			ExitCode: 1},
+		Call{Args: []string{"bandwidthctl", "vm", "set", "slow", "--limit", "1G"}},
+		Call{Args: []string{"bandwidthctl", "vm", "list", "--machinereadable"},
+			Stdout: "name=\"slow\"\ntype=\"Network\"\nmaxbytespersec=1073741824\n"},
+		Call{Args: []string{"bandwidthctl", "vm", "remove", "slow"}},
+		Call{Args: []string{"bandwidthctl", "vm", "remove", "slow"},
+			Stderr:   "VBoxManage: error: Could not find a bandwidth group named 'slow' (VBOX_E_OBJECT_NOT_FOUND)\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	g := BandwidthGroup{Name: "slow", Type: BandwidthNetwork, Limit: 1250000}
+	if err := m.AddBandwidthGroup(ctx, "vm", g); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddBandwidthGroup(ctx, "vm", g); !errors.Is(err, ErrBandwidthGroupExist) {
+		t.Errorf("AddBandwidthGroup() = %v; want %v", err, ErrBandwidthGroupExist)
+	}
+	if err := m.SetBandwidthLimit(ctx, "vm", "slow", 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := m.BandwidthGroups(ctx, "vm")
+	if err != nil || groups["slow"].Limit != 1<<30 {
+		t.Errorf("BandwidthGroups() = %v, %v; want slow at 1G", groups, err)
+	}
+	if err := m.RemoveBandwidthGroup(ctx, "vm", "slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveBandwidthGroup(ctx, "vm", "slow"); !errors.Is(err, ErrBandwidthGroupNotExist) {
+		t.Errorf("RemoveBandwidthGroup() = %v; want %v", err, ErrBandwidthGroupNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerAttachStorage(t *testing.T) {
+	args := []string{"storageattach", "vm", "--storagectl", "SATA", "--port", "1", "--device", "0",
+		"--type", "hdd", "--medium", "disk.vdi", "--bandwidthgroup", "slow"}
+	r := NewReplayer(
+		Call{Args: args},
+		Call{Args: args,
+			Stderr:   "VBoxManage: error: Could not find a bandwidth group named 'slow' (VBOX_E_OBJECT_NOT_FOUND)\n",
+			ExitCode: 1},
+		Call{Args: args,
+			Stderr:   "VBoxManage: error: Could not find a registered machine named 'vm'\n",
+			ExitCode: 1},
+		Call{Args: args,
+			Stderr:   "VBoxManage: error: Could not find file for the medium 'disk.vdi' (VBOX_E_OBJECT_NOT_FOUND)\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	medium := StorageMedium{Port: 1, DriveType: DriveHDD, Medium: "disk.vdi", BandwidthGroup: "slow"}
+	if err := m.AttachStorage(ctx, "vm", "SATA", medium); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AttachStorage(ctx, "vm", "SATA", medium); !errors.Is(err, ErrBandwidthGroupNotExist) {
+		t.Errorf("AttachStorage() = %v; want %v", err, ErrBandwidthGroupNotExist)
+	}
+	if err := m.AttachStorage(ctx, "vm", "SATA", medium); !errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("AttachStorage() = %v; want %v", err, ErrMachineNotExist)
+	}
+	if err := m.AttachStorage(ctx, "vm", "SATA", medium); err == nil || errors.Is(err, ErrBandwidthGroupNotExist) {
+		t.Errorf("AttachStorage() of a missing medium = %v; want another error than %v", err, ErrBandwidthGroupNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
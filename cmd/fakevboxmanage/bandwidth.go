@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reLimit = regexp.MustCompile(`^(\d+)([kmgKMG]?)$`)
+
+// parseLimit parses the --limit of bandwidthctl into bytes per second.
+func parseLimit(s string) (uint64, error) {
+	res := reLimit.FindStringSubmatch(s)
+	if res == nil {
+		return 0, fmt.Errorf("invalid limit '%s': %w", s, errSyntax)
+	}
+	n, _ := strconv.ParseUint(res[1], 10, 64)
+	switch res[2] {
+	case "k":
+		return n * 1000 / 8, nil
+	case "m":
+		return n * 1000000 / 8, nil
+	case "g":
+		return n * 1000000000 / 8, nil
+	case "K":
+		return n << 10, nil
+	case "G":
+		return n << 30, nil
+	}
+	return n << 20, nil
+}
+
+func bandwidthctl(st *state, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("bandwidthctl requires a machine and a subcommand: %w", errSyntax)
+	}
+	m, err := st.machine(args[0])
+	if err != nil {
+		return err
+	}
+	fs, pos, err := flags(args[2:], "--machinereadable")
+	if err != nil {
+		return err
+	}
+	if args[1] == "list" {
+		for _, g := range m.Bandwidth {
+			fmt.Fprintf(out, "name=\"%s\"\n", g.Name)
+			fmt.Fprintf(out, "type=\"%s\"\n", g.Type)
+			fmt.Fprintf(out, "maxbytespersec=%d\n", g.Limit)
+		}
+		return nil
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("bandwidthctl %s requires a group name: %w", args[1], errSyntax)
+	}
+	name := pos[0]
+	idx := -1
+	for i, g := range m.Bandwidth {
+		if g.Name == name {
+			idx = i
+		}
+	}
+	if args[1] != "add" && idx < 0 {
+		return fmt.Errorf("Could not find a bandwidth group named '%s' (VBOX_E_OBJECT_NOT_FOUND)", name)
+	}
+	switch args[1] {
+	case "add":
+		if idx >= 0 {
+			return fmt.Errorf("Bandwidth group named '%s' already exists", name)
+		}
+		var typ string
+		switch strings.ToLower(fs["--type"]) {
+		case "disk":
+			typ = "Disk"
+		case "network":
+			typ = "Network"
+		default:
+			return fmt.Errorf("invalid type '%s': %w", fs["--type"], errSyntax)
+		}
+		limit, err := parseLimit(fs["--limit"])
+		if err != nil {
+			return err
+		}
+		m.Bandwidth = append(m.Bandwidth, &bwgroup{Name: name, Type: typ, Limit: limit})
+	case "set":
+		limit, err := parseLimit(fs["--limit"])
+		if err != nil {
+			return err
+		}
+		m.Bandwidth[idx].Limit = limit
+	case "remove":
+		for _, n := range m.NICs {
+			if n.Bandwidth == name {
+				return fmt.Errorf("The bandwidth group '%s' is still in use", name)
+			}
+		}
+		m.Bandwidth = append(m.Bandwidth[:idx], m.Bandwidth[idx+1:]...)
+	default:
+		return fmt.Errorf("unknown bandwidthctl subcommand '%s': %w", args[1], errSyntax)
+	}
+	return nil
+}
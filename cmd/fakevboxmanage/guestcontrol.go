@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// exitStatus is returned by the guest processes which exit with a non-zero
+// code, VBoxManage exits with the same code without printing an error.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// guestPrograms are the programs of the fake guests.
+var guestPrograms = map[string]func(args, env []string, out io.Writer) error{
+	"/bin/echo": func(args, _ []string, out io.Writer) error {
+		fmt.Fprintln(out, strings.Join(args, " "))
+		return nil
+	},
+	"/bin/true": func(_, _ []string, _ io.Writer) error {
+		return nil
+	},
+	"/bin/false": func(_, _ []string, _ io.Writer) error {
+		return exitStatus(1)
+	},
+	"/usr/bin/env": func(_, env []string, out io.Writer) error {
+		for _, e := range env {
+			fmt.Fprintln(out, e)
+		}
+		return nil
+	},
+}
+
+func guestcontrol(st *state, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("guestcontrol requires a machine and a subcommand: %w", errSyntax)
+	}
+	m, err := st.machine(args[0])
+	if err != nil {
+		return err
+	}
+	if m.State != "running" {
+		return fmt.Errorf("Machine \"%s\" is not running (currently %s)!", m.Name, m.State)
+	}
+	if args[1] == "run" {
+		return guestRun(args[2:], out)
+	}
+	g, err := newGuestFS(m)
+	if err != nil {
+		return err
+	}
+	switch args[1] {
+	case "copyto":
+		return guestCopy(g, true, args[2:])
+	case "copyfrom":
+		return guestCopy(g, false, args[2:])
+	case "mkdir":
+		return guestMkdir(g, args[2:])
+	case "rmdir":
+		return guestRmdir(g, args[2:])
+	case "rm":
+		return guestRm(g, args[2:])
+	case "mv":
+		return guestMv(g, args[2:])
+	case "mktemp":
+		return guestMktemp(g, args[2:], out)
+	case "stat":
+		return guestStat(g, args[2:], out)
+	case "updatega":
+		return updatega(args[2:])
+	default:
+		return fmt.Errorf("unknown guestcontrol subcommand '%s': %w", args[1], errSyntax)
+	}
+}
+
+// guestRun runs one of the guestPrograms, the arguments following "--" start
+// with the name of the program.
+func guestRun(args []string, out io.Writer) error {
+	var argv []string
+	for i, a := range args {
+		if a == "--" {
+			args, argv = args[:i], args[i+1:]
+			break
+		}
+	}
+	env := map[string]string{"HOME": "/home/vagrant", "PATH": "/usr/bin:/bin"}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--putenv" && i+1 < len(args) {
+			if k, v, ok := strings.Cut(args[i+1], "="); ok {
+				env[k] = v
+			} else {
+				delete(env, k)
+			}
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	fs, _, err := flags(rest, "--wait-stdout", "--wait-stderr", "--no-wait-stdout", "--no-wait-stderr")
+	if err != nil {
+		return err
+	}
+	if fs["--username"] == "" {
+		return fmt.Errorf("No user name specified!: %w", errSyntax)
+	}
+	exe := fs["--exe"]
+	if exe == "" && len(argv) > 0 {
+		exe = argv[0]
+	}
+	prog, ok := guestPrograms[exe]
+	if !ok {
+		return fmt.Errorf("The guest execution service is not ready (yet): file not found: %s", exe)
+	}
+	if len(argv) > 0 {
+		argv = argv[1:]
+	}
+	environ := make([]string, 0, len(env))
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+	if fs["--no-wait-stdout"] == "on" {
+		out = io.Discard
+	}
+	return prog(argv, environ, out)
+}
+
+// updatega only checks the source ISO, the Guest Additions are always up to
+// date.
+func updatega(args []string) error {
+	for i, a := range args {
+		if a == "--" {
+			args = args[:i]
+			break
+		}
+	}
+	fs, _, err := flags(args, "--wait-start", "--reboot", "--verbose")
+	if err != nil {
+		return err
+	}
+	if src := fs["--source"]; src != "" {
+		if _, err := os.Stat(src); err != nil {
+			return fmt.Errorf("Source \"%s\" does not exist!", src)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// guestFS is the file system of a fake guest, a directory next to the state.
+type guestFS string
+
+func newGuestFS(m *machine) (guestFS, error) {
+	root := filepath.Join(filepath.Dir(statePath()), "guests", m.UUID)
+	for _, dir := range []string{"tmp", "home/vagrant"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return "", err
+		}
+	}
+	return guestFS(root), nil
+}
+
+// host returns the host path of the absolute guest path.
+func (g guestFS) host(p string) (string, error) {
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("Path '%s' is not absolute: %w", p, errSyntax)
+	}
+	return filepath.Join(string(g), filepath.FromSlash(path.Clean(p))), nil
+}
+
+// guestError returns the error of the guest file operation like VBoxManage.
+func guestError(p string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("File '%s' does not exist", p)
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return fmt.Errorf("%s failed on '%s': %v", pe.Op, p, pe.Err)
+	}
+	return err
+}
+
+// guestFlags parses the flags of the guestcontrol subcommands, which all need
+// a user name.
+func guestFlags(args []string, switches ...string) (map[string]string, []string, error) {
+	fs, pos, err := flags(args, switches...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fs["--username"] == "" {
+		return nil, nil, fmt.Errorf("No user name specified!: %w", errSyntax)
+	}
+	return fs, pos, nil
+}
+
+func guestCopy(g guestFS, to bool, args []string) error {
+	fs, srcs, err := guestFlags(args, "--recursive", "--follow", "--dryrun")
+	if err != nil {
+		return err
+	}
+	target := fs["--target-directory"]
+	if target == "" || len(srcs) == 0 {
+		return fmt.Errorf("No source(s) or target directory specified!: %w", errSyntax)
+	}
+	// The sources are on the host and the target in the guest for copyto,
+	// and the other way around for copyfrom.
+	src, dst := func(p string) (string, error) { return p, nil }, g.host
+	if !to {
+		src, dst = g.host, func(p string) (string, error) { return p, nil }
+	}
+	dir, err := dst(target)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return guestError(target, err)
+	}
+	for _, s := range srcs {
+		from, err := src(s)
+		if err != nil {
+			return err
+		}
+		err = copyTree(from, filepath.Join(dir, path.Base(filepath.ToSlash(s))), fs["--recursive"] == "on", fs["--follow"] == "on")
+		if err != nil {
+			return guestError(s, err)
+		}
+	}
+	return nil
+}
+
+// copyTree copies the file or, when recursive, the directory. The symbolic
+// links are skipped unless they are followed.
+func copyTree(from, to string, recursive, follow bool) error {
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+	fi, err := stat(from)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fi.Mode()&fs.ModeSymlink != 0:
+		return nil
+	case fi.IsDir():
+		if !recursive {
+			return fmt.Errorf("'%s' is a directory, use --recursive", from)
+		}
+		if err := os.MkdirAll(to, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(from)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyTree(filepath.Join(from, e.Name()), filepath.Join(to, e.Name()), recursive, follow); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(from) // #nosec
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(to, data, fi.Mode().Perm())
+}
+
+func guestMkdir(g guestFS, args []string) error {
+	fs, dirs, err := guestFlags(args, "--parents")
+	if err != nil {
+		return err
+	}
+	mode, err := guestMode(fs["--mode"], 0o755)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		p, err := g.host(d)
+		if err != nil {
+			return err
+		}
+		if fs["--parents"] == "on" {
+			err = os.MkdirAll(p, mode)
+		} else {
+			err = os.Mkdir(p, mode)
+		}
+		if err != nil {
+			return guestError(d, err)
+		}
+	}
+	return nil
+}
+
+// guestMode parses the octal --mode, or returns the default when it is empty.
+func guestMode(s string, def fs.FileMode) (fs.FileMode, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid mode '%s': %w", s, errSyntax)
+	}
+	return fs.FileMode(n).Perm(), nil
+}
+
+func guestRmdir(g guestFS, args []string) error {
+	fs, dirs, err := guestFlags(args, "--recursive")
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		p, err := g.host(d)
+		if err != nil {
+			return err
+		}
+		if fi, err := os.Stat(p); err != nil {
+			return guestError(d, err)
+		} else if !fi.IsDir() {
+			return fmt.Errorf("'%s' is not a directory", d)
+		}
+		if fs["--recursive"] == "on" {
+			err = os.RemoveAll(p)
+		} else {
+			err = os.Remove(p)
+		}
+		if err != nil {
+			return guestError(d, err)
+		}
+	}
+	return nil
+}
+
+func guestRm(g guestFS, args []string) error {
+	fs, files, err := guestFlags(args, "--force")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		p, err := g.host(f)
+		if err != nil {
+			return err
+		}
+		if fi, err := os.Lstat(p); err == nil && fi.IsDir() {
+			return fmt.Errorf("'%s' is a directory", f)
+		}
+		if err := os.Remove(p); err != nil && !(fs["--force"] == "on" && errors.Is(err, os.ErrNotExist)) {
+			return guestError(f, err)
+		}
+	}
+	return nil
+}
+
+func guestMv(g guestFS, args []string) error {
+	_, pos, err := guestFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 2 {
+		return fmt.Errorf("mv requires a source and a destination: %w", errSyntax)
+	}
+	srcs, dst := pos[:len(pos)-1], pos[len(pos)-1]
+	to, err := g.host(dst)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(to)
+	isDir := err == nil && fi.IsDir()
+	if len(srcs) > 1 && !isDir {
+		return fmt.Errorf("Destination '%s' must be a directory", dst)
+	}
+	for _, s := range srcs {
+		from, err := g.host(s)
+		if err != nil {
+			return err
+		}
+		target := to
+		if isDir {
+			target = filepath.Join(to, path.Base(s))
+		}
+		if err := os.Rename(from, target); err != nil {
+			return guestError(s, err)
+		}
+	}
+	return nil
+}
+
+func guestMktemp(g guestFS, args []string, out io.Writer) error {
+	opts, pos, err := guestFlags(args, "--directory", "--secure")
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || !strings.Contains(pos[0], "XXX") {
+		return fmt.Errorf("mktemp requires a template with at least 3 X: %w", errSyntax)
+	}
+	def := fs.FileMode(0o700)
+	if opts["--secure"] != "on" {
+		def = 0o755
+	}
+	mode, err := guestMode(opts["--mode"], def)
+	if err != nil {
+		return err
+	}
+	dir := opts["--tmpdir"]
+	if dir == "" {
+		dir = "/tmp"
+	}
+	// The last run of X is replaced with random characters.
+	name := []byte(pos[0])
+	end := strings.LastIndex(pos[0], "XXX") + 3
+	for end < len(name) && name[end] == 'X' {
+		end++
+	}
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec
+	for j := end - 1; j >= 0 && pos[0][j] == 'X'; j-- {
+		name[j] = letters[rnd.Intn(len(letters))]
+	}
+	p := path.Join(dir, string(name))
+	hp, err := g.host(p)
+	if err != nil {
+		return err
+	}
+	if opts["--directory"] == "on" {
+		if err := os.Mkdir(hp, mode); err != nil {
+			return guestError(p, err)
+		}
+		fmt.Fprintf(out, "Directory name: %s\n", p)
+		return nil
+	}
+	if err := os.WriteFile(hp, nil, mode); err != nil {
+		return guestError(p, err)
+	}
+	fmt.Fprintf(out, "File name: %s\n", p)
+	return nil
+}
+
+func guestStat(g guestFS, args []string, out io.Writer) error {
+	_, files, err := guestFlags(args)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		p, err := g.host(f)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return guestError(f, err)
+		}
+		typ := "file"
+		switch {
+		case fi.IsDir():
+			typ = "directory"
+		case fi.Mode()&fs.ModeSymlink != 0:
+			typ = "symlink"
+		}
+		mod := fi.ModTime().UTC().Format(time.RFC3339Nano)
+		fmt.Fprintf(out, "  File: '%s'\n", f)
+		fmt.Fprintf(out, "  Size: %-17d Alloc: %-19d Type: %s\n", fi.Size(), (fi.Size()+4095)/4096*4096, typ)
+		fmt.Fprintf(out, "Device: %#-17x INode: %-18d Links: %d\n", 0x803, 1, 1)
+		fmt.Fprintf(out, "  Mode: %-16s Attrib: %s\n", fi.Mode().String(), "")
+		fmt.Fprintf(out, " Owner: %4d/%-12s Group: %4d/%s\n", 1000, "vagrant", 1000, "vagrant")
+		for _, k := range []string{"Access", "Modify", "Change", " Birth"} {
+			fmt.Fprintf(out, "%s: %s\n", k, mod)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reIndexedFlag = regexp.MustCompile(`^--([a-z-]+?)(\d)$`)
+	reNATPF       = regexp.MustCompile(`^natpf(\d)$`)
+	reNICControl  = regexp.MustCompile(`^(nic|setlinkstate|nicpromisc|nicproperty|nictrace|nictracefile)(\d)$`)
+)
+
+func showvminfo(st *state, args []string, out io.Writer) error {
+	_, pos, err := flags(args, "--machinereadable")
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("showvminfo requires a machine: %w", errSyntax)
+	}
+	m, err := st.machine(pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "name=\"%s\"\n", m.Name)
+	fmt.Fprintf(out, "groups=\"/\"\n")
+	fmt.Fprintf(out, "ostype=\"%s\"\n", m.OSType)
+	fmt.Fprintf(out, "UUID=\"%s\"\n", m.UUID)
+	fmt.Fprintf(out, "CfgFile=\"%s\"\n", m.CfgFile)
+	fmt.Fprintf(out, "memory=%d\n", m.Memory)
+	fmt.Fprintf(out, "vram=%d\n", m.VRAM)
+	fmt.Fprintf(out, "firmware=\"%s\"\n", m.Firmware)
+	fmt.Fprintf(out, "cpus=%d\n", m.CPUs)
+	for i, b := range m.Boot {
+		if b == "" {
+			b = "none"
+		}
+		fmt.Fprintf(out, "boot%d=\"%s\"\n", i+1, b)
+	}
+	fmt.Fprintf(out, "VMState=\"%s\"\n", m.State)
+	for i, n := range m.NICs {
+		slot := i + 1
+		if n.Type == "" || n.Type == "none" {
+			fmt.Fprintf(out, "nic%d=\"none\"\n", slot)
+			continue
+		}
+		switch n.Type {
+		case "hostonly":
+			fmt.Fprintf(out, "hostonlyadapter%d=\"%s\"\n", slot, n.Hostonly)
+		case "hostonlynet":
+			fmt.Fprintf(out, "hostonly-network%d=\"%s\"\n", slot, n.HostNet)
+		case "natnetwork":
+			fmt.Fprintf(out, "nat-network%d=\"%s\"\n", slot, n.NATNet)
+		case "bridged":
+			fmt.Fprintf(out, "bridgeadapter%d=\"%s\"\n", slot, n.Bridge)
+		case "intnet":
+			fmt.Fprintf(out, "intnet%d=\"%s\"\n", slot, n.Intnet)
+		case "nat":
+			fmt.Fprintf(out, "natnet%d=\"nat\"\n", slot)
+		}
+		fmt.Fprintf(out, "macaddress%d=\"%s\"\n", slot, n.MAC)
+		fmt.Fprintf(out, "cableconnected%d=\"%s\"\n", slot, n.Cable)
+		if n.Type == "hostonlynet" {
+			fmt.Fprintf(out, "nic%d=\"hostonlynetwork\"\n", slot)
+		} else {
+			fmt.Fprintf(out, "nic%d=\"%s\"\n", slot, n.Type)
+		}
+		fmt.Fprintf(out, "nictype%d=\"%s\"\n", slot, n.Hardware)
+		fmt.Fprintf(out, "nicspeed%d=\"0\"\n", slot)
+		for j, f := range n.Forwarding {
+			fmt.Fprintf(out, "Forwarding(%d)=\"%s\"\n", j, f)
+		}
+	}
+	// The Guest Additions of the running machines are up to the userland.
+	if m.State == "running" {
+		fmt.Fprintf(out, "GuestAdditionsRunLevel=2\n")
+		fmt.Fprintf(out, "GuestAdditionsVersion=\"%s\"\n", strings.Replace(defaultVersion, "r", " r", 1))
+		fmt.Fprintf(out, "GuestAdditionsFacility_VirtualBox Base Driver=50,%d\n", m.Started)
+		fmt.Fprintf(out, "GuestAdditionsFacility_VirtualBox System Service=50,%d\n", m.Started)
+	} else {
+		fmt.Fprintf(out, "GuestAdditionsRunLevel=0\n")
+	}
+	return nil
+}
+
+func createvm(st *state, args []string, out io.Writer) error {
+	fs, _, err := flags(args, "--register")
+	if err != nil {
+		return err
+	}
+	name := fs["--name"]
+	if name == "" {
+		return fmt.Errorf("createvm requires --name: %w", errSyntax)
+	}
+	folder := fs["--basefolder"]
+	if folder == "" {
+		folder = "VirtualBox VMs"
+	}
+	cfg := filepath.Join(folder, name, name+".vbox")
+	for _, m := range st.Machines {
+		if m.Name == name || (fs["--uuid"] != "" && m.UUID == fs["--uuid"]) {
+			return fmt.Errorf("Machine settings file '%s' already exists", cfg)
+		}
+	}
+	uuid := fs["--uuid"]
+	if uuid == "" {
+		uuid = fmt.Sprintf("00000000-0000-4000-8000-%012x", st.next())
+	}
+	m := &machine{
+		Name:       name,
+		UUID:       uuid,
+		OSType:     fs["--ostype"],
+		Firmware:   "BIOS",
+		State:      "poweroff",
+		CfgFile:    cfg,
+		CPUs:       1,
+		Memory:     128,
+		VRAM:       8,
+		Properties: map[string]*property{},
+	}
+	st.Machines = append(st.Machines, m)
+	fmt.Fprintf(out, "Virtual machine '%s' is created and registered.\n", name)
+	fmt.Fprintf(out, "UUID: %s\n", uuid)
+	fmt.Fprintf(out, "Settings file: '%s'\n", cfg)
+	return nil
+}
+
+func modifyvm(st *state, args []string, _ io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("modifyvm requires a machine: %w", errSyntax)
+	}
+	m, err := st.machine(args[0])
+	if err != nil {
+		return err
+	}
+	if m.State == "running" || m.State == "paused" {
+		return fmt.Errorf("The machine '%s' is already locked for a session (or being unlocked)", m.Name)
+	}
+	fs, pos, err := flags(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		return fmt.Errorf("unexpected arguments %q: %w", pos, errSyntax)
+	}
+	for k, v := range fs {
+		if err := m.set(st, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set applies a single modifyvm flag, both the legacy and the hyphenated
+// spelling is accepted. Unknown flags are ignored.
+func (m *machine) set(st *state, flag, val string) error {
+	atoi := func() (int, error) {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value '%s' for %s: %w", val, flag, errSyntax)
+		}
+		return n, nil
+	}
+	var err error
+	switch flag {
+	case "--name":
+		m.Name = val
+	case "--ostype", "--os-type":
+		m.OSType = val
+	case "--firmware":
+		m.Firmware = val
+	case "--cpus":
+		m.CPUs, err = atoi()
+	case "--memory":
+		m.Memory, err = atoi()
+	case "--vram":
+		m.VRAM, err = atoi()
+	}
+	if err != nil {
+		return err
+	}
+
+	res := reIndexedFlag.FindStringSubmatch(flag)
+	if res == nil {
+		return nil
+	}
+	name := strings.ReplaceAll(res[1], "-", "")
+	n, _ := strconv.Atoi(res[2])
+	if name == "boot" {
+		if n < 1 || n > 4 {
+			return fmt.Errorf("invalid boot slot %d: %w", n, errSyntax)
+		}
+		m.Boot[n-1] = val
+		return nil
+	}
+	if n < 1 || n > len(m.NICs) {
+		return nil
+	}
+	nic := &m.NICs[n-1]
+	switch name {
+	case "nic":
+		nic.Type = val
+		if nic.MAC == "" {
+			nic.MAC = fmt.Sprintf("080027%06X", st.next())
+		}
+		if nic.Hardware == "" {
+			nic.Hardware = "82540EM"
+		}
+		if nic.Cable == "" {
+			nic.Cable = "on"
+		}
+	case "nictype":
+		nic.Hardware = val
+	case "cableconnected":
+		nic.Cable = val
+	case "macaddress":
+		nic.MAC = strings.ToUpper(val)
+	case "hostonlyadapter":
+		if _, err := st.hostonly(val); err != nil {
+			return err
+		}
+		nic.Hostonly = val
+	case "bridgeadapter":
+		nic.Bridge = val
+	case "hostonlynet":
+		if _, err := st.hostnet(val); err != nil {
+			return err
+		}
+		nic.HostNet = val
+	case "natnetwork":
+		if _, err := st.natnet(val); err != nil {
+			return err
+		}
+		nic.NATNet = val
+	case "natpf":
+		nic.Forwarding = append(nic.Forwarding, val)
+	case "intnet":
+		nic.Intnet = val
+	case "nicbandwidthgroup":
+		if val != "none" && m.bwgroup(val) == nil {
+			return fmt.Errorf("Could not find a bandwidth group named '%s' (VBOX_E_OBJECT_NOT_FOUND)", val)
+		}
+		nic.Bandwidth = val
+	case "nicpromisc":
+		nic.Promisc = val
+	case "nicproperty":
+		k, v, ok := strings.Cut(val, "=")
+		if !ok {
+			return fmt.Errorf("invalid NIC property '%s': %w", val, errSyntax)
+		}
+		if nic.Properties == nil {
+			nic.Properties = map[string]string{}
+		}
+		nic.Properties[k] = v
+	case "nictrace":
+		nic.Trace = val
+		if val == "on" && nic.TraceFile != "" {
+			return writeTrace(nic)
+		}
+	case "nictracefile":
+		nic.TraceFile = val
+	}
+	return nil
+}
+
+// bwgroup returns the bandwidth group with the given name, or nil.
+func (m *machine) bwgroup(name string) *bwgroup {
+	for _, g := range m.Bandwidth {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+func startvm(st *state, args []string, out io.Writer) error {
+	_, pos, err := flags(args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("startvm requires a machine: %w", errSyntax)
+	}
+	m, err := st.machine(pos[0])
+	if err != nil {
+		return err
+	}
+	if m.State == "running" || m.State == "paused" {
+		return fmt.Errorf("The machine '%s' is already locked by a session (or being locked or unlocked)", m.Name)
+	}
+	m.State = "running"
+	m.Started = time.Now().UnixMilli()
+	m.guestInfo()
+	fmt.Fprintf(out, "Waiting for VM \"%s\" to power on...\n", m.Name)
+	fmt.Fprintf(out, "VM \"%s\" has been successfully started.\n", m.Name)
+	return nil
+}
+
+// guestInfo sets the guest properties of the network interfaces reported by
+// the Guest Additions, the NAT NICs get 10.0.2.15 and the other ones an address
+// of 192.168.56.0/24.
+func (m *machine) guestInfo() {
+	count := 0
+	for i, n := range m.NICs {
+		if n.Type == "" || n.Type == "none" || n.Type == "null" {
+			continue
+		}
+		ip := fmt.Sprintf("192.168.56.%d", 100+i+1)
+		if n.Type == "nat" {
+			ip = "10.0.2.15"
+		}
+		status := "Up"
+		if n.Cable == "off" {
+			status = "Down"
+		}
+		prefix := fmt.Sprintf("/VirtualBox/GuestInfo/Net/%d/", count)
+		m.setProperty(prefix+"MAC", n.MAC, "TRANSIENT, RDONLYGUEST")
+		m.setProperty(prefix+"V4/IP", ip, "TRANSIENT, RDONLYGUEST")
+		m.setProperty(prefix+"V4/Netmask", "255.255.255.0", "TRANSIENT, RDONLYGUEST")
+		m.setProperty(prefix+"Status", status, "TRANSIENT, RDONLYGUEST")
+		count++
+	}
+	m.setProperty("/VirtualBox/GuestInfo/Net/Count", strconv.Itoa(count), "TRANSIENT, RDONLYGUEST")
+}
+
+// setProperty sets the guest property with the current time.
+func (m *machine) setProperty(name, value, flags string) {
+	if m.Properties == nil {
+		m.Properties = map[string]*property{}
+	}
+	m.Properties[name] = &property{Value: value, Flags: flags, Timestamp: time.Now().UnixNano()}
+}
+
+// propertyFlags are the valid flags of the guest properties.
+var propertyFlags = map[string]bool{
+	"TRANSIENT": true, "TRANSRESET": true, "RDONLYGUEST": true, "RDONLYHOST": true, "READONLY": true,
+}
+
+// rePropertyFlags splits the flags, which are separated by commas or spaces.
+var rePropertyFlags = regexp.MustCompile(`[\s,]+`)
+
+// normalizeFlags returns the flags like VBoxManage prints them.
+func normalizeFlags(s string) (string, error) {
+	var flags []string
+	for _, f := range rePropertyFlags.Split(strings.TrimSpace(s), -1) {
+		if f == "" {
+			continue
+		}
+		f = strings.ToUpper(f)
+		if !propertyFlags[f] {
+			return "", fmt.Errorf("Invalid flag '%s': %w", f, errSyntax)
+		}
+		flags = append(flags, f)
+	}
+	return strings.Join(flags, ", "), nil
+}
+
+// matchPatterns reports whether the name matches any of the patterns, which
+// may use the * and ? wildcards and be separated by |. All names match when
+// there are no patterns.
+func matchPatterns(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		for _, alt := range strings.Split(p, "|") {
+			re := "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(alt)) + "$"
+			if ok, _ := regexp.MatchString(re, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func controlvm(st *state, args []string, _ io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("controlvm requires a machine and an action: %w", errSyntax)
+	}
+	m, err := st.machine(args[0])
+	if err != nil {
+		return err
+	}
+	if m.State != "running" && m.State != "paused" {
+		return fmt.Errorf("Machine '%s' is not currently running", m.Name)
+	}
+	action := args[1]
+	switch action {
+	case "pause":
+		m.State = "paused"
+	case "resume":
+		m.State = "running"
+	case "savestate":
+		m.State = "saved"
+	case "acpipowerbutton", "poweroff":
+		m.State = "poweroff"
+	case "reset":
+		m.State = "running"
+	default:
+		if res := reNICControl.FindStringSubmatch(action); res != nil {
+			return m.control(st, res[1], res[2], args[2:])
+		}
+		res := reNATPF.FindStringSubmatch(action)
+		if res == nil {
+			return fmt.Errorf("Invalid parameter '%s': %w", action, errSyntax)
+		}
+		n, _ := strconv.Atoi(res[1])
+		if n < 1 || n > len(m.NICs) || len(args) < 3 {
+			return fmt.Errorf("invalid natpf arguments: %w", errSyntax)
+		}
+		nic := &m.NICs[n-1]
+		if args[2] == "delete" {
+			if len(args) != 4 {
+				return fmt.Errorf("natpf delete requires a rule name: %w", errSyntax)
+			}
+			for i, f := range nic.Forwarding {
+				if strings.HasPrefix(f, args[3]+",") {
+					nic.Forwarding = append(nic.Forwarding[:i], nic.Forwarding[i+1:]...)
+					return nil
+				}
+			}
+			return fmt.Errorf("Port forwarding rule '%s' not found", args[3])
+		}
+		nic.Forwarding = append(nic.Forwarding, args[2])
+	}
+	return nil
+}
+
+// nicAdapterFlags are the modifyvm flags naming the network of a NIC attached
+// with 'controlvm nic<N>'.
+var nicAdapterFlags = map[string]string{
+	"hostonly":    "hostonlyadapter",
+	"hostonlynet": "hostonlynet",
+	"natnetwork":  "natnetwork",
+	"bridged":     "bridgeadapter",
+	"intnet":      "intnet",
+}
+
+// control applies the controlvm NIC action to the slot by translating it to
+// the modifyvm flags.
+func (m *machine) control(st *state, action, slot string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%s%s requires a value: %w", action, slot, errSyntax)
+	}
+	switch action {
+	case "nic":
+		if err := m.set(st, "--nic"+slot, args[0]); err != nil {
+			return err
+		}
+		if flag, ok := nicAdapterFlags[args[0]]; ok && len(args) > 1 {
+			return m.set(st, "--"+flag+slot, args[1])
+		}
+		return nil
+	case "setlinkstate":
+		if err := m.set(st, "--cableconnected"+slot, args[0]); err != nil {
+			return err
+		}
+		m.guestInfo()
+		return nil
+	}
+	return m.set(st, "--"+action+slot, args[0])
+}
+
+func unregistervm(st *state, args []string, _ io.Writer) error {
+	_, pos, err := flags(args, "--delete", "--delete-all")
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("unregistervm requires a machine: %w", errSyntax)
+	}
+	m, err := st.machine(pos[0])
+	if err != nil {
+		return err
+	}
+	if m.State == "running" || m.State == "paused" {
+		return fmt.Errorf("Cannot unregister the machine '%s' while it is locked", m.Name)
+	}
+	for i, mm := range st.Machines {
+		if mm == m {
+			st.Machines = append(st.Machines[:i], st.Machines[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func guestproperty(st *state, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("guestproperty requires a subcommand and a machine: %w", errSyntax)
+	}
+	m, err := st.machine(args[1])
+	if err != nil {
+		return err
+	}
+	rest := args[2:]
+	switch args[0] {
+	case "get":
+		if len(rest) < 1 {
+			return fmt.Errorf("guestproperty get requires a property: %w", errSyntax)
+		}
+		if p, ok := m.Properties[rest[0]]; ok {
+			fmt.Fprintf(out, "Value: %s\n", p.Value)
+		} else {
+			fmt.Fprintln(out, "No value set!")
+		}
+	case "set":
+		fs, pos, err := flags(rest)
+		if err != nil {
+			return err
+		}
+		if len(pos) < 1 {
+			return fmt.Errorf("guestproperty set requires a property: %w", errSyntax)
+		}
+		if len(pos) < 2 {
+			delete(m.Properties, pos[0])
+			return nil
+		}
+		f, err := normalizeFlags(fs["--flags"])
+		if err != nil {
+			return err
+		}
+		m.setProperty(pos[0], pos[1], f)
+	case "delete", "unset":
+		if len(rest) < 1 {
+			return fmt.Errorf("guestproperty delete requires a property: %w", errSyntax)
+		}
+		delete(m.Properties, rest[0])
+	case "enumerate":
+		fs, patterns, err := flags(rest, "--old-format", "--relative", "--no-timestamp", "--no-flags")
+		if err != nil {
+			return err
+		}
+		if p := fs["--patterns"]; p != "" {
+			patterns = append(patterns, p)
+		}
+		names := make([]string, 0, len(m.Properties))
+		for k := range m.Properties {
+			if matchPatterns(k, patterns) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			p := m.Properties[k]
+			if fs["--old-format"] == "on" {
+				fmt.Fprintf(out, "Name: %s, value: %s, timestamp: %d, flags: %s\n", k, p.Value, p.Timestamp, p.Flags)
+				continue
+			}
+			fmt.Fprintf(out, "%s = '%s' @ %s", k, p.Value, time.Unix(0, p.Timestamp).UTC().Format("2006-01-02T15:04:05.000000000Z"))
+			if p.Flags != "" {
+				fmt.Fprintf(out, " [%s]", p.Flags)
+			}
+			fmt.Fprintln(out)
+		}
+	default:
+		return fmt.Errorf("unknown guestproperty subcommand '%s': %w", args[0], errSyntax)
+	}
+	return nil
+}
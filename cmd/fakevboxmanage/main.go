@@ -0,0 +1,167 @@
+// Command fakevboxmanage is a fake VBoxManage for integration tests. It speaks
+// the VBoxManage command-line grammar for the commands used by go-virtualbox
+// and keeps its state in a JSON file instead of running any machine.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultVersion is reported by --version unless the state sets one.
+const defaultVersion = "7.0.10r158379"
+
+// errSyntax is returned for the invalid command lines, VBoxManage exits with 2
+// in such case.
+var errSyntax = errors.New("syntax error")
+
+type handler func(st *state, args []string, out io.Writer) error
+
+var commands = map[string]handler{
+	"list":          list,
+	"showvminfo":    showvminfo,
+	"createvm":      createvm,
+	"modifyvm":      modifyvm,
+	"startvm":       startvm,
+	"controlvm":     controlvm,
+	"unregistervm":  unregistervm,
+	"guestproperty": guestproperty,
+	"hostonlyif":    hostonlyif,
+	"hostonlynet":   hostonlynet,
+	"natnetwork":    natnetwork,
+	"dhcpserver":    dhcpserver,
+	"bandwidthctl":  bandwidthctl,
+	"guestcontrol":  guestcontrol,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "Usage: fakevboxmanage <command> [<args>]")
+		return 2
+	}
+
+	name := statePath()
+	lf, err := os.OpenFile(name+".lock", os.O_CREATE|os.O_RDWR, 0o600) // #nosec
+	if err != nil {
+		fmt.Fprintf(stderr, "VBoxManage: error: %v\n", err)
+		return 1
+	}
+	defer lf.Close()
+	if err := lock(lf); err != nil {
+		fmt.Fprintf(stderr, "VBoxManage: error: %v\n", err)
+		return 1
+	}
+
+	st, err := load(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "VBoxManage: error: %v\n", err)
+		return 1
+	}
+
+	if args[0] == "--version" || args[0] == "-v" {
+		v := st.Version
+		if v == "" {
+			v = defaultVersion
+		}
+		fmt.Fprintln(stdout, v)
+		return 0
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "VBoxManage: error: Invalid command '%s'\n", args[0])
+		return 2
+	}
+	if err := cmd(st, args[1:], stdout); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			return int(status)
+		}
+		fmt.Fprintf(stderr, "VBoxManage: error: %v\n", err)
+		if errors.Is(err, errSyntax) {
+			return 2
+		}
+		return 1
+	}
+	if err := st.save(name); err != nil {
+		fmt.Fprintf(stderr, "VBoxManage: error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// flags parses the "--name value" pairs of the arguments. The flags without
+// a value are set to "on" when they are in the switches.
+func flags(args []string, switches ...string) (map[string]string, []string, error) {
+	isSwitch := map[string]bool{}
+	for _, s := range switches {
+		isSwitch[s] = true
+	}
+	fs := map[string]string{}
+	var pos []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "--") {
+			pos = append(pos, a)
+			continue
+		}
+		if k, v, ok := strings.Cut(a, "="); ok {
+			fs[k] = v
+			continue
+		}
+		if isSwitch[a] {
+			fs[a] = "on"
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("missing value for %s: %w", a, errSyntax)
+		}
+		fs[a] = args[i+1]
+		i++
+	}
+	return fs, pos, nil
+}
+
+func list(st *state, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("list requires exactly one subcommand: %w", errSyntax)
+	}
+	switch args[0] {
+	case "vms":
+		for _, m := range st.Machines {
+			fmt.Fprintf(out, "\"%s\" {%s}\n", m.Name, m.UUID)
+		}
+	case "runningvms":
+		for _, m := range st.Machines {
+			if m.State == "running" || m.State == "paused" {
+				fmt.Fprintf(out, "\"%s\" {%s}\n", m.Name, m.UUID)
+			}
+		}
+	case "hostonlyifs":
+		for _, h := range st.Hostonly {
+			printHostonly(out, h)
+		}
+	case "hostonlynets":
+		for _, h := range st.HostNets {
+			printHostnet(out, h)
+		}
+	case "natnets":
+		for _, n := range st.NATNets {
+			printNATNet(out, n)
+		}
+	case "dhcpservers":
+		for _, d := range st.DHCP {
+			printDHCP(out, d)
+		}
+	default:
+		return fmt.Errorf("unknown list subcommand '%s': %w", args[0], errSyntax)
+	}
+	return nil
+}
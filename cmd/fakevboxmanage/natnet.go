@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+func printNATNet(out io.Writer, n *natnet) {
+	yesNo := func(b bool) string {
+		if b {
+			return "Yes"
+		}
+		return "No"
+	}
+	// The gateway is the first address of the network.
+	var gateway net.IP
+	if _, ipnet, err := net.ParseCIDR(n.Network); err == nil {
+		gateway = append(net.IP(nil), ipnet.IP...)
+		gateway[len(gateway)-1]++
+	}
+	fmt.Fprintf(out, "Name:         %s\n", n.Name)
+	fmt.Fprintf(out, "Network:      %s\n", n.Network)
+	fmt.Fprintf(out, "Gateway:      %s\n", gateway)
+	fmt.Fprintf(out, "DHCP Server:  %s\n", yesNo(n.DHCP))
+	fmt.Fprintf(out, "IPv6:         %s\n", yesNo(n.IPv6))
+	fmt.Fprintf(out, "IPv6 Prefix:  %s\n", n.IPv6Prefix)
+	fmt.Fprintf(out, "IPv6 Default: %s\n", yesNo(n.IPv6Default))
+	fmt.Fprintf(out, "Enabled:      %s\n", yesNo(n.Enabled))
+	for _, s := range []struct {
+		heading string
+		rules   []string
+	}{
+		{"Port-forwarding (ipv4)", n.PortForward4},
+		{"Port-forwarding (ipv6)", n.PortForward6},
+	} {
+		if len(s.rules) == 0 {
+			continue
+		}
+		fmt.Fprintln(out, s.heading)
+		for _, r := range s.rules {
+			fmt.Fprintf(out, "        %s\n", r)
+		}
+	}
+	for _, s := range []struct {
+		heading  string
+		mappings map[string]string
+	}{
+		{"loopback mappings (ipv4)", n.Loopback4},
+		{"loopback mappings (ipv6)", n.Loopback6},
+	} {
+		if len(s.mappings) == 0 {
+			continue
+		}
+		fmt.Fprintln(out, s.heading)
+		ips := make([]string, 0, len(s.mappings))
+		for ip := range s.mappings {
+			ips = append(ips, ip)
+		}
+		sort.Strings(ips)
+		for _, ip := range ips {
+			fmt.Fprintf(out, "        %s=%s\n", ip, s.mappings[ip])
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+func natnetwork(st *state, args []string, _ io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("natnetwork requires a subcommand: %w", errSyntax)
+	}
+	// The rules and mappings can be given multiple times, so they are not
+	// parsed by flags.
+	var rest, pf4, pf6, lb4, lb6 []string
+	for i := 1; i < len(args); i++ {
+		var list *[]string
+		switch args[i] {
+		case "--port-forward-4":
+			list = &pf4
+		case "--port-forward-6":
+			list = &pf6
+		case "--loopback-4":
+			list = &lb4
+		case "--loopback-6":
+			list = &lb6
+		default:
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s: %w", args[i], errSyntax)
+		}
+		i++
+		if args[i] == "delete" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing rule name to delete: %w", errSyntax)
+			}
+			i++
+			*list = append(*list, "delete "+args[i])
+			continue
+		}
+		*list = append(*list, args[i])
+	}
+	fs, _, err := flags(rest, "--enable", "--disable")
+	if err != nil {
+		return err
+	}
+	name := fs["--netname"]
+	if name == "" {
+		return fmt.Errorf("natnetwork requires --netname: %w", errSyntax)
+	}
+	n, err := st.natnet(name)
+
+	switch args[0] {
+	case "add":
+		if n != nil {
+			return fmt.Errorf("NAT network '%s' already exists", name)
+		}
+		if fs["--network"] == "" {
+			return fmt.Errorf("natnetwork add requires --network: %w", errSyntax)
+		}
+		n = &natnet{Name: name, Enabled: true}
+		st.NATNets = append(st.NATNets, n)
+	case "modify":
+		if err != nil {
+			return err
+		}
+	case "remove":
+		if err != nil {
+			return err
+		}
+		for i, nn := range st.NATNets {
+			if nn == n {
+				st.NATNets = append(st.NATNets[:i], st.NATNets[i+1:]...)
+				break
+			}
+		}
+		return nil
+	case "start", "stop":
+		if err != nil {
+			return err
+		}
+		n.Running = args[0] == "start"
+		return nil
+	default:
+		return fmt.Errorf("unknown natnetwork subcommand '%s': %w", args[0], errSyntax)
+	}
+
+	for k, v := range fs {
+		switch k {
+		case "--network":
+			n.Network = v
+		case "--enable":
+			n.Enabled = true
+		case "--disable":
+			n.Enabled = false
+		case "--dhcp":
+			n.DHCP = v == "on"
+		case "--ipv6":
+			n.IPv6 = v == "on"
+		case "--ipv6-prefix":
+			n.IPv6Prefix = v
+		case "--ipv6-default":
+			n.IPv6Default = v == "on"
+		}
+	}
+	if n.PortForward4, err = forward(n.PortForward4, pf4); err != nil {
+		return err
+	}
+	if n.PortForward6, err = forward(n.PortForward6, pf6); err != nil {
+		return err
+	}
+	if n.Loopback4, err = loopback(n.Loopback4, lb4); err != nil {
+		return err
+	}
+	if n.Loopback6, err = loopback(n.Loopback6, lb6); err != nil {
+		return err
+	}
+	return nil
+}
+
+// forward applies the added and deleted port forwarding rules to the rules.
+func forward(rules, changes []string) ([]string, error) {
+	for _, c := range changes {
+		if strings.HasPrefix(c, "delete ") {
+			name := strings.TrimPrefix(c, "delete ")
+			found := false
+			for i, r := range rules {
+				if strings.HasPrefix(r, name+":") {
+					rules = append(rules[:i], rules[i+1:]...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Port-forwarding rule '%s' does not exist", name)
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(c, ":")
+		for _, r := range rules {
+			if strings.HasPrefix(r, name+":") {
+				return nil, fmt.Errorf("A port-forwarding rule with the name '%s' already exists", name)
+			}
+		}
+		rules = append(rules, c)
+	}
+	return rules, nil
+}
+
+// loopback applies the mappings, the offset 0 removes the mapping.
+func loopback(mappings map[string]string, changes []string) (map[string]string, error) {
+	for _, c := range changes {
+		ip, offset, ok := strings.Cut(c, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid loopback mapping '%s': %w", c, errSyntax)
+		}
+		if offset == "0" {
+			delete(mappings, ip)
+			continue
+		}
+		if mappings == nil {
+			mappings = map[string]string{}
+		}
+		mappings[ip] = offset
+	}
+	return mappings, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func printHostonly(out io.Writer, h *hostonly) {
+	dhcp := "Disabled"
+	if h.DHCP {
+		dhcp = "Enabled"
+	}
+	fmt.Fprintf(out, "Name:            %s\n", h.Name)
+	fmt.Fprintf(out, "GUID:            786f6276-656e-4%03x-8000-0a0027%06x\n", h.Index, h.Index)
+	fmt.Fprintf(out, "DHCP:            %s\n", dhcp)
+	fmt.Fprintf(out, "IPAddress:       %s\n", h.IP)
+	fmt.Fprintf(out, "NetworkMask:     %s\n", h.Netmask)
+	fmt.Fprintf(out, "IPV6Address:     %s\n", h.IPv6)
+	fmt.Fprintf(out, "IPV6NetworkMaskPrefixLength: %d\n", h.IPv6Len)
+	fmt.Fprintf(out, "HardwareAddress: 0a:00:27:00:00:%02x\n", h.Index)
+	fmt.Fprintf(out, "MediumType:      Ethernet\n")
+	fmt.Fprintf(out, "Status:          Down\n")
+	fmt.Fprintf(out, "VBoxNetworkName: HostInterfaceNetworking-%s\n\n", h.Name)
+}
+
+func printHostnet(out io.Writer, h *hostnet) {
+	state := "Disabled"
+	if h.Enabled {
+		state = "Enabled"
+	}
+	fmt.Fprintf(out, "Name:            %s\n", h.Name)
+	fmt.Fprintf(out, "GUID:            %s\n", h.UUID)
+	fmt.Fprintf(out, "State:           %s\n", state)
+	fmt.Fprintf(out, "NetworkMask:     %s\n", h.Netmask)
+	fmt.Fprintf(out, "LowerIP:         %s\n", h.LowerIP)
+	fmt.Fprintf(out, "UpperIP:         %s\n", h.UpperIP)
+	fmt.Fprintf(out, "VBoxNetworkName: hostonly-%s\n\n", h.Name)
+}
+
+func printDHCP(out io.Writer, d *dhcp) {
+	enabled := "No"
+	if d.Enabled {
+		enabled = "Yes"
+	}
+	fmt.Fprintf(out, "NetworkName:    %s\n", d.Network)
+	fmt.Fprintf(out, "IP:             %s\n", d.IP)
+	fmt.Fprintf(out, "NetworkMask:    %s\n", d.Netmask)
+	fmt.Fprintf(out, "lowerIPAddress: %s\n", d.LowerIP)
+	fmt.Fprintf(out, "upperIPAddress: %s\n", d.UpperIP)
+	fmt.Fprintf(out, "Enabled:        %s\n\n", enabled)
+}
+
+func hostonlyif(st *state, args []string, out io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("hostonlyif requires a subcommand: %w", errSyntax)
+	}
+	switch args[0] {
+	case "create":
+		i := 0
+		for ; ; i++ {
+			if _, err := st.hostonly(fmt.Sprintf("vboxnet%d", i)); err != nil {
+				break
+			}
+		}
+		h := &hostonly{
+			Name:    fmt.Sprintf("vboxnet%d", i),
+			IP:      fmt.Sprintf("192.168.%d.1", 56+i),
+			Netmask: "255.255.255.0",
+			Index:   i,
+		}
+		st.Hostonly = append(st.Hostonly, h)
+		fmt.Fprintf(out, "0%%...10%%...20%%...30%%...40%%...50%%...60%%...70%%...80%%...90%%...100%%\n")
+		fmt.Fprintf(out, "Interface '%s' was successfully created\n", h.Name)
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("hostonlyif remove requires an interface: %w", errSyntax)
+		}
+		h, err := st.hostonly(args[1])
+		if err != nil {
+			return err
+		}
+		for i, hh := range st.Hostonly {
+			if hh == h {
+				st.Hostonly = append(st.Hostonly[:i], st.Hostonly[i+1:]...)
+				break
+			}
+		}
+	case "ipconfig":
+		if len(args) < 2 {
+			return fmt.Errorf("hostonlyif ipconfig requires an interface: %w", errSyntax)
+		}
+		h, err := st.hostonly(args[1])
+		if err != nil {
+			return err
+		}
+		fs, _, err := flags(args[2:], "--dhcp")
+		if err != nil {
+			return err
+		}
+		if _, ok := fs["--dhcp"]; ok {
+			h.DHCP = true
+		}
+		if ip, ok := fs["--ip"]; ok {
+			h.IP, h.DHCP = ip, false
+			if mask, ok := fs["--netmask"]; ok {
+				h.Netmask = mask
+			}
+		}
+		if ip, ok := fs["--ipv6"]; ok {
+			n, err := strconv.Atoi(fs["--netmasklengthv6"])
+			if err != nil {
+				return fmt.Errorf("invalid --netmasklengthv6: %w", errSyntax)
+			}
+			h.IPv6, h.IPv6Len = ip, n
+		}
+	default:
+		return fmt.Errorf("unknown hostonlyif subcommand '%s': %w", args[0], errSyntax)
+	}
+	return nil
+}
+
+func hostonlynet(st *state, args []string, _ io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("hostonlynet requires a subcommand: %w", errSyntax)
+	}
+	fs, _, err := flags(args[1:], "--enable", "--disable")
+	if err != nil {
+		return err
+	}
+	name := fs["--name"]
+	if name == "" {
+		return fmt.Errorf("hostonlynet requires --name: %w", errSyntax)
+	}
+	h, _ := st.hostnet(name)
+
+	switch args[0] {
+	case "add":
+		if h != nil {
+			return fmt.Errorf("A host-only network named '%s' already exists", name)
+		}
+		h = &hostnet{Name: name, UUID: fmt.Sprintf("00000000-0000-4000-8000-%012x", st.next())}
+		st.HostNets = append(st.HostNets, h)
+	case "modify":
+		if h == nil {
+			_, err := st.hostnet(name)
+			return err
+		}
+	case "remove":
+		if h == nil {
+			_, err := st.hostnet(name)
+			return err
+		}
+		for i, hh := range st.HostNets {
+			if hh == h {
+				st.HostNets = append(st.HostNets[:i], st.HostNets[i+1:]...)
+				break
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown hostonlynet subcommand '%s': %w", args[0], errSyntax)
+	}
+
+	for k, v := range fs {
+		switch k {
+		case "--netmask":
+			h.Netmask = v
+		case "--lower-ip":
+			h.LowerIP = v
+		case "--upper-ip":
+			h.UpperIP = v
+		case "--enable":
+			h.Enabled = true
+		case "--disable":
+			h.Enabled = false
+		}
+	}
+	return nil
+}
+
+func dhcpserver(st *state, args []string, _ io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("dhcpserver requires a subcommand: %w", errSyntax)
+	}
+	fs, _, err := flags(args[1:], "--enable", "--disable")
+	if err != nil {
+		return err
+	}
+	network := fs["--network"]
+	if network == "" {
+		network = fs["--netname"]
+	}
+	if ifname := fs["--ifname"]; ifname != "" {
+		network = "HostInterfaceNetworking-" + ifname
+	}
+	if network == "" {
+		return fmt.Errorf("dhcpserver requires --network, --netname or --ifname: %w", errSyntax)
+	}
+	d := st.dhcp(network)
+
+	switch args[0] {
+	case "add":
+		if d != nil {
+			return fmt.Errorf("DHCP server already exists")
+		}
+		d = &dhcp{Network: network}
+		st.DHCP = append(st.DHCP, d)
+	case "modify":
+		if d == nil {
+			return fmt.Errorf("DHCP server does not exist")
+		}
+	case "start", "stop", "restart":
+		if d == nil {
+			return fmt.Errorf("DHCP server does not exist")
+		}
+		return nil
+	case "findlease":
+		if d == nil {
+			return fmt.Errorf("DHCP server does not exist")
+		}
+		if fs["--mac-address"] == "" {
+			return fmt.Errorf("dhcpserver findlease requires --mac-address: %w", errSyntax)
+		}
+		// The fake runs no DHCP server, so there are never any leases.
+		return fmt.Errorf("Could not find a lease for %s", fs["--mac-address"])
+	case "remove":
+		if d == nil {
+			return fmt.Errorf("DHCP server does not exist")
+		}
+		for i, dd := range st.DHCP {
+			if dd == d {
+				st.DHCP = append(st.DHCP[:i], st.DHCP[i+1:]...)
+				break
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown dhcpserver subcommand '%s': %w", args[0], errSyntax)
+	}
+
+	for k, v := range fs {
+		switch strings.ReplaceAll(k, "-", "") {
+		case "ip", "serverip":
+			d.IP = v
+		case "netmask":
+			d.Netmask = v
+		case "lowerip":
+			d.LowerIP = v
+		case "upperip":
+			d.UpperIP = v
+		case "enable":
+			d.Enabled = true
+		case "disable":
+			d.Enabled = false
+		}
+	}
+	return nil
+}
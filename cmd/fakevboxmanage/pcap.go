@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// writeTrace writes the trace of the NIC, a pcap file with the DHCPDISCOVER
+// the guest sends after booting.
+func writeTrace(n *nic) error {
+	mac, err := hex.DecodeString(n.MAC)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("invalid MAC address '%s'", n.MAC)
+	}
+	frame := dhcpDiscover(mac)
+
+	le := binary.LittleEndian
+	b := make([]byte, 24+16+len(frame))
+	le.PutUint32(b[0:], 0xa1b2c3d4)
+	le.PutUint16(b[4:], 2)
+	le.PutUint16(b[6:], 4)
+	le.PutUint32(b[16:], 65535)
+	le.PutUint32(b[20:], 1) // Ethernet
+	now := time.Now()
+	le.PutUint32(b[24:], uint32(now.Unix()))
+	le.PutUint32(b[28:], uint32(now.Nanosecond()/1000))
+	le.PutUint32(b[32:], uint32(len(frame)))
+	le.PutUint32(b[36:], uint32(len(frame)))
+	copy(b[40:], frame)
+	return os.WriteFile(n.TraceFile, b, 0o600)
+}
+
+// dhcpDiscover returns the Ethernet frame of a DHCPDISCOVER sent from the MAC
+// address, without the IPv4 and UDP checksums.
+func dhcpDiscover(mac []byte) []byte {
+	be := binary.BigEndian
+	dhcp := make([]byte, 240, 244)
+	dhcp[0], dhcp[1], dhcp[2] = 1, 1, 6 // BOOTREQUEST over Ethernet
+	be.PutUint32(dhcp[4:], 0x3903f326)  // transaction ID
+	copy(dhcp[28:], mac)
+	be.PutUint32(dhcp[236:], 0x63825363) // magic cookie
+	dhcp = append(dhcp, 53, 1, 1, 255)   // DHCP message type DISCOVER
+
+	udp := make([]byte, 8, 8+len(dhcp))
+	be.PutUint16(udp[0:], 68)
+	be.PutUint16(udp[2:], 67)
+	be.PutUint16(udp[4:], uint16(8+len(dhcp)))
+	udp = append(udp, dhcp...)
+
+	ip := make([]byte, 20, 20+len(udp))
+	ip[0] = 0x45
+	be.PutUint16(ip[2:], uint16(20+len(udp)))
+	ip[8], ip[9] = 64, 17 // TTL and UDP
+	copy(ip[16:], []byte{255, 255, 255, 255})
+	ip = append(ip, udp...)
+
+	eth := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	eth = append(eth, mac...)
+	eth = append(eth, 0x08, 0x00)
+	return append(eth, ip...)
+}
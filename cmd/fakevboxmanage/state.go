@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// state of the fake VirtualBox, stored as JSON between the invocations.
+type state struct {
+	Version  string      `json:"version"`
+	Seq      int         `json:"seq"`
+	Machines []*machine  `json:"machines"`
+	Hostonly []*hostonly `json:"hostonlyifs"`
+	HostNets []*hostnet  `json:"hostonlynets"`
+	NATNets  []*natnet   `json:"natnets"`
+	DHCP     []*dhcp     `json:"dhcpservers"`
+}
+
+type machine struct {
+	Name       string               `json:"name"`
+	UUID       string               `json:"uuid"`
+	OSType     string               `json:"ostype"`
+	Firmware   string               `json:"firmware"`
+	State      string               `json:"state"`
+	CfgFile    string               `json:"cfgfile"`
+	CPUs       int                  `json:"cpus"`
+	Memory     int                  `json:"memory"`
+	VRAM       int                  `json:"vram"`
+	Boot       [4]string            `json:"boot"`
+	NICs       [8]nic               `json:"nics"`
+	Properties map[string]*property `json:"properties"`
+	Bandwidth  []*bwgroup           `json:"bandwidth,omitempty"`
+	Started    int64                `json:"started,omitempty"` // in milliseconds since the epoch
+}
+
+// property is a guest property of a machine.
+type property struct {
+	Value     string `json:"value"`
+	Flags     string `json:"flags,omitempty"`
+	Timestamp int64  `json:"timestamp"` // in nanoseconds since the epoch
+}
+
+type bwgroup struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Limit uint64 `json:"limit"`
+}
+
+type nic struct {
+	Type       string   `json:"type"`
+	Hardware   string   `json:"hardware"`
+	MAC        string   `json:"mac"`
+	Cable      string   `json:"cable"`
+	Hostonly   string   `json:"hostonly,omitempty"`
+	HostNet    string   `json:"hostnet,omitempty"`
+	NATNet     string   `json:"natnet,omitempty"`
+	Bridge     string   `json:"bridge,omitempty"`
+	Intnet     string   `json:"intnet,omitempty"`
+	Bandwidth  string   `json:"bandwidth,omitempty"`
+	Forwarding []string `json:"forwarding,omitempty"`
+
+	Promisc    string            `json:"promisc,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Trace      string            `json:"trace,omitempty"`
+	TraceFile  string            `json:"tracefile,omitempty"`
+}
+
+type hostonly struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Netmask  string `json:"netmask"`
+	IPv6     string `json:"ipv6"`
+	IPv6Len  int    `json:"ipv6len"`
+	DHCP     bool   `json:"dhcp"`
+	Index    int    `json:"index"`
+	Disabled bool   `json:"disabled"`
+}
+
+type hostnet struct {
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	Netmask string `json:"netmask"`
+	LowerIP string `json:"lowerip"`
+	UpperIP string `json:"upperip"`
+	Enabled bool   `json:"enabled"`
+}
+
+type natnet struct {
+	Name         string            `json:"name"`
+	Network      string            `json:"network"`
+	Enabled      bool              `json:"enabled"`
+	DHCP         bool              `json:"dhcp"`
+	IPv6         bool              `json:"ipv6"`
+	IPv6Prefix   string            `json:"ipv6prefix"`
+	IPv6Default  bool              `json:"ipv6default"`
+	PortForward4 []string          `json:"portforward4,omitempty"`
+	PortForward6 []string          `json:"portforward6,omitempty"`
+	Loopback4    map[string]string `json:"loopback4,omitempty"`
+	Loopback6    map[string]string `json:"loopback6,omitempty"`
+	Running      bool              `json:"running"`
+}
+
+type dhcp struct {
+	Network string `json:"network"`
+	IP      string `json:"ip"`
+	Netmask string `json:"netmask"`
+	LowerIP string `json:"lowerip"`
+	UpperIP string `json:"upperip"`
+	Enabled bool   `json:"enabled"`
+}
+
+// statePath returns the path of the state file: $FAKEVBOXMANAGE_STATE, or
+// fakevboxmanage.json within $VBOX_USER_HOME or the current directory.
+func statePath() string {
+	if p := os.Getenv("FAKEVBOXMANAGE_STATE"); p != "" {
+		return p
+	}
+	return filepath.Join(os.Getenv("VBOX_USER_HOME"), "fakevboxmanage.json")
+}
+
+func load(name string) (*state, error) {
+	st := &state{}
+	data, err := os.ReadFile(name) // #nosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return st, nil
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("unable to parse state %s: %v", name, err)
+	}
+	return st, nil
+}
+
+// save writes the state into a temporary file first, so a concurrent reader
+// never sees a partially written state.
+func (st *state) save(name string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (st *state) next() int {
+	st.Seq++
+	return st.Seq
+}
+
+func (st *state) machine(id string) (*machine, error) {
+	for _, m := range st.Machines {
+		if m.Name == id || m.UUID == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("Could not find a registered machine named '%s'", id)
+}
+
+func (st *state) hostonly(name string) (*hostonly, error) {
+	for _, h := range st.Hostonly {
+		if h.Name == name {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("The host network interface named '%s' could not be found", name)
+}
+
+func (st *state) hostnet(name string) (*hostnet, error) {
+	for _, h := range st.HostNets {
+		if h.Name == name {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("The host-only network named '%s' could not be found", name)
+}
+
+func (st *state) natnet(name string) (*natnet, error) {
+	for _, n := range st.NATNets {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("Code VBOX_E_OBJECT_NOT_FOUND (0x80bb0001) - The NAT network named '%s' could not be found", name)
+}
+
+func (st *state) dhcp(network string) *dhcp {
+	for _, d := range st.DHCP {
+		if d.Network == network {
+			return d
+		}
+	}
+	return nil
+}
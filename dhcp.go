@@ -1,9 +1,34 @@
 package virtualbox
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	reDHCPNotFound      = regexp.MustCompile(`(?i)DHCP server .*(does not exist|could not be found|not found)|VBOX_E_OBJECT_NOT_FOUND`)
+	reDHCPLeaseNotFound = regexp.MustCompile(`Could not find a lease for`)
+	reDHCPLeaseTime     = regexp.MustCompile(`\((\d+)\)$`)
+	reDHCPOption        = regexp.MustCompile(`^(\d+)/\w+$`)
+	reDHCPMACConfig     = regexp.MustCompile(`^MAC(?: Address)? ([0-9A-Fa-f:]+)`)
+	reDHCPVMConfig      = regexp.MustCompile(`^VM NIC: (.+)/(\d+)`)
+)
+
+var (
+	// ErrDHCPExist is returned when the network already has a DHCP server.
+	ErrDHCPExist = errors.New("DHCP server already exists")
+	// ErrDHCPNotExist is returned when the network has no DHCP server.
+	ErrDHCPNotExist = errors.New("DHCP server does not exist")
+	// ErrLeaseNotFound is returned when the DHCP server has no lease for the
+	// MAC address.
+	ErrLeaseNotFound = errors.New("DHCP lease not found")
 )
 
 // DHCP server info.
@@ -12,7 +37,358 @@ type DHCP struct {
 	IPv4        net.IPNet
 	LowerIP     net.IP
 	UpperIP     net.IP
-	Enabled     bool
+	Enabled     bool                // whether the server is enabled, as listed by DHCPs; used by AddDHCP, ignored by ModifyDHCP
+	Options     DHCPOptions         // the global options
+	Groups      []DHCPGroupConfig   // the options of the groups of machines
+	Machines    []DHCPMachineConfig // the options of the single machines
+}
+
+// DHCPOptions are the options given to the DHCP clients. The options which
+// are not set are left unchanged when the DHCP server is modified.
+type DHCPOptions struct {
+	DNSServers []net.IP       // option 6
+	DomainName string         // option 15
+	Routers    []net.IP       // option 3
+	LeaseTime  time.Duration  // the default lease time
+	Other      map[int]string // the other options, by their number
+}
+
+// DHCP option numbers, see RFC 2132.
+const (
+	dhcpOptRouters    = 3
+	dhcpOptDNSServers = 6
+	dhcpOptDomainName = 15
+)
+
+// DHCPGroupConfig is the configuration of a named group of machines, selected
+// by their MAC addresses.
+type DHCPGroupConfig struct {
+	Name string
+	MACs []string
+	DHCPOptions
+}
+
+// DHCPMachineConfig is the configuration of a single machine, selected either
+// by the MAC address or by the machine and its NIC slot.
+type DHCPMachineConfig struct {
+	MAC          string
+	VM           string
+	NIC          int
+	FixedAddress net.IP
+	DHCPOptions
+}
+
+// DHCPLease is a lease of the DHCP server.
+type DHCPLease struct {
+	IP     net.IP
+	MAC    string
+	State  string // e.g. acked, offered, released or expired
+	Issued time.Time
+	Expire time.Time
+}
+
+// args returns the flags setting the options, in the scope selected by the
+// preceding --group, --vm or --mac-address flag.
+func (o DHCPOptions) args() []string {
+	var args []string
+	ips := func(ips []net.IP) string {
+		s := make([]string, len(ips))
+		for i, ip := range ips {
+			s[i] = ip.String()
+		}
+		return strings.Join(s, ",")
+	}
+	if len(o.Routers) > 0 {
+		args = append(args, "--set-opt", strconv.Itoa(dhcpOptRouters), ips(o.Routers))
+	}
+	if len(o.DNSServers) > 0 {
+		args = append(args, "--set-opt", strconv.Itoa(dhcpOptDNSServers), ips(o.DNSServers))
+	}
+	if o.DomainName != "" {
+		args = append(args, "--set-opt", strconv.Itoa(dhcpOptDomainName), o.DomainName)
+	}
+	if o.LeaseTime > 0 {
+		args = append(args, "--default-lease-time", strconv.Itoa(int(o.LeaseTime/time.Second)))
+	}
+	for _, opt := range sortedKeys(o.Other) {
+		args = append(args, "--set-opt", strconv.Itoa(opt), o.Other[opt])
+	}
+	return args
+}
+
+// changed returns the options which are set and differ from the current ones.
+func (o DHCPOptions) changed(cur DHCPOptions) DHCPOptions {
+	var c DHCPOptions
+	if len(o.Routers) > 0 && !equalIPs(o.Routers, cur.Routers) {
+		c.Routers = o.Routers
+	}
+	if len(o.DNSServers) > 0 && !equalIPs(o.DNSServers, cur.DNSServers) {
+		c.DNSServers = o.DNSServers
+	}
+	if o.DomainName != cur.DomainName {
+		c.DomainName = o.DomainName
+	}
+	if o.LeaseTime != cur.LeaseTime {
+		c.LeaseTime = o.LeaseTime
+	}
+	for opt, v := range o.Other {
+		if cur.Other[opt] != v {
+			if c.Other == nil {
+				c.Other = map[int]string{}
+			}
+			c.Other[opt] = v
+		}
+	}
+	return c
+}
+
+func equalIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[int]string) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// args returns the flags of 'dhcpserver add' for the DHCP server.
+func (d DHCP) args() []string {
+	args := []string{"--network", d.NetworkName}
+	if d.IPv4.IP != nil {
+		args = append(args, "--ip", d.IPv4.IP.String())
+	}
+	if d.IPv4.Mask != nil {
+		args = append(args, "--netmask", net.IP(d.IPv4.Mask).String())
+	}
+	if d.LowerIP != nil {
+		args = append(args, "--lowerip", d.LowerIP.String())
+	}
+	if d.UpperIP != nil {
+		args = append(args, "--upperip", d.UpperIP.String())
+	}
+	if d.Enabled {
+		args = append(args, "--enable")
+	} else {
+		args = append(args, "--disable")
+	}
+	args = append(args, d.Options.args()...)
+	for _, g := range d.Groups {
+		args = append(args, "--group", g.Name)
+		for _, mac := range g.MACs {
+			args = append(args, "--incl-mac", mac)
+		}
+		args = append(args, g.DHCPOptions.args()...)
+	}
+	for _, c := range d.Machines {
+		if c.MAC != "" {
+			args = append(args, "--mac-address", c.MAC)
+		} else {
+			args = append(args, "--vm", c.VM, "--nic", strconv.Itoa(c.NIC))
+		}
+		if c.FixedAddress != nil {
+			args = append(args, "--fixed-address", c.FixedAddress.String())
+		}
+		args = append(args, c.DHCPOptions.args()...)
+	}
+	return args
+}
+
+// modifyArgs returns the flags of 'dhcpserver modify' changing the current
+// DHCP server into d. Only the addresses and options which are set and differ
+// are changed, and only the MAC addresses which are not in the group yet are
+// added to it, so modifying the server again with d changes nothing.
+func (d DHCP) modifyArgs(cur *DHCP) []string {
+	var args []string
+	for _, a := range []struct {
+		flag     string
+		ip, curr net.IP
+	}{
+		{"--ip", d.IPv4.IP, cur.IPv4.IP},
+		{"--netmask", net.IP(d.IPv4.Mask), net.IP(cur.IPv4.Mask)},
+		{"--lowerip", d.LowerIP, cur.LowerIP},
+		{"--upperip", d.UpperIP, cur.UpperIP},
+	} {
+		if a.ip != nil && !a.ip.Equal(a.curr) {
+			args = append(args, a.flag, a.ip.String())
+		}
+	}
+	args = append(args, d.Options.changed(cur.Options).args()...)
+	for _, g := range d.Groups {
+		var (
+			curg DHCPGroupConfig
+			macs []string
+		)
+		for _, c := range cur.Groups {
+			if c.Name == g.Name {
+				curg = c
+			}
+		}
+	include:
+		for _, mac := range g.MACs {
+			for _, c := range curg.MACs {
+				if strings.EqualFold(formatMAC(c), formatMAC(mac)) {
+					continue include
+				}
+			}
+			macs = append(macs, mac)
+		}
+		opts := g.DHCPOptions.changed(curg.DHCPOptions).args()
+		if len(macs) == 0 && len(opts) == 0 {
+			continue
+		}
+		args = append(args, "--group", g.Name)
+		for _, mac := range macs {
+			args = append(args, "--incl-mac", mac)
+		}
+		args = append(args, opts...)
+	}
+	for _, c := range d.Machines {
+		var curc DHCPMachineConfig
+		for _, cc := range cur.Machines {
+			if (c.MAC != "" && strings.EqualFold(formatMAC(cc.MAC), formatMAC(c.MAC))) ||
+				(c.MAC == "" && cc.MAC == "" && cc.VM == c.VM && cc.NIC == c.NIC) {
+				curc = cc
+			}
+		}
+		var margs []string
+		if c.FixedAddress != nil && !c.FixedAddress.Equal(curc.FixedAddress) {
+			margs = append(margs, "--fixed-address", c.FixedAddress.String())
+		}
+		margs = append(margs, c.DHCPOptions.changed(curc.DHCPOptions).args()...)
+		if len(margs) == 0 {
+			continue
+		}
+		if c.MAC != "" {
+			args = append(args, "--mac-address", c.MAC)
+		} else {
+			args = append(args, "--vm", c.VM, "--nic", strconv.Itoa(c.NIC))
+		}
+		args = append(args, margs...)
+	}
+	return args
+}
+
+// AddDHCP adds the DHCP server to the network named by d.NetworkName, e.g.
+// HostInterfaceNetworking-vboxnet0 for a host-only interface.
+func (m *Manager) AddDHCP(ctx context.Context, d DHCP) error {
+	if d.NetworkName == "" {
+		return fmt.Errorf("DHCP network name is empty")
+	}
+	m.log.Printf("adding DHCP server to %q", d.NetworkName)
+	args := append([]string{"dhcpserver", "add"}, d.args()...)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if strings.Contains(stderr, "already exists") {
+			return ErrDHCPExist
+		}
+		return fmt.Errorf("unable to add DHCP server: %w", err)
+	}
+	return nil
+}
+
+// ModifyDHCP changes the DHCP server of the network named by d.NetworkName.
+// The addresses and options which are not set are left unchanged, and the
+// groups and machines are merged with the existing ones. Enabled is ignored,
+// the server is enabled and disabled with EnableDHCP and DisableDHCP.
+func (m *Manager) ModifyDHCP(ctx context.Context, d DHCP) error {
+	dhcps, err := m.DHCPs(ctx)
+	if err != nil {
+		return err
+	}
+	cur, ok := dhcps[d.NetworkName]
+	if !ok {
+		return ErrDHCPNotExist
+	}
+	args := d.modifyArgs(cur)
+	if len(args) == 0 {
+		return nil
+	}
+	m.log.Printf("modifying DHCP server of %q", d.NetworkName)
+	return m.modifyDHCP(ctx, d.NetworkName, args...)
+}
+
+func (m *Manager) modifyDHCP(ctx context.Context, network string, args ...string) error {
+	args = append([]string{"dhcpserver", "modify", "--network", network}, args...)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if reDHCPNotFound.MatchString(stderr) {
+			return ErrDHCPNotExist
+		}
+		return fmt.Errorf("unable to modify DHCP server: %w", err)
+	}
+	return nil
+}
+
+// EnableDHCP enables the DHCP server of the network, so it starts with the
+// network.
+func (m *Manager) EnableDHCP(ctx context.Context, network string) error {
+	m.log.Printf("enabling DHCP server of %q", network)
+	return m.modifyDHCP(ctx, network, "--enable")
+}
+
+// DisableDHCP disables the DHCP server of the network.
+func (m *Manager) DisableDHCP(ctx context.Context, network string) error {
+	m.log.Printf("disabling DHCP server of %q", network)
+	return m.modifyDHCP(ctx, network, "--disable")
+}
+
+// RemoveDHCP removes the DHCP server of the network.
+func (m *Manager) RemoveDHCP(ctx context.Context, network string) error {
+	return m.dhcpserver(ctx, "remove", network)
+}
+
+// StartDHCP starts the DHCP server of the network.
+func (m *Manager) StartDHCP(ctx context.Context, network string) error {
+	return m.dhcpserver(ctx, "start", network)
+}
+
+// StopDHCP stops the DHCP server of the network.
+func (m *Manager) StopDHCP(ctx context.Context, network string) error {
+	return m.dhcpserver(ctx, "stop", network)
+}
+
+// RestartDHCP restarts the DHCP server of the network, e.g. to apply the
+// modified options.
+func (m *Manager) RestartDHCP(ctx context.Context, network string) error {
+	return m.dhcpserver(ctx, "restart", network)
+}
+
+func (m *Manager) dhcpserver(ctx context.Context, cmd, network string) error {
+	m.log.Printf("%s DHCP server of %q", cmd, network)
+	if _, stderr, err := m.run(ctx, "dhcpserver", cmd, "--network", network); err != nil {
+		if reDHCPNotFound.MatchString(stderr) {
+			return ErrDHCPNotExist
+		}
+		return fmt.Errorf("unable to %s DHCP server: %w", cmd, err)
+	}
+	return nil
+}
+
+// DHCPs returns all DHCP servers keyed by their network name.
+func (m *Manager) DHCPs(ctx context.Context) (map[string]*DHCP, error) {
+	stdout, _, err := m.run(ctx, "list", "dhcpservers")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list DHCP servers: %w", err)
+	}
+	dhcps, err := parseDHCPs(stdout)
+	if err != nil {
+		return nil, err
+	}
+	dm := make(map[string]*DHCP, len(dhcps))
+	for _, d := range dhcps {
+		dm[d.NetworkName] = d
+	}
+	return dm, nil
 }
 
 func addDHCP(kind, name string, d DHCP) error {
@@ -28,7 +404,7 @@ func addDHCP(kind, name string, d DHCP) error {
 	} else {
 		args = append(args, "--disable")
 	}
-	_, _, err := Manage().run(args...)
+	_, _, err := Manage().run(context.Background(), args...)
 	return err
 }
 
@@ -43,42 +419,7 @@ func AddHostonlyDHCP(ifname string, d DHCP) error {
 }
 
 // DHCPs gets all DHCP server settings in a map keyed by DHCP.NetworkName.
+// DEPRECATED: Use (*Manager).DHCPs
 func DHCPs() (map[string]*DHCP, error) {
-	out, _, err := Manage().run("list", "dhcpservers")
-	if err != nil {
-		return nil, err
-	}
-	s := bufio.NewScanner(strings.NewReader(out))
-	m := map[string]*DHCP{}
-	dhcp := &DHCP{}
-	for s.Scan() {
-		line := s.Text()
-		if line == "" {
-			m[dhcp.NetworkName] = dhcp
-			dhcp = &DHCP{}
-			continue
-		}
-		res := reColonLine.FindStringSubmatch(line)
-		if res == nil {
-			continue
-		}
-		switch key, val := res[1], res[2]; key {
-		case "NetworkName":
-			dhcp.NetworkName = val
-		case "IP":
-			dhcp.IPv4.IP = net.ParseIP(val)
-		case "upperIPAddress":
-			dhcp.UpperIP = net.ParseIP(val)
-		case "lowerIPAddress":
-			dhcp.LowerIP = net.ParseIP(val)
-		case "NetworkMask":
-			dhcp.IPv4.Mask = ParseIPv4Mask(val)
-		case "Enabled":
-			dhcp.Enabled = (val == stringYes)
-		}
-	}
-	if err := s.Err(); err != nil {
-		return nil, err
-	}
-	return m, nil
+	return defaultManager.DHCPs(context.Background())
 }
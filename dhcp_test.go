@@ -1,8 +1,13 @@
 package virtualbox
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/go-test/deep"
 	"github.com/golang/mock/gomock"
 )
 
@@ -12,7 +17,7 @@ func TestDHCPs(t *testing.T) {
 	if ManageMock != nil {
 		listDhcpServersOut := ReadTestData("vboxmanage-list-dhcpservers-1.out")
 		gomock.InOrder(
-			ManageMock.EXPECT().run("list", "dhcpservers").Return(listDhcpServersOut, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "list", "dhcpservers").Return(listDhcpServersOut, "", nil).Times(1),
 		)
 	}
 	m, err := DHCPs()
@@ -26,3 +31,191 @@ func TestDHCPs(t *testing.T) {
 
 	Teardown()
 }
+
+const listDHCPServersOptions = `NetworkName:    HostInterfaceNetworking-vboxnet0
+Dhcpd IP:       192.168.56.100
+LowerIPAddress: 192.168.56.101
+UpperIPAddress: 192.168.56.254
+NetworkMask:    255.255.255.0
+Enabled:        Yes
+Global Configuration:
+    minLeaseTime:     default
+    defaultLeaseTime: 600
+    maxLeaseTime:     default
+    Forced options:   None
+    Suppressed opts.: None
+          1/legacy: 255.255.255.0
+          3/legacy: 192.168.56.1
+          6/legacy: 8.8.8.8,8.8.4.4
+         15/legacy: example.com
+Groups:
+Group:                web
+    Conditions:       include MAC 08:00:27:00:00:01
+                      include MAC 08:00:27:00:00:02
+    minLeaseTime:     default
+    defaultLeaseTime: default
+    maxLeaseTime:     default
+         15/legacy: web.example.com
+Individual Configs:
+Individual Config:    MAC Address 08:00:27:00:00:03
+    minLeaseTime:     default
+    defaultLeaseTime: 3600
+    maxLeaseTime:     default
+    Fixed Address:    192.168.56.50
+Individual Config:    VM NIC: go-virtualbox/1
+    minLeaseTime:     default
+    defaultLeaseTime: default
+    maxLeaseTime:     default
+    Fixed Address:    192.168.56.51
+
+`
+
+var testDHCPOptions = DHCP{
+	NetworkName: "HostInterfaceNetworking-vboxnet0",
+	IPv4:        net.IPNet{IP: net.ParseIP("192.168.56.100"), Mask: net.IPv4Mask(255, 255, 255, 0)},
+	LowerIP:     net.ParseIP("192.168.56.101"),
+	UpperIP:     net.ParseIP("192.168.56.254"),
+	Enabled:     true,
+	Options: DHCPOptions{
+		DNSServers: []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")},
+		DomainName: "example.com",
+		Routers:    []net.IP{net.ParseIP("192.168.56.1")},
+		LeaseTime:  10 * time.Minute,
+	},
+	Groups: []DHCPGroupConfig{{
+		Name:        "web",
+		MACs:        []string{"08:00:27:00:00:01", "08:00:27:00:00:02"},
+		DHCPOptions: DHCPOptions{DomainName: "web.example.com"},
+	}},
+	Machines: []DHCPMachineConfig{
+		{MAC: "08:00:27:00:00:03", FixedAddress: net.ParseIP("192.168.56.50"), DHCPOptions: DHCPOptions{LeaseTime: time.Hour}},
+		{VM: "go-virtualbox", NIC: 1, FixedAddress: net.ParseIP("192.168.56.51")},
+	},
+}
+
+func TestParseDHCPs(t *testing.T) {
+	got, err := parseDHCPs(listDHCPServersOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(got, []*DHCP{&testDHCPOptions}); diff != nil {
+		t.Errorf("parseDHCPs() = %+v; diff = %v", got, diff)
+	}
+}
+
+func TestManagerDHCP(t *testing.T) {
+	const network = "HostInterfaceNetworking-vboxnet0"
+	r := NewReplayer(
+		Call{Args: []string{"dhcpserver", "add", "--network", network,
+			"--ip", "192.168.56.100", "--netmask", "255.255.255.0",
+			"--lowerip", "192.168.56.101", "--upperip", "192.168.56.254", "--enable",
+			"--set-opt", "3", "192.168.56.1", "--set-opt", "6", "8.8.8.8,8.8.4.4",
+			"--set-opt", "15", "example.com", "--default-lease-time", "600",
+			"--group", "web", "--incl-mac", "08:00:27:00:00:01", "--incl-mac", "08:00:27:00:00:02",
+			"--set-opt", "15", "web.example.com",
+			"--mac-address", "08:00:27:00:00:03", "--fixed-address", "192.168.56.50", "--default-lease-time", "3600",
+			"--vm", "go-virtualbox", "--nic", "1", "--fixed-address", "192.168.56.51"}},
+		Call{Args: []string{"list", "dhcpservers"}, Stdout: listDHCPServersOptions},
+		Call{Args: []string{"dhcpserver", "modify", "--network", network,
+			"--upperip", "192.168.56.200", "--set-opt", "42", "192.168.56.1",
+			"--group", "web", "--incl-mac", "08:00:27:00:00:04"}},
+		Call{Args: []string{"list", "dhcpservers"}, Stdout: listDHCPServersOptions},
+		Call{Args: []string{"dhcpserver", "modify", "--network", network, "--disable"}},
+		Call{Args: []string{"dhcpserver", "modify", "--network", network, "--enable"}},
+		Call{Args: []string{"dhcpserver", "restart", "--network", network}},
+		Call{Args: []string{"dhcpserver", "stop", "--network", network}},
+		Call{Args: []string{"dhcpserver", "remove", "--network", network}},
+		Call{Args: []string{"dhcpserver", "start", "--network", network},
+			Stderr:   "VBoxManage: error: DHCP server does not exist\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	if err := m.AddDHCP(ctx, testDHCPOptions); err != nil {
+		t.Fatal(err)
+	}
+	// Only the settings which differ from the listed ones are modified.
+	d := DHCP{
+		NetworkName: network,
+		LowerIP:     net.ParseIP("192.168.56.101"),
+		UpperIP:     net.ParseIP("192.168.56.200"),
+		Options: DHCPOptions{
+			DomainName: "example.com",
+			Other:      map[int]string{42: "192.168.56.1"},
+		},
+		Groups: []DHCPGroupConfig{{
+			Name:        "web",
+			MACs:        []string{"08:00:27:00:00:01", "08:00:27:00:00:04"},
+			DHCPOptions: DHCPOptions{DomainName: "web.example.com"},
+		}},
+		Machines: []DHCPMachineConfig{{MAC: "080027000003", FixedAddress: net.ParseIP("192.168.56.50")}},
+	}
+	if err := m.ModifyDHCP(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is run when nothing changes.
+	if err := m.ModifyDHCP(ctx, testDHCPOptions); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DisableDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if err := m.EnableDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if err := m.RestartDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if err := m.StopDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if err := m.RemoveDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if err := m.StartDHCP(ctx, network); !errors.Is(err, ErrDHCPNotExist) {
+		t.Errorf("StartDHCP() = %v; want %v", err, ErrDHCPNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerFindLease(t *testing.T) {
+	const network = "HostInterfaceNetworking-vboxnet0"
+	args := []string{"dhcpserver", "findlease", "--network", network, "--mac-address", "08:00:27:ee:1d:f7"}
+	r := NewReplayer(
+		Call{Args: []string{"--version"}, Stdout: "6.1.38r153438\n"},
+		Call{Args: args, Stdout: `IP Address:  192.168.56.101
+MAC Address: 08:00:27:ee:1d:f7
+State:       acked
+Issued:      2023-01-02T10:00:00.000000000Z (1672653600)
+Expire:      2023-01-02T10:10:00.000000000Z (1672654200)
+TTL:         600 sec, currently 0 sec left
+`},
+		Call{Args: args, Stderr: "VBoxManage: error: Could not find a lease for 08:00:27:ee:1d:f7\n", ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	lease, err := m.FindLease(ctx, network, "080027EE1DF7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DHCPLease{
+		IP:     net.ParseIP("192.168.56.101"),
+		MAC:    "08:00:27:ee:1d:f7",
+		State:  "acked",
+		Issued: time.Unix(1672653600, 0),
+		Expire: time.Unix(1672654200, 0),
+	}
+	if diff := deep.Equal(lease, want); diff != nil {
+		t.Errorf("FindLease() = %+v; diff = %v", lease, diff)
+	}
+	if _, err := m.FindLease(ctx, network, "08:00:27:ee:1d:f7"); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("FindLease() = %v; want %v", err, ErrLeaseNotFound)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
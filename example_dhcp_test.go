@@ -0,0 +1,36 @@
+package virtualbox_test
+
+import (
+	"context"
+	"log"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+func ExampleManager_FindLease() {
+	ctx := context.Background()
+	m := virtualbox.NewManager()
+
+	vm, err := m.Machine(ctx, VM)
+	if err != nil {
+		panic(err)
+	}
+	dhcps, err := m.DHCPs(ctx)
+	if err != nil {
+		panic(err)
+	}
+	for _, nic := range vm.NICs {
+		if nic.Network != virtualbox.NICNetHostonly {
+			continue
+		}
+		network := "HostInterfaceNetworking-" + nic.HostInterface
+		if _, ok := dhcps[network]; !ok {
+			continue
+		}
+		lease, err := m.FindLease(ctx, network, nic.MacAddr)
+		if err != nil {
+			panic(err)
+		}
+		log.Println("ip:", lease.IP, "state:", lease.State, "expires:", lease.Expire)
+	}
+}
@@ -1,13 +1,15 @@
 package virtualbox
 
+import "context"
+
 // SetExtra sets extra data. Name could be "global"|<uuid>|<vmname>
 func SetExtra(name, key, val string) error {
-	_, _, err := Manage().run("setextradata", name, key, val)
+	_, _, err := Manage().run(context.Background(), "setextradata", name, key, val)
 	return err
 }
 
 // DelExtra deletes extra data. Name could be "global"|<uuid>|<vmname>
 func DelExtra(name, key string) error {
-	_, _, err := Manage().run("setextradata", name, key)
+	_, _, err := Manage().run(context.Background(), "setextradata", name, key)
 	return err
 }
@@ -0,0 +1,565 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeVBoxManage is cmd/fakevboxmanage built once for all the tests, in a
+// directory removed by TestMain.
+var fakeVBoxManage struct {
+	once sync.Once
+	dir  string
+	bin  string
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if fakeVBoxManage.dir != "" {
+		os.RemoveAll(fakeVBoxManage.dir)
+	}
+	os.Exit(code)
+}
+
+// buildFakeVBoxManage builds cmd/fakevboxmanage on the first use and returns
+// its path.
+func buildFakeVBoxManage(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping the build of fakevboxmanage in short mode")
+	}
+	f := &fakeVBoxManage
+	f.once.Do(func() {
+		gobin, err := exec.LookPath("go")
+		if err != nil {
+			f.err = err
+			return
+		}
+		if f.dir, f.err = os.MkdirTemp("", "fakevboxmanage"); f.err != nil {
+			return
+		}
+		f.bin = filepath.Join(f.dir, "VBoxManage")
+		if runtime.GOOS == osWindows {
+			f.bin += ".exe"
+		}
+		out, err := exec.Command(gobin, "build", "-o", f.bin, "./cmd/fakevboxmanage").CombinedOutput() // #nosec
+		if err != nil {
+			f.err = fmt.Errorf("%w\n%s", err, out)
+		}
+	})
+	if f.err != nil {
+		t.Fatalf("unable to build fakevboxmanage: %v", f.err)
+	}
+	return f.bin
+}
+
+func TestFakeVBoxManage(t *testing.T) {
+	ctx := context.Background()
+	bin := buildFakeVBoxManage(t)
+	m := NewManager(VBoxManagePath(bin), UserHome(t.TempDir()))
+
+	v, err := m.Version(ctx)
+	if err != nil || !v.AtLeast(7, 0, 0) {
+		t.Fatalf("Version() = %s, %v; want 7.0 or newer", v, err)
+	}
+
+	vm := &Machine{Name: "test", OSType: "Ubuntu_64"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateMachine(ctx, &Machine{Name: "test"}); !errors.Is(err, ErrMachineExist) {
+		t.Errorf("CreateMachine() duplicate = %v; want %v", err, ErrMachineExist)
+	}
+
+	vm.CPUs = 2
+	vm.Memory = 2048
+	vm.VRAM = 16
+	vm.Firmware = "EFI"
+	vm.NICs = []NIC{{Network: NICNetNAT, Hardware: VirtIO}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if vm.CPUs != 2 || vm.Memory != 2048 || vm.Firmware != "EFI" ||
+		len(vm.NICs) != 1 || vm.NICs[0].Hardware != VirtIO || vm.NICs[0].MacAddr == "" {
+		t.Errorf("ModifyMachine() machine = %+v; want the new settings", vm)
+	}
+
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ModifyMachine(ctx, vm); err == nil {
+		t.Error("ModifyMachine() of a running machine succeeded")
+	}
+	var ee *exec.ExitError
+	if err := m.DeleteMachine(ctx, "test"); !errors.As(err, &ee) || ee.ExitCode() != 1 {
+		t.Errorf("DeleteMachine() of a running machine = %v; want exit status 1", err)
+	}
+
+	rule := PFRule{Name: "ssh", Proto: PFTCP, HostPort: 2222, GuestPort: 22}
+	for _, want := range []uint16{2222, 2223} {
+		port, err := m.AllocateNATPF(ctx, "test", 1, rule, PortRange{Min: 2222, Max: 2299})
+		if err != nil || port != want {
+			t.Errorf("AllocateNATPF() = %d, %v; want %d", port, err, want)
+		}
+		rule.Name = "ssh2"
+	}
+
+	vms, err := m.ListMachines(ctx)
+	if err != nil || len(vms) != 1 || vms[0].State != Running {
+		t.Errorf("ListMachines() = %v, %v; want one running machine", vms, err)
+	} else if fw := vms[0].NICs[0].Forwarding; len(fw) != 2 || fw[1].HostPort != 2223 {
+		t.Errorf("ListMachines() forwarding = %v; want the allocated ports", fw)
+	}
+
+	if err := m.SetLinkState(ctx, "test", 1, false); err != nil {
+		t.Error(err)
+	}
+	if err := m.SetNIC(ctx, "test", 1, NIC{Network: NICNetInternal, HostInterface: "lab"}); err != nil {
+		t.Error(err)
+	}
+	if got, err := m.Machine(ctx, "test"); err != nil || got.NICs[0].Network != NICNetInternal || got.NICs[0].HostInterface != "lab" {
+		t.Errorf("Machine() after SetNIC() = %+v, %v; want attached to the lab network", got, err)
+	}
+
+	// A manager with another home does not see the machines.
+	other := NewManager(VBoxManagePath(bin), UserHome(t.TempDir()))
+	if vms, err := other.ListMachines(ctx); err != nil || len(vms) != 0 {
+		t.Errorf("ListMachines() in another home = %v, %v; want none", vms, err)
+	}
+	if _, err := other.Machine(ctx, "test"); !errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("Machine() in another home = %v; want %v", err, ErrMachineNotExist)
+	}
+}
+
+func TestFakeVBoxManageHostonlyNets(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	n, err := m.CreateHostonlyNet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.IPv4 = net.IPNet{IP: net.IPv4(192, 168, 99, 1), Mask: net.CIDRMask(24, 32)}
+	if err := m.ConfigHostonlyNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.HostonlyNet(ctx, n.Name)
+	if err != nil || !got.IPv4.IP.Equal(n.IPv4.IP) {
+		t.Errorf("HostonlyNet() = %+v, %v; want %s", got, err, n.IPv4.IP)
+	}
+
+	if err := m.RemoveHostonlyNet(ctx, n.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HostonlyNet(ctx, n.Name); !errors.Is(err, ErrHostonlyInterfaceNotExist) {
+		t.Errorf("HostonlyNet() after remove = %v; want %v", err, ErrHostonlyInterfaceNotExist)
+	}
+	if err := m.RemoveHostonlyNet(ctx, n.Name); !errors.Is(err, ErrHostonlyInterfaceNotExist) {
+		t.Errorf("RemoveHostonlyNet() after remove = %v; want %v", err, ErrHostonlyInterfaceNotExist)
+	}
+}
+
+func TestFakeVBoxManageHostonlyNetworks(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	n := &HostonlyNetwork{
+		Name:    "HostNetwork",
+		Enabled: true,
+		Netmask: net.CIDRMask(24, 32),
+		LowerIP: net.IPv4(192, 168, 56, 2),
+		UpperIP: net.IPv4(192, 168, 56, 199),
+	}
+	if err := m.AddHostonlyNetwork(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddHostonlyNetwork(ctx, n); !errors.Is(err, ErrHostonlyNetworkExist) {
+		t.Errorf("AddHostonlyNetwork() duplicate = %v; want %v", err, ErrHostonlyNetworkExist)
+	}
+
+	vm := &Machine{Name: "test"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	vm.NICs = []NIC{{Network: NICNetHostonlyNet, Hardware: VirtIO, HostInterface: n.Name}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.NICs) != 1 || vm.NICs[0].Network != NICNetHostonlyNet || vm.NICs[0].HostInterface != n.Name {
+		t.Errorf("ModifyMachine() NICs = %+v; want attached to %s", vm.NICs, n.Name)
+	}
+
+	if err := m.RemoveHostonlyNetwork(ctx, n.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HostonlyNetwork(ctx, n.Name); !errors.Is(err, ErrHostonlyNetworkNotExist) {
+		t.Errorf("HostonlyNetwork() after remove = %v; want %v", err, ErrHostonlyNetworkNotExist)
+	}
+}
+
+func TestFakeVBoxManageNATNets(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	n := NATNet{
+		Name:    "NatNetwork",
+		IPv4:    net.IPNet{IP: net.IPv4(10, 0, 2, 0), Mask: net.CIDRMask(24, 32)},
+		DHCP:    true,
+		Enabled: true,
+		PortForward4: []PFRule{
+			{Name: "ssh", Proto: PFTCP, HostPort: 2222, GuestIP: net.IPv4(10, 0, 2, 15), GuestPort: 22},
+		},
+		Loopback4: []NATLoopback{{IP: net.IPv4(127, 0, 0, 1), Offset: 2}},
+	}
+	if err := m.AddNATNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddNATNet(ctx, n); !errors.Is(err, ErrNATNetExist) {
+		t.Errorf("AddNATNet() duplicate = %v; want %v", err, ErrNATNetExist)
+	}
+
+	n.PortForward4[0].HostPort = 2200
+	n.Loopback4 = nil
+	if err := m.ModifyNATNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.NATNet(ctx, n.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IPv4.IP.Equal(net.IPv4(10, 0, 2, 1)) || !got.DHCP || len(got.Loopback4) != 0 ||
+		len(got.PortForward4) != 1 || got.PortForward4[0].HostPort != 2200 {
+		t.Errorf("NATNet() = %+v; want the modified network", got)
+	}
+	if err := m.StartNATNet(ctx, n.Name); err != nil {
+		t.Error(err)
+	}
+
+	vm := &Machine{Name: "test"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	vm.NICs = []NIC{{Network: NICNetNATNetwork, Hardware: VirtIO, HostInterface: n.Name}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.NICs) != 1 || vm.NICs[0].Network != NICNetNATNetwork || vm.NICs[0].HostInterface != n.Name {
+		t.Errorf("ModifyMachine() NICs = %+v; want attached to %s", vm.NICs, n.Name)
+	}
+
+	if err := m.RemoveNATNet(ctx, n.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopNATNet(ctx, n.Name); !errors.Is(err, ErrNATNetNotExist) {
+		t.Errorf("StopNATNet() after remove = %v; want %v", err, ErrNATNetNotExist)
+	}
+}
+
+func TestFakeVBoxManageDHCPs(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+	const network = "HostInterfaceNetworking-vboxnet0"
+
+	d := DHCP{
+		NetworkName: network,
+		IPv4:        net.IPNet{IP: net.IPv4(192, 168, 56, 100), Mask: net.CIDRMask(24, 32)},
+		LowerIP:     net.IPv4(192, 168, 56, 101),
+		UpperIP:     net.IPv4(192, 168, 56, 254),
+		Enabled:     true,
+		Options:     DHCPOptions{DNSServers: []net.IP{net.IPv4(8, 8, 8, 8)}},
+	}
+	if err := m.AddDHCP(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDHCP(ctx, d); !errors.Is(err, ErrDHCPExist) {
+		t.Errorf("AddDHCP() duplicate = %v; want %v", err, ErrDHCPExist)
+	}
+	dhcps, err := m.DHCPs(ctx)
+	if err != nil || dhcps[network] == nil || !dhcps[network].UpperIP.Equal(d.UpperIP) {
+		t.Errorf("DHCPs() = %v, %v; want %s", dhcps, err, network)
+	}
+	if err := m.RestartDHCP(ctx, network); err != nil {
+		t.Error(err)
+	}
+	if _, err := m.FindLease(ctx, network, "080027000001"); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("FindLease() = %v; want %v", err, ErrLeaseNotFound)
+	}
+	if err := m.RemoveDHCP(ctx, network); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopDHCP(ctx, network); !errors.Is(err, ErrDHCPNotExist) {
+		t.Errorf("StopDHCP() after remove = %v; want %v", err, ErrDHCPNotExist)
+	}
+}
+
+func TestFakeVBoxManageCaptureNIC(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	vm := &Machine{Name: "test"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	vm.NICs = []NIC{{Network: NICNetNAT, Hardware: VirtIO}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	c, err := m.CaptureNIC(cctx, "test", 1, filepath.Join(t.TempDir(), "nic1.pcap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	pr, err := c.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	rec, err := pr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ethernet, IPv4 and UDP headers, then the BOOTP fields and the cookie.
+	const udp, options = 14 + 20, 14 + 20 + 8 + 240
+	if len(rec.Data) < options+3 || rec.Data[udp+3] != 67 || rec.Data[options] != 53 || rec.Data[options+2] != 1 {
+		t.Errorf("Next() = %x; want a DHCPDISCOVER", rec.Data)
+	}
+}
+
+func TestFakeVBoxManageBandwidthGroups(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	vm := &Machine{Name: "test"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	limit, _ := ParseBandwidthLimit("10m")
+	if err := m.AddBandwidthGroup(ctx, "test", BandwidthGroup{Name: "slow", Type: BandwidthNetwork, Limit: limit}); err != nil {
+		t.Fatal(err)
+	}
+	vm.NICs = []NIC{{Network: NICNetNAT, Hardware: VirtIO, BandwidthGroup: "slow"}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveBandwidthGroup(ctx, "test", "slow"); err == nil {
+		t.Error("RemoveBandwidthGroup() of a group in use succeeded")
+	}
+	if err := m.SetBandwidthLimit(ctx, "test", "slow", 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := m.BandwidthGroups(ctx, "test")
+	want := BandwidthGroup{Name: "slow", Type: BandwidthNetwork, Limit: 1 << 30}
+	if err != nil || len(groups) != 1 || groups["slow"] != want {
+		t.Errorf("BandwidthGroups() = %v, %v; want %v", groups, err, want)
+	}
+	if err := m.SetBandwidthLimit(ctx, "test", "fast", 0); !errors.Is(err, ErrBandwidthGroupNotExist) {
+		t.Errorf("SetBandwidthLimit() = %v; want %v", err, ErrBandwidthGroupNotExist)
+	}
+}
+
+func TestFakeVBoxManageExec(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	if err := m.CreateMachine(ctx, &Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	creds := GuestCredentials{Username: "vagrant", Password: "vagrant"}
+	run := func(cmd GuestCommand) (string, int, error) {
+		p, err := m.Exec(ctx, "test", creds, cmd)
+		if err != nil {
+			return "", 0, err
+		}
+		stdout, err := io.ReadAll(p.Stdout)
+		if err != nil {
+			return "", 0, err
+		}
+		if _, err := io.Copy(io.Discard, p.Stderr); err != nil {
+			return "", 0, err
+		}
+		code, err := p.Wait()
+		return string(stdout), code, err
+	}
+
+	if _, _, err := run(GuestCommand{Exe: "/bin/true"}); !errors.Is(err, ErrGuestControl) {
+		t.Errorf("Exec() in a stopped machine = %v; want %v", err, ErrGuestControl)
+	}
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if out, code, err := run(GuestCommand{Exe: "/bin/echo", Args: []string{"hello", "world"}}); err != nil || code != 0 || out != "hello world\n" {
+		t.Errorf("Exec(echo) = %q, %d, %v; want %q", out, code, err, "hello world\n")
+	}
+	if _, code, err := run(GuestCommand{Exe: "/bin/false"}); err != nil || code != 1 {
+		t.Errorf("Exec(false) = %d, %v; want exit code 1", code, err)
+	}
+	out, _, err := run(GuestCommand{Exe: "/usr/bin/env", Env: []string{"LANG=C", "HOME"}})
+	if err != nil || !strings.Contains(out, "LANG=C\n") || strings.Contains(out, "HOME=") {
+		t.Errorf("Exec(env) = %q, %v; want LANG set and HOME unset", out, err)
+	}
+}
+
+func TestFakeVBoxManageGuestFiles(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	if err := m.CreateMachine(ctx, &Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	creds := GuestCredentials{Username: "vagrant", Password: "vagrant"}
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "hello.txt"), []byte("hello\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := m.GuestMkTemp(ctx, "test", creds, GuestTemp{Template: "provXXXX", Directory: true})
+	if err != nil || !strings.HasPrefix(dir, "/tmp/prov") || strings.Contains(dir, "X") {
+		t.Fatalf("GuestMkTemp() = %q, %v", dir, err)
+	}
+	if err := m.CopyToGuest(ctx, "test", creds, GuestCopyOptions{Recursive: true}, dir, src); err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.GuestStat(ctx, "test", creds, dir+"/src/sub/hello.txt")
+	if err != nil || f.Type != GuestFileRegular || f.Size != 6 || f.Perm != 0o640 {
+		t.Errorf("GuestStat() = %+v, %v; want a file of 6 bytes", f, err)
+	}
+	if err := m.GuestMkdir(ctx, "test", creds, dir+"/a/b", 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GuestMove(ctx, "test", creds, dir+"/a/b", dir+"/src/sub/hello.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := m.SnapshotGuestDir(ctx, "test", creds, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	if b, err := fs.ReadFile(snap, "a/b/hello.txt"); err != nil || string(b) != "hello\n" {
+		t.Errorf("ReadFile() = %q, %v", b, err)
+	}
+	if err := fstest.TestFS(snap, "a/b/hello.txt", "src/sub"); err != nil {
+		t.Error(err)
+	}
+
+	if err := m.GuestRemove(ctx, "test", creds, false, dir+"/a/b/hello.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GuestRmdir(ctx, "test", creds, dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GuestStat(ctx, "test", creds, dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GuestStat() of a removed directory = %v; want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestFakeVBoxManageGuestAdditions(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	if err := m.CreateMachine(ctx, &Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if ga, err := m.GuestAdditions(ctx, "test"); err != nil || ga.RunLevel != GuestAdditionsNone {
+		t.Errorf("GuestAdditions() of a stopped machine = %+v, %v", ga, err)
+	}
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	ga, err := m.WaitForGuestAdditions(ctx, "test", GuestAdditionsSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ga.Version == "" || len(ga.Facilities) == 0 || ga.Facilities[0].Status != FacilityActive {
+		t.Errorf("WaitForGuestAdditions() = %+v; want active facilities", ga)
+	}
+	opts := UpdateGuestAdditionsOptions{Source: filepath.Join(t.TempDir(), "missing.iso")}
+	if err := m.UpdateGuestAdditions(ctx, "test", opts); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("UpdateGuestAdditions() with a missing ISO = %v; want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestFakeVBoxManageWaitForIP(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	vm := &Machine{Name: "test"}
+	if err := m.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	vm.NICs = []NIC{{Network: NICNetNAT, Hardware: VirtIO}, {Network: NICNetInternal, Hardware: VirtIO, HostInterface: "intnet"}}
+	if err := m.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if ip, err := m.WaitForIP(ctx, "test", 1); err != nil || !ip.Equal(net.IPv4(10, 0, 2, 15)) {
+		t.Errorf("WaitForIP(1) = %v, %v; want 10.0.2.15", ip, err)
+	}
+	if ip, err := m.WaitForIP(ctx, "test", 2); err != nil || !ip.Equal(net.IPv4(192, 168, 56, 102)) {
+		t.Errorf("WaitForIP(2) = %v, %v; want 192.168.56.102", ip, err)
+	}
+	if err := m.SetLinkState(ctx, "test", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	nets, err := m.GuestNetworks(ctx, "test")
+	if err != nil || len(nets) != 2 || nets[1].Slot != 2 || nets[1].Up {
+		t.Errorf("GuestNetworks() = %+v, %v; want nic2 down", nets, err)
+	}
+}
+
+func TestFakeVBoxManageGuestProperties(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(VBoxManagePath(buildFakeVBoxManage(t)), UserHome(t.TempDir()))
+
+	if err := m.CreateMachine(ctx, &Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetGuestProperty(ctx, "test", "/Custom/Role", "web", GuestPropTransReset, GuestPropReadOnlyGuest); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetGuestProperty(ctx, "test", "/Other", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetGuestProperty(ctx, "test", "/Bad", "x", "NOSUCHFLAG"); err == nil {
+		t.Error("SetGuestProperty() with an invalid flag succeeded")
+	}
+	props, err := m.EnumerateGuestProperties(ctx, "test", "/Custom/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 1 || props[0].Name != "/Custom/Role" || props[0].Value != "web" ||
+		!props[0].HasFlag(GuestPropTransReset) || !props[0].HasFlag(GuestPropReadOnlyGuest) ||
+		time.Since(props[0].Timestamp) > time.Hour {
+		t.Errorf("EnumerateGuestProperties() = %+v", props)
+	}
+	if v, err := m.GuestProperty(ctx, "test", "/Other"); err != nil || v != "x" {
+		t.Errorf("GuestProperty() = %q, %v", v, err)
+	}
+}
@@ -0,0 +1,231 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GuestAdditionsRunLevel is the run level of the Guest Additions, each level
+// includes the lower ones.
+type GuestAdditionsRunLevel int
+
+const (
+	// GuestAdditionsNone means the Guest Additions are not running.
+	GuestAdditionsNone GuestAdditionsRunLevel = iota
+	// GuestAdditionsSystem means the drivers and the service are running, so
+	// the guest properties and the guest control are available.
+	GuestAdditionsSystem
+	// GuestAdditionsUserland means a user is logged in.
+	GuestAdditionsUserland
+	// GuestAdditionsDesktop means the desktop integration is running.
+	GuestAdditionsDesktop
+)
+
+func (l GuestAdditionsRunLevel) String() string {
+	switch l {
+	case GuestAdditionsNone:
+		return "none"
+	case GuestAdditionsSystem:
+		return "system"
+	case GuestAdditionsUserland:
+		return "userland"
+	case GuestAdditionsDesktop:
+		return "desktop"
+	}
+	return strconv.Itoa(int(l))
+}
+
+// FacilityStatus is the status of a Guest Additions facility.
+type FacilityStatus int
+
+// The statuses of the facilities, see AdditionsFacilityStatus in the
+// VirtualBox SDK.
+const (
+	FacilityInactive    FacilityStatus = 0
+	FacilityPaused      FacilityStatus = 1
+	FacilityPreInit     FacilityStatus = 20
+	FacilityInit        FacilityStatus = 30
+	FacilityActive      FacilityStatus = 50
+	FacilityTerminating FacilityStatus = 100
+	FacilityTerminated  FacilityStatus = 101
+	FacilityFailed      FacilityStatus = 800
+	FacilityUnknown     FacilityStatus = 999
+)
+
+func (s FacilityStatus) String() string {
+	switch s {
+	case FacilityInactive:
+		return "inactive"
+	case FacilityPaused:
+		return "paused"
+	case FacilityPreInit:
+		return "pre-init"
+	case FacilityInit:
+		return "init"
+	case FacilityActive:
+		return "active"
+	case FacilityTerminating:
+		return "terminating"
+	case FacilityTerminated:
+		return "terminated"
+	case FacilityFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// GuestFacility is a component of the Guest Additions, e.g. the VirtualBox
+// Base Driver or the Seamless Mode.
+type GuestFacility struct {
+	Name   string
+	Status FacilityStatus
+	Since  time.Time // the time of the last status change
+}
+
+// GuestAdditions is the status of the Guest Additions of a machine.
+type GuestAdditions struct {
+	Version    string // e.g. 7.0.10, empty when they are not installed
+	Revision   string
+	RunLevel   GuestAdditionsRunLevel
+	Facilities []GuestFacility
+}
+
+// GuestAdditions returns the status of the Guest Additions of the machine,
+// from showvminfo and, for the version of older VirtualBox releases, from the
+// /VirtualBox/GuestAdd guest properties.
+func (m *Manager) GuestAdditions(ctx context.Context, id string) (*GuestAdditions, error) {
+	stdout, stderr, err := m.runMachine(ctx, id, "showvminfo", id, "--machinereadable")
+	if err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return nil, ErrMachineNotExist
+		}
+		return nil, fmt.Errorf("unable to get the guest additions of %q: %w", id, err)
+	}
+	ga, err := parseGuestAdditions(stdout)
+	if err != nil {
+		return nil, err
+	}
+	if ga.Version == "" && ga.RunLevel > GuestAdditionsNone {
+		if ga.Version, err = m.guestAddProperty(ctx, id, "Version"); err != nil {
+			return nil, err
+		}
+		if ga.Revision, err = m.guestAddProperty(ctx, id, "Revision"); err != nil {
+			return nil, err
+		}
+	}
+	return ga, nil
+}
+
+// guestAddProperty returns the /VirtualBox/GuestAdd property, which is empty
+// when it is not set.
+func (m *Manager) guestAddProperty(ctx context.Context, id, name string) (string, error) {
+	v, err := m.GuestProperty(ctx, id, "/VirtualBox/GuestAdd/"+name)
+	if errors.Is(err, ErrGuestPropertyNotSet) {
+		return "", nil
+	}
+	return v, err
+}
+
+// parseGuestAdditions parses the Guest Additions keys of the machinereadable
+// output of showvminfo, e.g.:
+//
+//	GuestAdditionsRunLevel=2
+//	GuestAdditionsVersion="7.0.10 r158379"
+//	GuestAdditionsFacility_VirtualBox Base Driver=50,1691752335880
+func parseGuestAdditions(out string) (*GuestAdditions, error) {
+	ga := &GuestAdditions{}
+	for _, l := range parseMachineReadable(out) {
+		switch {
+		case l.key == "GuestAdditionsRunLevel":
+			n, err := strconv.Atoi(l.val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid guest additions run level %q: %w", l.val, err)
+			}
+			ga.RunLevel = GuestAdditionsRunLevel(n)
+		case l.key == "GuestAdditionsVersion":
+			version, rev, _ := strings.Cut(l.val, " ")
+			ga.Version, ga.Revision = version, strings.TrimPrefix(rev, "r")
+		case strings.HasPrefix(l.key, "GuestAdditionsFacility_"):
+			f := GuestFacility{Name: strings.TrimPrefix(l.key, "GuestAdditionsFacility_")}
+			status, since, _ := strings.Cut(l.val, ",")
+			n, err := strconv.Atoi(status)
+			if err != nil {
+				return nil, fmt.Errorf("invalid status of facility %q: %w", f.Name, err)
+			}
+			f.Status = FacilityStatus(n)
+			if ms, err := strconv.ParseInt(since, 10, 64); err == nil && ms > 0 {
+				f.Since = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+			}
+			ga.Facilities = append(ga.Facilities, f)
+		}
+	}
+	sort.Slice(ga.Facilities, func(i, j int) bool {
+		return ga.Facilities[i].Name < ga.Facilities[j].Name
+	})
+	return ga, nil
+}
+
+// UpdateGuestAdditionsOptions are the options of UpdateGuestAdditions.
+type UpdateGuestAdditionsOptions struct {
+	// Source is the path of the Guest Additions ISO on the host, the one
+	// shipped with VirtualBox is used when it is empty.
+	Source string
+	// WaitStart only waits for the installer to start instead of to finish.
+	WaitStart bool
+	// Reboot reboots the guest when the update requires it.
+	Reboot bool
+	// Args are passed to the installer in the guest.
+	Args []string
+}
+
+// UpdateGuestAdditions updates the Guest Additions of the running machine,
+// which must already run Guest Additions with the guest control.
+func (m *Manager) UpdateGuestAdditions(ctx context.Context, vm string, opts UpdateGuestAdditionsOptions) error {
+	m.log.Printf("updating the guest additions of %q", vm)
+	var args []string
+	if opts.Source != "" {
+		args = append(args, "--source", opts.Source)
+	}
+	if opts.WaitStart {
+		args = append(args, "--wait-start")
+	}
+	if opts.Reboot {
+		args = append(args, "--reboot")
+	}
+	if len(opts.Args) > 0 {
+		args = append(append(args, "--"), opts.Args...)
+	}
+	_, err := m.guestcontrol(ctx, vm, GuestCredentials{}, "updatega", args...)
+	return err
+}
+
+// guestPollInterval is the interval of polling the status of the guest.
+var guestPollInterval = 2 * time.Second
+
+// WaitForGuestAdditions waits until the Guest Additions of the machine reach
+// the run level, e.g. GuestAdditionsSystem before using the guest control, and
+// returns their status.
+func (m *Manager) WaitForGuestAdditions(ctx context.Context, id string, level GuestAdditionsRunLevel) (*GuestAdditions, error) {
+	m.log.Printf("waiting for the guest additions of %q to reach run level %s", id, level)
+	t := time.NewTicker(guestPollInterval)
+	defer t.Stop()
+	for {
+		ga, err := m.GuestAdditions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ga.RunLevel >= level {
+			return ga, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("guest additions of %q at run level %s: %w", id, ga.RunLevel, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package virtualbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseGuestAdditions(t *testing.T) {
+	got, err := parseGuestAdditions(`name="Ubuntu"
+VMState="running"
+GuestMemoryBalloon=0
+GuestOSType="Linux26_64"
+GuestAdditionsRunLevel=2
+GuestAdditionsVersion="7.0.10 r158379"
+GuestAdditionsFacility_VirtualBox Base Driver=50,1691752335880
+GuestAdditionsFacility_Seamless Mode=0,1691752335880
+GuestAdditionsFacility_Graphics Mode=0,1691752335879
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2023, 8, 11, 11, 12, 15, 880000000, time.UTC)
+	want := &GuestAdditions{
+		Version:  "7.0.10",
+		Revision: "158379",
+		RunLevel: GuestAdditionsUserland,
+		Facilities: []GuestFacility{
+			{Name: "Graphics Mode", Status: FacilityInactive, Since: since.Add(-time.Millisecond)},
+			{Name: "Seamless Mode", Status: FacilityInactive, Since: since},
+			{Name: "VirtualBox Base Driver", Status: FacilityActive, Since: since},
+		},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("parseGuestAdditions() = %+v; diff = %v", got, diff)
+	}
+	if _, err := parseGuestAdditions("GuestAdditionsRunLevel=high\n"); err == nil {
+		t.Error("parseGuestAdditions() of an invalid run level succeeded")
+	}
+}
+
+func TestManagerGuestAdditionsProperties(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"showvminfo", "Ubuntu", "--machinereadable"}, Stdout: "GuestAdditionsRunLevel=1\n"},
+		Call{Args: []string{"guestproperty", "get", "Ubuntu", "/VirtualBox/GuestAdd/Version"}, Stdout: "Value: 6.1.46\n"},
+		Call{Args: []string{"guestproperty", "get", "Ubuntu", "/VirtualBox/GuestAdd/Revision"}, Stdout: "No value set!\n"},
+	)
+	m := NewManager(Replay(r))
+	ga, err := m.GuestAdditions(context.Background(), "Ubuntu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ga.Version != "6.1.46" || ga.Revision != "" || ga.RunLevel != GuestAdditionsSystem {
+		t.Errorf("GuestAdditions() = %+v; want 6.1.46 at the system run level", ga)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerUpdateGuestAdditions(t *testing.T) {
+	r := NewReplayer(Call{Args: []string{"guestcontrol", "Ubuntu", "updatega",
+		"--source", "/isos/VBoxGuestAdditions.iso", "--reboot", "--", "--nox11"}})
+	m := NewManager(Replay(r))
+	err := m.UpdateGuestAdditions(context.Background(), "Ubuntu", UpdateGuestAdditionsOptions{
+		Source: "/isos/VBoxGuestAdditions.iso",
+		Reboot: true,
+		Args:   []string{"--nox11"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWaitForGuestAdditions(t *testing.T) {
+	defer func(d time.Duration) { guestPollInterval = d }(guestPollInterval)
+	guestPollInterval = time.Millisecond
+
+	showvminfo := []string{"showvminfo", "Ubuntu", "--machinereadable"}
+	r := NewReplayer(
+		Call{Args: showvminfo, Stdout: "GuestAdditionsRunLevel=0\n"},
+		Call{Args: showvminfo, Stdout: "GuestAdditionsRunLevel=1\nGuestAdditionsVersion=\"7.0.10 r158379\"\n"},
+		Call{Args: showvminfo, Stdout: "GuestAdditionsRunLevel=2\nGuestAdditionsVersion=\"7.0.10 r158379\"\n"},
+	)
+	m := NewManager(Replay(r))
+	ga, err := m.WaitForGuestAdditions(context.Background(), "Ubuntu", GuestAdditionsUserland)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ga.RunLevel != GuestAdditionsUserland || ga.Version != "7.0.10" {
+		t.Errorf("WaitForGuestAdditions() = %+v", ga)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,223 @@
+package virtualbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotHost is returned for the operations which are only available on
+	// the host, e.g. the guest control, when running in the guest.
+	ErrNotHost = errors.New("only available on the host")
+	// ErrGuestControl is returned when VBoxManage could not run the guest
+	// process, e.g. because of invalid credentials or missing Guest Additions.
+	ErrGuestControl = errors.New("guest control failed")
+)
+
+// errNoStream is returned by the streamFn which can not stream the output, the
+// command is run with the runFn instead.
+var errNoStream = errors.New("streaming not supported")
+
+// GuestCredentials are the credentials of the guest user the guest control
+// operations run as. The password is visible in the process list of the host,
+// so PasswordFile should be preferred on shared hosts.
+type GuestCredentials struct {
+	Username     string
+	Password     string
+	PasswordFile string
+	Domain       string
+}
+
+// args returns the flags of the credentials.
+func (c GuestCredentials) args() []string {
+	var args []string
+	if c.Username != "" {
+		args = append(args, "--username", c.Username)
+	}
+	if c.PasswordFile != "" {
+		args = append(args, "--passwordfile", c.PasswordFile)
+	} else if c.Password != "" {
+		args = append(args, "--password", c.Password)
+	}
+	if c.Domain != "" {
+		args = append(args, "--domain", c.Domain)
+	}
+	return args
+}
+
+// GuestCommand is a process to run in the guest.
+type GuestCommand struct {
+	Exe  string   // the absolute path of the executable in the guest
+	Args []string // the arguments, without the executable
+	// Env changes the environment of the guest user, NAME=VALUE sets and
+	// NAME unsets the variable.
+	Env []string
+	// Timeout kills the guest process when it runs for longer, there is no
+	// limit when it is zero.
+	Timeout time.Duration
+	// IgnoreStdout and IgnoreStderr do not wait for, nor stream the output.
+	IgnoreStdout bool
+	IgnoreStderr bool
+}
+
+// args returns the arguments of 'guestcontrol run' following the machine.
+func (c GuestCommand) args() []string {
+	args := []string{"--exe", c.Exe}
+	if c.Timeout > 0 {
+		args = append(args, "--timeout", strconv.FormatInt(c.Timeout.Milliseconds(), 10))
+	}
+	for _, e := range c.Env {
+		args = append(args, "--putenv", e)
+	}
+	if c.IgnoreStdout {
+		args = append(args, "--no-wait-stdout")
+	} else {
+		args = append(args, "--wait-stdout")
+	}
+	if c.IgnoreStderr {
+		args = append(args, "--no-wait-stderr")
+	} else {
+		args = append(args, "--wait-stderr")
+	}
+	args = append(args, "--", c.Exe)
+	return append(args, c.Args...)
+}
+
+// GuestExec is a process running in the guest, started by Exec.
+type GuestExec struct {
+	// Stdout and Stderr stream the output of the guest process, they must be
+	// read until EOF before calling Wait.
+	Stdout io.Reader
+	Stderr io.Reader
+
+	errs *vboxErrors
+	wait func() error
+}
+
+// vboxErrors keeps the error messages of VBoxManage from the stderr, while
+// it is read by the caller.
+type vboxErrors struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	msgs []string
+}
+
+func (e *vboxErrors) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.buf.Write(p)
+	for {
+		i := bytes.IndexByte(e.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(e.buf.Next(i+1)), "\r\n")
+		if strings.HasPrefix(line, "VBoxManage: error: ") {
+			e.msgs = append(e.msgs, strings.TrimPrefix(line, "VBoxManage: error: "))
+		}
+	}
+	// A line without a new line is kept up to a limit, the guest can write
+	// anything to the stderr.
+	if e.buf.Len() > 64<<10 {
+		e.buf.Reset()
+	}
+	return len(p), nil
+}
+
+func (e *vboxErrors) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.Join(e.msgs, ": ")
+}
+
+// Wait waits for the guest process to exit and returns its exit code. An
+// error is only returned when VBoxManage failed to run the process, VBoxManage
+// itself exits with the exit code of the guest process.
+func (p *GuestExec) Wait() (int, error) {
+	err := p.wait()
+	if err == nil {
+		return 0, nil
+	}
+	if msg := p.errs.String(); msg != "" {
+		return 0, fmt.Errorf("%w: %s", ErrGuestControl, msg)
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode(), nil
+	}
+	var re *ExitError
+	if errors.As(err, &re) {
+		return re.Code, nil
+	}
+	return 0, err
+}
+
+// Exec runs the command in the running machine through the Guest Additions
+// with 'guestcontrol run', and streams its output. The timeout of the manager
+// does not apply, the command runs until it exits or the context is done.
+func (m *Manager) Exec(ctx context.Context, vm string, creds GuestCredentials, cmd GuestCommand) (*GuestExec, error) {
+	if cmd.Exe == "" {
+		return nil, fmt.Errorf("guest executable is empty")
+	}
+	m.log.Printf("running %q in %q", cmd.Exe, vm)
+	args := append([]string{"guestcontrol", vm, "run"}, creds.args()...)
+	args = append(args, cmd.args()...)
+
+	p, err := m.stream(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to run %q in %q: %w", cmd.Exe, vm, err)
+	}
+	errs := &vboxErrors{}
+	return &GuestExec{
+		Stdout: p.stdout,
+		Stderr: io.TeeReader(p.stderr, errs),
+		errs:   errs,
+		wait:   p.wait,
+	}, nil
+}
+
+// stream starts the command with its output streamed, or runs it to the end
+// and serves the buffered output when the manager can not stream it. The
+// streamed command holds a slot of the concurrency limit until it is waited
+// for, but the timeout of the manager does not apply.
+func (m *Manager) stream(ctx context.Context, args ...string) (*process, error) {
+	if m.streamer != nil {
+		select {
+		case m.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		release := func() { once.Do(func() { <-m.sem }) }
+		p, err := m.streamer(ctx, args...)
+		if err == nil {
+			wait := p.wait
+			p.wait = func() error {
+				defer release()
+				return wait()
+			}
+			return p, nil
+		}
+		release()
+		if !errors.Is(err, errNoStream) {
+			return nil, err
+		}
+	}
+	stdout, stderr, err := m.run(ctx, args...)
+	if errors.Is(err, ErrCommandNotFound) {
+		return nil, err
+	}
+	return &process{
+		stdout: strings.NewReader(stdout),
+		stderr: strings.NewReader(stderr),
+		wait:   func() error { return err },
+	}, nil
+}
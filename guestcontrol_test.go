@@ -0,0 +1,130 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestGuestCommandArgs(t *testing.T) {
+	creds := GuestCredentials{Username: "vagrant", Password: "secret", PasswordFile: "/tmp/pw"}
+	cmd := GuestCommand{
+		Exe:          "/bin/ls",
+		Args:         []string{"-l", "/tmp"},
+		Env:          []string{"LANG=C", "TERM"},
+		Timeout:      1500 * time.Millisecond,
+		IgnoreStderr: true,
+	}
+	got := append(creds.args(), cmd.args()...)
+	want := []string{
+		"--username", "vagrant", "--passwordfile", "/tmp/pw",
+		"--exe", "/bin/ls", "--timeout", "1500", "--putenv", "LANG=C", "--putenv", "TERM",
+		"--wait-stdout", "--no-wait-stderr", "--", "/bin/ls", "-l", "/tmp",
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("args() = %q; diff = %v", got, diff)
+	}
+}
+
+func TestManagerExec(t *testing.T) {
+	tests := map[string]struct {
+		call     Call
+		stdout   string
+		exitCode int
+		err      error
+	}{
+		"success": {
+			call:   Call{Stdout: "hello\n"},
+			stdout: "hello\n",
+		},
+		"exit code": {
+			call:     Call{Stdout: "partial", ExitCode: 3},
+			stdout:   "partial",
+			exitCode: 3,
+		},
+		"failure": {
+			call: Call{
+				Stderr:   "VBoxManage: error: The guest execution service is not ready (yet)\n",
+				ExitCode: 1,
+			},
+			err: ErrGuestControl,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			tc.call.Args = []string{"guestcontrol", "Ubuntu", "run", "--username", "vagrant",
+				"--exe", "/bin/echo", "--wait-stdout", "--wait-stderr", "--", "/bin/echo", "hello"}
+			r := NewReplayer(tc.call)
+			m := NewManager(Replay(r))
+			p, err := m.Exec(context.Background(), "Ubuntu", GuestCredentials{Username: "vagrant"},
+				GuestCommand{Exe: "/bin/echo", Args: []string{"hello"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdout, err := io.ReadAll(p.Stdout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.Copy(io.Discard, p.Stderr); err != nil {
+				t.Fatal(err)
+			}
+			code, err := p.Wait()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Wait() error = %v; want %v", err, tc.err)
+			}
+			if code != tc.exitCode || (err == nil && string(stdout) != tc.stdout) {
+				t.Errorf("Exec() = %q, %d; want %q, %d", stdout, code, tc.stdout, tc.exitCode)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestVBoxErrors(t *testing.T) {
+	var e vboxErrors
+	for _, s := range []string{"guest output\nVBoxManage: error: Invalid ", "user name\nVBoxMan", "age: error: Details: code\n"} {
+		if _, err := e.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := e.String(), "Invalid user name: Details: code"; got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+}
+
+func TestStreamConcurrency(t *testing.T) {
+	m := NewManager(Concurrency(1))
+	m.runner = func(context.Context, ...string) (string, string, error) { return "", "", nil }
+	m.streamer = func(context.Context, ...string) (*process, error) {
+		return &process{
+			stdout: strings.NewReader(""),
+			stderr: strings.NewReader(""),
+			wait:   func() error { return nil },
+		}, nil
+	}
+
+	p, err := m.stream(context.Background(), "guestcontrol", "Ubuntu", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The streamed command holds the only slot until it is waited for.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.run(ctx, "list", "vms"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("run() while streaming = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if err := p.wait(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.run(context.Background(), "list", "vms"); err != nil {
+		t.Errorf("run() after wait = %v", err)
+	}
+}
@@ -0,0 +1,429 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reGuestNotExist matches the errors of the guest control operations on the
+// guest files which do not exist.
+var reGuestNotExist = regexp.MustCompile(`(?i)(does not exist|not found|no such file)`)
+
+// GuestFileType is the type of a file in the guest, as reported by
+// 'guestcontrol stat'.
+type GuestFileType string
+
+const (
+	// GuestFileRegular is a regular file.
+	GuestFileRegular = GuestFileType("file")
+	// GuestFileDirectory is a directory.
+	GuestFileDirectory = GuestFileType("directory")
+	// GuestFileSymlink is a symbolic link.
+	GuestFileSymlink = GuestFileType("symlink")
+	// GuestFileFIFO is a named pipe.
+	GuestFileFIFO = GuestFileType("fifo")
+	// GuestFileSocket is a socket.
+	GuestFileSocket = GuestFileType("socket")
+	// GuestFileCharDevice is a character device.
+	GuestFileCharDevice = GuestFileType("char-device")
+	// GuestFileBlockDevice is a block device.
+	GuestFileBlockDevice = GuestFileType("block-device")
+)
+
+// GuestFile is the metadata of a file in the guest, see FileInfo for the
+// fs.FileInfo view of it.
+type GuestFile struct {
+	Path       string
+	Type       GuestFileType
+	Size       int64
+	Alloc      int64 // the allocated size on the disk
+	Perm       fs.FileMode
+	Inode      uint64
+	Links      int
+	UID        int
+	GID        int
+	Owner      string
+	Group      string
+	AccessTime time.Time
+	ModTime    time.Time
+	ChangeTime time.Time
+	BirthTime  time.Time
+}
+
+// Mode returns the fs.FileMode of the file, with its type bits.
+func (f *GuestFile) Mode() fs.FileMode {
+	mode := f.Perm & fs.ModePerm
+	switch f.Type {
+	case GuestFileDirectory:
+		mode |= fs.ModeDir
+	case GuestFileSymlink:
+		mode |= fs.ModeSymlink
+	case GuestFileFIFO:
+		mode |= fs.ModeNamedPipe
+	case GuestFileSocket:
+		mode |= fs.ModeSocket
+	case GuestFileCharDevice:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case GuestFileBlockDevice:
+		mode |= fs.ModeDevice
+	}
+	return mode
+}
+
+// FileInfo returns the fs.FileInfo of the file, whose Sys method returns the
+// GuestFile.
+func (f *GuestFile) FileInfo() fs.FileInfo {
+	return guestFileInfo{f}
+}
+
+// guestFileInfo is the fs.FileInfo of a GuestFile.
+type guestFileInfo struct {
+	f *GuestFile
+}
+
+func (fi guestFileInfo) Name() string       { return guestBase(fi.f.Path) }
+func (fi guestFileInfo) Size() int64        { return fi.f.Size }
+func (fi guestFileInfo) Mode() fs.FileMode  { return fi.f.Mode() }
+func (fi guestFileInfo) ModTime() time.Time { return fi.f.ModTime }
+func (fi guestFileInfo) IsDir() bool        { return fi.f.Type == GuestFileDirectory }
+func (fi guestFileInfo) Sys() interface{}   { return fi.f }
+
+// guestBase returns the last element of the guest path, which uses slashes or,
+// in Windows guests, backslashes.
+func guestBase(p string) string {
+	p = strings.TrimRight(p, `/\`)
+	if i := strings.LastIndexAny(p, `/\`); i >= 0 {
+		p = p[i+1:]
+	}
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// GuestCopyOptions are the options of CopyToGuest and CopyFromGuest.
+type GuestCopyOptions struct {
+	Recursive      bool // copy the directories with their content
+	FollowSymlinks bool // copy the targets of the symbolic links
+}
+
+// args returns the flags of the options.
+func (o GuestCopyOptions) args() []string {
+	var args []string
+	if o.FollowSymlinks {
+		args = append(args, "--follow")
+	}
+	if o.Recursive {
+		args = append(args, "--recursive")
+	}
+	return args
+}
+
+// guestcontrol runs the guestcontrol subcommand with the credentials in the
+// running machine. The failures of VBoxManage are returned as ErrGuestControl,
+// or fs.ErrNotExist for the files which do not exist.
+func (m *Manager) guestcontrol(ctx context.Context, vm string, creds GuestCredentials, sub string, args ...string) (string, error) {
+	args = append(append([]string{"guestcontrol", vm, sub}, creds.args()...), args...)
+	stdout, stderr, err := m.run(ctx, args...)
+	if err != nil {
+		if errors.Is(err, ErrCommandNotFound) {
+			return "", m.hostOnly(err)
+		}
+		if reMachineNotFound.MatchString(stderr) {
+			return "", ErrMachineNotExist
+		}
+		errs := &vboxErrors{}
+		_, _ = errs.Write([]byte(stderr + "\n"))
+		msg := errs.String()
+		if msg == "" {
+			return "", fmt.Errorf("unable to %s in %q: %w", sub, vm, err)
+		}
+		if reGuestNotExist.MatchString(msg) {
+			return "", fmt.Errorf("%s: %w", msg, fs.ErrNotExist)
+		}
+		return "", fmt.Errorf("%w: %s", ErrGuestControl, msg)
+	}
+	return stdout, nil
+}
+
+// CopyToGuest copies the host files into the guest directory.
+func (m *Manager) CopyToGuest(ctx context.Context, vm string, creds GuestCredentials, opts GuestCopyOptions, guestDir string, hostSrcs ...string) error {
+	if len(hostSrcs) == 0 {
+		return fmt.Errorf("no files to copy")
+	}
+	m.log.Printf("copying %q to %q in %q", hostSrcs, guestDir, vm)
+	args := append(opts.args(), "--target-directory", guestDir)
+	_, err := m.guestcontrol(ctx, vm, creds, "copyto", append(args, hostSrcs...)...)
+	return err
+}
+
+// CopyFromGuest copies the guest files into the host directory.
+func (m *Manager) CopyFromGuest(ctx context.Context, vm string, creds GuestCredentials, opts GuestCopyOptions, hostDir string, guestSrcs ...string) error {
+	if len(guestSrcs) == 0 {
+		return fmt.Errorf("no files to copy")
+	}
+	m.log.Printf("copying %q from %q to %q", guestSrcs, vm, hostDir)
+	args := append(opts.args(), "--target-directory", hostDir)
+	_, err := m.guestcontrol(ctx, vm, creds, "copyfrom", append(args, guestSrcs...)...)
+	return err
+}
+
+// GuestMkdir creates the directory in the guest, and its parents when they
+// do not exist if parents is set. The default mode of the guest is used when
+// the mode is zero.
+func (m *Manager) GuestMkdir(ctx context.Context, vm string, creds GuestCredentials, dir string, mode fs.FileMode, parents bool) error {
+	m.log.Printf("creating directory %q in %q", dir, vm)
+	var args []string
+	if parents {
+		args = append(args, "--parents")
+	}
+	if mode != 0 {
+		args = append(args, "--mode", fmt.Sprintf("%o", mode.Perm()))
+	}
+	_, err := m.guestcontrol(ctx, vm, creds, "mkdir", append(args, dir)...)
+	return err
+}
+
+// GuestRmdir removes the directory from the guest, which must be empty unless
+// recursive is set.
+func (m *Manager) GuestRmdir(ctx context.Context, vm string, creds GuestCredentials, dir string, recursive bool) error {
+	m.log.Printf("removing directory %q from %q", dir, vm)
+	var args []string
+	if recursive {
+		args = append(args, "--recursive")
+	}
+	_, err := m.guestcontrol(ctx, vm, creds, "rmdir", append(args, dir)...)
+	return err
+}
+
+// GuestRemove removes the files from the guest. With force, the files which
+// do not exist are ignored.
+func (m *Manager) GuestRemove(ctx context.Context, vm string, creds GuestCredentials, force bool, files ...string) error {
+	m.log.Printf("removing %q from %q", files, vm)
+	var args []string
+	if force {
+		args = append(args, "--force")
+	}
+	_, err := m.guestcontrol(ctx, vm, creds, "rm", append(args, files...)...)
+	return err
+}
+
+// GuestMove moves the files or directories of the guest to the destination,
+// which must be a directory when there are several sources.
+func (m *Manager) GuestMove(ctx context.Context, vm string, creds GuestCredentials, dst string, srcs ...string) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("no files to move")
+	}
+	m.log.Printf("moving %q to %q in %q", srcs, dst, vm)
+	args := append(append([]string{}, srcs...), dst)
+	_, err := m.guestcontrol(ctx, vm, creds, "mv", args...)
+	return err
+}
+
+// GuestTemp describes the temporary file or directory created by GuestMkTemp.
+type GuestTemp struct {
+	// Template is the name of the file, whose last run of X characters is
+	// replaced with random ones, e.g. "buildXXXXXX".
+	Template  string
+	Dir       string // the parent directory, the temporary one of the guest when empty
+	Directory bool   // create a directory instead of a file
+	Secure    bool   // only the owner may access it
+	Mode      fs.FileMode
+}
+
+// GuestMkTemp creates a temporary file or directory in the guest and returns
+// its path.
+func (m *Manager) GuestMkTemp(ctx context.Context, vm string, creds GuestCredentials, t GuestTemp) (string, error) {
+	if !strings.Contains(t.Template, "XXX") {
+		return "", fmt.Errorf("invalid template %q: it needs at least 3 X", t.Template)
+	}
+	var args []string
+	if t.Directory {
+		args = append(args, "--directory")
+	}
+	if t.Secure {
+		args = append(args, "--secure")
+	}
+	if t.Mode != 0 {
+		args = append(args, "--mode", fmt.Sprintf("%o", t.Mode.Perm()))
+	}
+	if t.Dir != "" {
+		args = append(args, "--tmpdir", t.Dir)
+	}
+	stdout, err := m.guestcontrol(ctx, vm, creds, "mktemp", append(args, t.Template)...)
+	if err != nil {
+		return "", err
+	}
+	// VBoxManage prints "Directory name: <path>" or "File name: <path>".
+	for _, line := range lines(stdout) {
+		if _, name, ok := strings.Cut(line, "name: "); ok {
+			return strings.TrimSpace(name), nil
+		}
+	}
+	return "", fmt.Errorf("unable to parse the output of mktemp: %q", stdout)
+}
+
+// GuestStat returns the metadata of the file in the guest, symbolic links are
+// not followed. A file which does not exist is reported with fs.ErrNotExist.
+// VirtualBox 6.1 and older only report the path and the type of the file.
+func (m *Manager) GuestStat(ctx context.Context, vm string, creds GuestCredentials, path string) (*GuestFile, error) {
+	stdout, err := m.guestcontrol(ctx, vm, creds, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGuestStat(stdout)
+}
+
+var (
+	// reStatField matches the "Key: value" fields of 'guestcontrol stat'.
+	reStatField = regexp.MustCompile(`(\w+): +(\S+)`)
+	// reStatLegacy matches the output of 'guestcontrol stat' before
+	// VirtualBox 7.0, which only reports the type of the file.
+	reStatLegacy = regexp.MustCompile(`^Element "(.+)" found: Is a (file|directory|symlink)$`)
+)
+
+// parseGuestStat parses the output of 'guestcontrol stat' of a file:
+//
+//	  File: '/etc/hosts'
+//	  Size: 221               Alloc: 4096                Type: file
+//	Device: 0x803             INode: 1310745            Links: 1
+//	  Mode: -rw-r--r--        Attrib: ----------
+//	 Owner: 0/root            Group: 0/root
+//	Access: 2023-08-09T10:11:12.123456789Z
+//	Modify: 2023-08-01T08:00:00.000000000Z
+//	Change: 2023-08-01T08:00:00.000000000Z
+//	 Birth: 2023-08-01T08:00:00.000000000Z
+//
+// or of VirtualBox 6.1 and older:
+//
+//	Element "/etc/hosts" found: Is a file
+func parseGuestStat(s string) (*GuestFile, error) {
+	f := &GuestFile{}
+	for _, line := range lines(s) {
+		line = strings.TrimSpace(line)
+		if res := reStatLegacy.FindStringSubmatch(line); res != nil {
+			return &GuestFile{Path: res[1], Type: GuestFileType(res[2])}, nil
+		}
+		if strings.HasPrefix(line, "File: ") {
+			f.Path = strings.Trim(strings.TrimPrefix(line, "File: "), "'")
+			continue
+		}
+		for _, m := range reStatField.FindAllStringSubmatch(line, -1) {
+			if err := f.setStat(m[1], m[2]); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", m[1], m[2], err)
+			}
+		}
+	}
+	if f.Path == "" || f.Type == "" {
+		return nil, fmt.Errorf("unable to parse the output of stat: %q", s)
+	}
+	return f, nil
+}
+
+// setStat sets the field of the file for a key of 'guestcontrol stat'.
+func (f *GuestFile) setStat(key, val string) error {
+	var err error
+	switch key {
+	case "Size":
+		f.Size, err = strconv.ParseInt(val, 10, 64)
+	case "Alloc":
+		f.Alloc, err = strconv.ParseInt(val, 10, 64)
+	case "Type":
+		f.Type = GuestFileType(val)
+	case "INode":
+		f.Inode, err = strconv.ParseUint(val, 10, 64)
+	case "Links":
+		f.Links, err = strconv.Atoi(val)
+	case "Mode":
+		f.Perm = parseGuestPerm(val)
+	case "Owner":
+		f.UID, f.Owner, err = parseGuestOwner(val)
+	case "Group":
+		f.GID, f.Group, err = parseGuestOwner(val)
+	case "Access":
+		f.AccessTime, err = parseGuestTime(val)
+	case "Modify":
+		f.ModTime, err = parseGuestTime(val)
+	case "Change":
+		f.ChangeTime, err = parseGuestTime(val)
+	case "Birth":
+		f.BirthTime, err = parseGuestTime(val)
+	}
+	return err
+}
+
+// parseGuestPerm parses the permissions of a mode like "-rw-r--r--", the
+// characters other than r, w and x are ignored.
+func parseGuestPerm(s string) fs.FileMode {
+	if len(s) < 9 {
+		return 0
+	}
+	var perm fs.FileMode
+	for i, c := range s[len(s)-9:] {
+		if c == 'r' || c == 'w' || c == 'x' || c == 's' || c == 't' {
+			perm |= 1 << (8 - i)
+		}
+	}
+	return perm
+}
+
+// parseGuestOwner parses an owner or a group like "1000/vagrant".
+func parseGuestOwner(s string) (int, string, error) {
+	id, name, _ := strings.Cut(s, "/")
+	n, err := strconv.Atoi(id)
+	return n, name, err
+}
+
+// parseGuestTime parses a timestamp of the guest, which are in UTC with up to
+// nanoseconds.
+func parseGuestTime(s string) (time.Time, error) {
+	if s == "" || strings.HasPrefix(s, "1970-01-01T00:00:00") {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// GuestDir is a read-only snapshot of a guest directory, taken by
+// SnapshotGuestDir. It must be closed to remove the copy of the files.
+type GuestDir struct {
+	fs.FS
+	tmp string
+}
+
+// SnapshotGuestDir copies the guest directory to the host and returns an
+// fs.FS of the copy, e.g. to check the files written by a provisioner in the
+// tests. The changes to the guest directory made afterwards are not seen.
+func (m *Manager) SnapshotGuestDir(ctx context.Context, vm string, creds GuestCredentials, dir string) (*GuestDir, error) {
+	tmp, err := os.MkdirTemp("", "go-virtualbox-guest-")
+	if err != nil {
+		return nil, err
+	}
+	opts := GuestCopyOptions{Recursive: true}
+	if err := m.CopyFromGuest(ctx, vm, creds, opts, tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	root := filepath.Join(tmp, guestBase(dir))
+	fi, err := os.Stat(root)
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("%s is not a directory", dir)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	return &GuestDir{FS: os.DirFS(root), tmp: tmp}, nil
+}
+
+// Close removes the copy of the guest directory.
+func (d *GuestDir) Close() error {
+	return os.RemoveAll(d.tmp)
+}
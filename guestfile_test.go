@@ -0,0 +1,117 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseGuestStat(t *testing.T) {
+	got, err := parseGuestStat(`  File: '/home/vagrant/my file'
+  Size: 221               Alloc: 4096                Type: file
+Device: 0x803             INode: 1310745            Links: 1
+  Mode: -rw-r-----        Attrib: ----------
+ Owner: 1000/vagrant      Group: 1000/vagrant
+Access: 2023-08-09T10:11:12.123456789Z
+Modify: 2023-08-01T08:00:00.5Z
+Change: 2023-08-01T08:00:00Z
+ Birth: 1970-01-01T00:00:00.000000000Z
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Date(2023, 8, 1, 8, 0, 0, 500000000, time.UTC)
+	want := &GuestFile{
+		Path: "/home/vagrant/my file", Type: GuestFileRegular, Size: 221, Alloc: 4096,
+		Perm: 0o640, Inode: 1310745, Links: 1, UID: 1000, GID: 1000, Owner: "vagrant", Group: "vagrant",
+		AccessTime: time.Date(2023, 8, 9, 10, 11, 12, 123456789, time.UTC),
+		ModTime:    mod,
+		ChangeTime: time.Date(2023, 8, 1, 8, 0, 0, 0, time.UTC),
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("parseGuestStat() = %+v; diff = %v", got, diff)
+	}
+	fi := got.FileInfo()
+	if fi.Name() != "my file" || fi.IsDir() || fi.Mode() != 0o640 || !fi.ModTime().Equal(mod) || fi.Sys() != got {
+		t.Errorf("FileInfo() = %v %v %v %v", fi.Name(), fi.IsDir(), fi.Mode(), fi.ModTime())
+	}
+	for in, want := range map[string]*GuestFile{
+		"Element \"/etc/hosts\" found: Is a file\n":  {Path: "/etc/hosts", Type: GuestFileRegular},
+		"Element \"/tmp\" found: Is a directory\r\n": {Path: "/tmp", Type: GuestFileDirectory},
+		"Element \"/bin\" found: Is a symlink\n":     {Path: "/bin", Type: GuestFileSymlink},
+	} {
+		got, err := parseGuestStat(in)
+		if diff := deep.Equal(got, want); err != nil || diff != nil {
+			t.Errorf("parseGuestStat(%q) = %+v, %v; diff = %v", in, got, err, diff)
+		}
+	}
+	if _, err := parseGuestStat("'/tmp' is a directory\n"); err == nil {
+		t.Error("parseGuestStat() of an unknown output succeeded")
+	}
+}
+
+func TestGuestFileMode(t *testing.T) {
+	tests := map[GuestFileType]fs.FileMode{
+		GuestFileRegular:     0o755,
+		GuestFileDirectory:   fs.ModeDir | 0o755,
+		GuestFileSymlink:     fs.ModeSymlink | 0o755,
+		GuestFileCharDevice:  fs.ModeDevice | fs.ModeCharDevice | 0o755,
+		GuestFileBlockDevice: fs.ModeDevice | 0o755,
+	}
+	for typ, want := range tests {
+		f := &GuestFile{Type: typ, Perm: 0o755}
+		if got := f.Mode(); got != want {
+			t.Errorf("Mode() of %s = %v; want %v", typ, got, want)
+		}
+	}
+}
+
+func TestManagerGuestFiles(t *testing.T) {
+	creds := GuestCredentials{Username: "vagrant", PasswordFile: "/tmp/pw"}
+	common := []string{"--username", "vagrant", "--passwordfile", "/tmp/pw"}
+	gc := func(sub string, args ...string) []string {
+		return append(append([]string{"guestcontrol", "Ubuntu", sub}, common...), args...)
+	}
+	r := NewReplayer(
+		Call{Args: gc("copyto", "--follow", "--recursive", "--target-directory", "/opt", "/src/a", "/src/b")},
+		Call{Args: gc("mkdir", "--parents", "--mode", "750", "/opt/x/y")},
+		Call{Args: gc("rm", "--force", "/opt/a")},
+		Call{Args: gc("mv", "/opt/b", "/opt/c", "/opt/x")},
+		Call{Args: gc("mktemp", "--directory", "--tmpdir", "/opt", "buildXXXXXX"), Stdout: "Directory name: /opt/build8wx0ab\n"},
+		Call{Args: gc("stat", "/missing"), Stderr: "VBoxManage: error: File '/missing' does not exist\n", ExitCode: 1},
+		Call{Args: gc("rmdir", "/opt/x"), Stderr: "VBoxManage: error: rmdir failed on '/opt/x': directory not empty\n", ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	opts := GuestCopyOptions{Recursive: true, FollowSymlinks: true}
+	if err := m.CopyToGuest(ctx, "Ubuntu", creds, opts, "/opt", "/src/a", "/src/b"); err != nil {
+		t.Error(err)
+	}
+	if err := m.GuestMkdir(ctx, "Ubuntu", creds, "/opt/x/y", 0o750, true); err != nil {
+		t.Error(err)
+	}
+	if err := m.GuestRemove(ctx, "Ubuntu", creds, true, "/opt/a"); err != nil {
+		t.Error(err)
+	}
+	if err := m.GuestMove(ctx, "Ubuntu", creds, "/opt/x", "/opt/b", "/opt/c"); err != nil {
+		t.Error(err)
+	}
+	tmp, err := m.GuestMkTemp(ctx, "Ubuntu", creds, GuestTemp{Template: "buildXXXXXX", Dir: "/opt", Directory: true})
+	if err != nil || tmp != "/opt/build8wx0ab" {
+		t.Errorf("GuestMkTemp() = %q, %v", tmp, err)
+	}
+	if _, err := m.GuestStat(ctx, "Ubuntu", creds, "/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GuestStat() error = %v; want %v", err, fs.ErrNotExist)
+	}
+	if err := m.GuestRmdir(ctx, "Ubuntu", creds, "/opt/x", false); !errors.Is(err, ErrGuestControl) {
+		t.Errorf("GuestRmdir() error = %v; want %v", err, ErrGuestControl)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
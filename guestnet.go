@@ -0,0 +1,97 @@
+package virtualbox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GuestNetwork is a network interface of the guest, as reported by the Guest
+// Additions in the /VirtualBox/GuestInfo/Net guest properties.
+type GuestNetwork struct {
+	Index   int // the index of the interface in the guest properties
+	Slot    int // the slot of the NIC with the same MAC address, or 0
+	MAC     string
+	IP      net.IP
+	Netmask net.IPMask
+	Up      bool
+}
+
+// normalizeMAC returns the MAC address in the format of showvminfo, e.g.
+// 080027EE1DF7.
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+}
+
+// GuestNetworks returns the network interfaces of the guest, which requires
+// the Guest Additions to run. The interfaces are matched with the NICs of the
+// machine by their MAC address.
+func (m *Manager) GuestNetworks(ctx context.Context, id string) ([]GuestNetwork, error) {
+	vm, err := m.Machine(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	const prefix = "/VirtualBox/GuestInfo/Net/"
+	props, err := m.EnumerateGuestProperties(ctx, id, prefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[string]string, len(props))
+	for _, p := range props {
+		vals[strings.TrimPrefix(p.Name, prefix)] = p.Value
+	}
+	count := vals["Count"]
+	if count == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid guest network count %q: %w", count, err)
+	}
+
+	nets := make([]GuestNetwork, 0, n)
+	for i := 0; i < n; i++ {
+		get := func(key string) string { return vals[fmt.Sprintf("%d/%s", i, key)] }
+		g := GuestNetwork{Index: i}
+		g.MAC = normalizeMAC(get("MAC"))
+		g.IP = net.ParseIP(get("V4/IP")).To4()
+		if mask := net.ParseIP(get("V4/Netmask")).To4(); mask != nil {
+			g.Netmask = net.IPMask(mask)
+		}
+		g.Up = strings.EqualFold(get("Status"), "Up")
+		for j, nic := range vm.NICs {
+			if g.MAC != "" && normalizeMAC(nic.MacAddr) == g.MAC {
+				g.Slot = j + 1
+			}
+		}
+		nets = append(nets, g)
+	}
+	return nets, nil
+}
+
+// WaitForIP waits until the guest interface of the NIC in the slot is up with
+// an IPv4 address, and returns the address.
+func (m *Manager) WaitForIP(ctx context.Context, id string, slot int) (net.IP, error) {
+	m.log.Printf("waiting for the IP of nic%d of %q", slot, id)
+	t := time.NewTicker(guestPollInterval)
+	defer t.Stop()
+	for {
+		nets, err := m.GuestNetworks(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range nets {
+			if g.Slot == slot && g.Up && g.IP != nil && !g.IP.IsUnspecified() {
+				return g.IP, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no IP for nic%d of %q: %w", slot, id, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
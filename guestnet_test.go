@@ -0,0 +1,124 @@
+package virtualbox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// guestNetCalls returns the calls reading the guest network properties of the
+// go-virtualbox machine, whose NIC in slot 1 has the MAC 080027EE1DF7. The
+// version is only detected when it is not empty.
+func guestNetCalls(version string, props map[string]string) []Call {
+	calls := []Call{{Args: []string{"showvminfo", "go-virtualbox", "--machinereadable"},
+		Stdout: ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")}}
+	if version != "" {
+		calls = append(calls, Call{Args: []string{"--version"}, Stdout: version + "\n"})
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var stdout strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&stdout, "/VirtualBox/GuestInfo/Net/%s = '%s' @ 2023-08-11T11:12:15.880543000Z [TRANSIENT, RDONLYGUEST]\n", name, props[name])
+	}
+	return append(calls, Call{
+		Args:   []string{"guestproperty", "enumerate", "go-virtualbox", "/VirtualBox/GuestInfo/Net/*"},
+		Stdout: stdout.String(),
+	})
+}
+
+func TestManagerGuestNetworks(t *testing.T) {
+	vm, err := parseMachine(ReadTestData("showvminfo_go-virtualbox_--machinereadable.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := vm.NICs[0].MacAddr
+	r := NewReplayer(guestNetCalls("7.0.10r158379", map[string]string{
+		"Count":        "2",
+		"0/MAC":        mac,
+		"0/V4/IP":      "10.0.2.15",
+		"0/V4/Netmask": "255.255.255.0",
+		"0/Status":     "Up",
+		"1/MAC":        "0800271A2B3C",
+		"1/Status":     "Down",
+	})...)
+	m := NewManager(Replay(r))
+	got, err := m.GuestNetworks(context.Background(), "go-virtualbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GuestNetwork{
+		{Index: 0, Slot: 1, MAC: mac, IP: net.IPv4(10, 0, 2, 15).To4(), Netmask: net.CIDRMask(24, 32), Up: true},
+		{Index: 1, MAC: "0800271A2B3C"},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("GuestNetworks() = %+v; diff = %v", got, diff)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWaitForIP(t *testing.T) {
+	defer func(d time.Duration) { guestPollInterval = d }(guestPollInterval)
+	guestPollInterval = time.Millisecond
+
+	vm, err := parseMachine(ReadTestData("showvminfo_go-virtualbox_--machinereadable.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := vm.NICs[0].MacAddr
+	calls := guestNetCalls("7.0.10r158379", map[string]string{"Count": "1", "0/MAC": mac, "0/Status": "Down"})
+	calls = append(calls, guestNetCalls("", map[string]string{
+		"Count":        "1",
+		"0/MAC":        mac,
+		"0/V4/IP":      "10.0.2.15",
+		"0/V4/Netmask": "255.255.255.0",
+		"0/Status":     "Up",
+	})...)
+	r := NewReplayer(calls...)
+	m := NewManager(Replay(r))
+	ip, err := m.WaitForIP(context.Background(), "go-virtualbox", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(10, 0, 2, 15)) {
+		t.Errorf("WaitForIP() = %v; want 10.0.2.15", ip)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWaitForIPTimeout(t *testing.T) {
+	defer func(d time.Duration) { guestPollInterval = d }(guestPollInterval)
+	guestPollInterval = time.Hour
+
+	r := NewReplayer(guestNetCalls("7.0.10r158379", nil)...)
+	m := NewManager(Replay(r))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if ip, err := m.WaitForIP(ctx, "go-virtualbox", 1); err == nil {
+		t.Errorf("WaitForIP() = %v; want an error", ip)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	for _, mac := range []string{"08:00:27:ee:1d:f7", "08-00-27-EE-1D-F7", "080027ee1df7"} {
+		if got := normalizeMAC(mac); got != "080027EE1DF7" {
+			t.Errorf("normalizeMAC(%q) = %q", mac, got)
+		}
+	}
+}
@@ -1,31 +1,82 @@
 package virtualbox
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 // GuestProperty holds key, value and associated flags.
 type GuestProperty struct {
-	Name  string
-	Value string
+	Name      string
+	Value     string
+	Flags     []GuestPropertyFlag
+	Timestamp time.Time // the time of the last change, only set by EnumerateGuestProperties
 }
 
+// GuestPropertyFlag restricts who can change a guest property, and for how
+// long it is kept.
+type GuestPropertyFlag string
+
+const (
+	// GuestPropTransient properties are removed when the machine powers off.
+	GuestPropTransient = GuestPropertyFlag("TRANSIENT")
+	// GuestPropTransReset properties are also removed when the machine resets.
+	GuestPropTransReset = GuestPropertyFlag("TRANSRESET")
+	// GuestPropReadOnlyGuest properties can only be changed by the host.
+	GuestPropReadOnlyGuest = GuestPropertyFlag("RDONLYGUEST")
+	// GuestPropReadOnlyHost properties can only be changed by the guest.
+	GuestPropReadOnlyHost = GuestPropertyFlag("RDONLYHOST")
+	// GuestPropReadOnly properties can not be changed.
+	GuestPropReadOnly = GuestPropertyFlag("READONLY")
+)
+
+// HasFlag reports whether the property has the flag.
+func (p GuestProperty) HasFlag(f GuestPropertyFlag) bool {
+	for _, pf := range p.Flags {
+		if pf == f {
+			return true
+		}
+	}
+	return false
+}
+
+// flagsArgs returns the --flags arguments of 'guestproperty set'.
+func flagsArgs(flags []GuestPropertyFlag) []string {
+	if len(flags) == 0 {
+		return nil
+	}
+	s := make([]string, len(flags))
+	for i, f := range flags {
+		s[i] = string(f)
+	}
+	return []string{"--flags", strings.Join(s, ",")}
+}
+
+var (
+	getRegexp = regexp.MustCompile("(?i)^Value: (.*)$")
+)
+
 var (
-	getRegexp  = regexp.MustCompile("(?mi)^Value: ([^,]*)$")
-	waitRegexp = regexp.MustCompile("^Name: ([^,]*), value: ([^,]*), flags:.*$")
+	// ErrGuestPropertyNotSet is returned when the guest property has no value.
+	ErrGuestPropertyNotSet = errors.New("guest property not set")
 )
 
-// SetGuestProperty writes a VirtualBox guestproperty to the given value.
-func SetGuestProperty(vm string, prop string, val string) error {
+// SetGuestProperty writes a VirtualBox guestproperty to the given value, with
+// the optional flags.
+func SetGuestProperty(vm string, prop string, val string, flags ...GuestPropertyFlag) error {
 	if Manage().isGuest() {
-		_, _, err := Manage().setOpts(sudo(true)).run("guestproperty", "set", prop, val)
+		args := append([]string{"guestproperty", "set", prop, val}, flagsArgs(flags)...)
+		_, _, err := Manage().setOpts(sudo(true)).run(context.Background(), args...)
 		return err
 	}
-	_, _, err := Manage().run("guestproperty", "set", vm, prop, val)
+	args := append([]string{"guestproperty", "set", vm, prop, val}, flagsArgs(flags)...)
+	_, _, err := Manage().run(context.Background(), args...)
 	return err
 }
 
@@ -34,21 +85,15 @@ func GetGuestProperty(vm string, prop string) (string, error) {
 	var out string
 	var err error
 	if Manage().isGuest() {
-		out, _, err = Manage().setOpts(sudo(true)).run("guestproperty", "get", prop)
+		out, _, err = Manage().setOpts(sudo(true)).run(context.Background(), "guestproperty", "get", prop)
 	} else {
-		out, _, err = Manage().run("guestproperty", "get", vm, prop)
+		out, _, err = Manage().run(context.Background(), "guestproperty", "get", vm, prop)
 	}
 	if err != nil {
 		return "", err
 	}
-	out = strings.TrimSpace(out)
-	Debug("out (trimmed): '%s'", out)
-	var match = getRegexp.FindStringSubmatch(out)
-	Debug("match:", match)
-	if len(match) != 2 {
-		return "", fmt.Errorf("No match with get guestproperty output")
-	}
-	return match[1], nil
+	Debug("out: '%s'", out)
+	return parseGuestPropertyValue(out)
 }
 
 // WaitGuestProperty blocks until a VirtualBox guestproperty is changed
@@ -59,28 +104,29 @@ func GetGuestProperty(vm string, prop string) (string, error) {
 // Deletion of the guestproperty causes WaitGuestProperty to return the
 // string.
 func WaitGuestProperty(vm string, prop string) (string, string, error) {
+	p, err := waitGuestProperty(vm, prop)
+	return p.Name, p.Value, err
+}
+
+// waitGuestProperty is like WaitGuestProperty, but also returns the flags of
+// the changed property.
+func waitGuestProperty(vm string, prop string) (GuestProperty, error) {
 	var out string
 	var err error
 	Debug("WaitGuestProperty(): wait on '%s'", prop)
 	if Manage().isGuest() {
-		_, _, err = Manage().setOpts(sudo(true)).run("guestproperty", "wait", prop)
+		_, _, err = Manage().setOpts(sudo(true)).run(context.Background(), "guestproperty", "wait", prop)
 		if err != nil {
-			return "", "", err
+			return GuestProperty{}, err
 		}
 	}
-	out, _, err = Manage().run("guestproperty", "wait", vm, prop)
+	out, _, err = Manage().run(context.Background(), "guestproperty", "wait", vm, prop)
 	if err != nil {
 		log.Print(err)
-		return "", "", err
-	}
-	out = strings.TrimSpace(out)
-	Debug("WaitGuestProperty(): out (trimmed): '%s'", out)
-	var match = waitRegexp.FindStringSubmatch(out)
-	Debug("WaitGuestProperty(): match:", match)
-	if len(match) != 3 {
-		return "", "", fmt.Errorf("No match with VBoxManage wait guestproperty output")
+		return GuestProperty{}, err
 	}
-	return match[1], match[2], nil
+	Debug("WaitGuestProperty(): out: '%s'", out)
+	return parseGuestPropertyWait(out)
 }
 
 // WaitGuestProperties wait for changes in GuestProperties
@@ -109,12 +155,11 @@ func WaitGuestProperties(vm string, propPattern string, done chan bool, wg *sync
 
 		for {
 			Debug("WaitGetProperties(): waiting for: '%s' changes", propPattern)
-			name, value, err := WaitGuestProperty(vm, propPattern)
+			prop, err := waitGuestProperty(vm, propPattern)
 			if err != nil {
 				log.Printf("WaitGetProperties(): err=%v", err)
 				return
 			}
-			prop := GuestProperty{name, value}
 			select {
 			case props <- prop:
 				Debug("WaitGetProperties(): stacked: %+v", prop)
@@ -131,9 +176,60 @@ func WaitGuestProperties(vm string, propPattern string, done chan bool, wg *sync
 // DeleteGuestProperty deletes a VirtualBox guestproperty.
 func DeleteGuestProperty(vm string, prop string) error {
 	if Manage().isGuest() {
-		_, _, err := Manage().setOpts(sudo(true)).run("guestproperty", "delete", prop)
+		_, _, err := Manage().setOpts(sudo(true)).run(context.Background(), "guestproperty", "delete", prop)
 		return err
 	}
-	_, _, err := Manage().run("guestproperty", "delete", vm, prop)
+	_, _, err := Manage().run(context.Background(), "guestproperty", "delete", vm, prop)
 	return err
 }
+
+// GuestProperty returns the value of the guest property of the machine, or
+// ErrGuestPropertyNotSet when it has no value.
+func (m *Manager) GuestProperty(ctx context.Context, id, name string) (string, error) {
+	stdout, stderr, err := m.run(ctx, "guestproperty", "get", id, name)
+	if err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return "", ErrMachineNotExist
+		}
+		return "", fmt.Errorf("unable to get guest property %q: %w", name, err)
+	}
+	return parseGuestPropertyValue(stdout)
+}
+
+// SetGuestProperty sets the guest property of the machine to the value, with
+// the optional flags.
+func (m *Manager) SetGuestProperty(ctx context.Context, id, name, value string, flags ...GuestPropertyFlag) error {
+	m.log.Printf("setting guest property %q of %q", name, id)
+	args := append([]string{"guestproperty", "set", id, name, value}, flagsArgs(flags)...)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return ErrMachineNotExist
+		}
+		return fmt.Errorf("unable to set guest property %q: %w", name, err)
+	}
+	return nil
+}
+
+// EnumerateGuestProperties returns the guest properties of the machine whose
+// names match any of the patterns, or all of them without patterns. The
+// patterns can use the * and ? wildcards, e.g. "/VirtualBox/GuestInfo/Net/*".
+func (m *Manager) EnumerateGuestProperties(ctx context.Context, id string, patterns ...string) ([]GuestProperty, error) {
+	v, err := m.Version(ctx)
+	if err != nil {
+		m.log.Printf("using legacy guestproperty patterns: %v", err)
+	}
+	args := []string{"guestproperty", "enumerate", id}
+	if v.AtLeast(7, 0, 0) {
+		args = append(args, patterns...)
+	} else if len(patterns) > 0 {
+		args = append(args, "--patterns", strings.Join(patterns, "|"))
+	}
+	stdout, stderr, err := m.run(ctx, args...)
+	if err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return nil, ErrMachineNotExist
+		}
+		return nil, fmt.Errorf("unable to enumerate guest properties: %w", err)
+	}
+	return parseGuestProperties(stdout)
+}
@@ -1,6 +1,7 @@
 package virtualbox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -16,7 +17,7 @@ func TestGuestProperty(t *testing.T) {
 	t.Logf("ManageMock=%v (type=%T)", ManageMock, ManageMock)
 	if ManageMock != nil {
 		ManageMock.EXPECT().isGuest().Return(false)
-		ManageMock.EXPECT().run("guestproperty", "set", VM, "test_key", "test_val").Return("", "", nil)
+		ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "set", VM, "test_key", "test_val").Return("", "", nil)
 	}
 	err := SetGuestProperty(VM, "test_key", "test_val")
 	if err != nil {
@@ -26,7 +27,7 @@ func TestGuestProperty(t *testing.T) {
 
 	if ManageMock != nil {
 		ManageMock.EXPECT().isGuest().Return(false)
-		ManageMock.EXPECT().run("guestproperty", "get", VM, "test_key").Return("Value: test_val", "", nil).Times(1)
+		ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "get", VM, "test_key").Return("Value: test_val", "", nil).Times(1)
 	}
 	val, err := GetGuestProperty(VM, "test_key")
 	if err != nil {
@@ -38,7 +39,7 @@ func TestGuestProperty(t *testing.T) {
 	}
 	if ManageMock != nil {
 		ManageMock.EXPECT().isGuest().Return(false)
-		ManageMock.EXPECT().run("guestproperty", "get", VM, "test_key").Return("value: test_val", "", nil).Times(1)
+		ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "get", VM, "test_key").Return("value: test_val", "", nil).Times(1)
 	}
 	val_lowercase, err := GetGuestProperty(VM, "test_key")
 	if err != nil {
@@ -53,7 +54,7 @@ func TestGuestProperty(t *testing.T) {
 	// Now deletes it...
 	if ManageMock != nil {
 		ManageMock.EXPECT().isGuest().Return(false)
-		ManageMock.EXPECT().run("guestproperty", "delete", VM, "test_key").Return("", "", nil).Times(1)
+		ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "delete", VM, "test_key").Return("", "", nil).Times(1)
 	}
 	err = DeleteGuestProperty(VM, "test_key")
 	if err != nil {
@@ -64,7 +65,7 @@ func TestGuestProperty(t *testing.T) {
 	// ...and check that it is  no longer readable
 	if ManageMock != nil {
 		ManageMock.EXPECT().isGuest().Return(false)
-		ManageMock.EXPECT().run("guestproperty", "get", VM, "test_key").Return("", "", errors.New("foo")).Times(1)
+		ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "get", VM, "test_key").Return("", "", errors.New("foo")).Times(1)
 	}
 	_, err = GetGuestProperty(VM, "test_key")
 	if err == nil {
@@ -83,7 +84,7 @@ func TestWaitGuestProperty(t *testing.T) {
 		waitGuestProperty1Out := ReadTestData("vboxmanage-guestproperty-wait-1.out")
 		gomock.InOrder(
 			ManageMock.EXPECT().isGuest().Return(false),
-			ManageMock.EXPECT().run("guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
 		)
 	} else {
 		go func() {
@@ -117,11 +118,11 @@ func TestWaitGuestProperties(t *testing.T) {
 		waitGuestProperty2Out := ReadTestData("vboxmanage-guestproperty-wait-2.out")
 		gomock.InOrder(
 			ManageMock.EXPECT().isGuest().Return(false),
-			ManageMock.EXPECT().run("guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
 			ManageMock.EXPECT().isGuest().Return(false),
-			ManageMock.EXPECT().run("guestproperty", "wait", VM, "test_*").Return(waitGuestProperty2Out, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "wait", VM, "test_*").Return(waitGuestProperty2Out, "", nil).Times(1),
 			ManageMock.EXPECT().isGuest().Return(false),
-			ManageMock.EXPECT().run("guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
 		)
 	} else {
 		go func() {
@@ -176,7 +177,7 @@ func TestWaitGuestPropertiesQuit(t *testing.T) {
 		waitGuestProperty1Out := ReadTestData("vboxmanage-guestproperty-wait-1.out")
 		gomock.InOrder(
 			ManageMock.EXPECT().isGuest().Return(false),
-			ManageMock.EXPECT().run("guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "wait", VM, "test_*").Return(waitGuestProperty1Out, "", nil).Times(1),
 		)
 	} else {
 		go func() {
@@ -205,3 +206,57 @@ func TestWaitGuestPropertiesQuit(t *testing.T) {
 
 	Teardown()
 }
+
+func TestSetGuestPropertyFlags(t *testing.T) {
+	Setup(t)
+	defer Teardown()
+
+	if ManageMock == nil {
+		t.Skip("only with the mocked VBoxManage")
+	}
+	ManageMock.EXPECT().isGuest().Return(false)
+	ManageMock.EXPECT().run(gomock.Any(), "guestproperty", "set", VM, "test_key", "test_val",
+		"--flags", "TRANSIENT,RDONLYGUEST").Return("", "", nil)
+	if err := SetGuestProperty(VM, "test_key", "test_val", GuestPropTransient, GuestPropReadOnlyGuest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagerEnumerateGuestProperties(t *testing.T) {
+	tests := map[string]struct {
+		version string
+		args    []string
+		stdout  string
+	}{
+		"7.0": {
+			version: "7.0.10r158379",
+			args:    []string{"guestproperty", "enumerate", "Ubuntu", "/VirtualBox/GuestAdd/*", "motd"},
+			stdout:  "/VirtualBox/GuestAdd/Version = '7.0.10' @ 2023-08-11T11:12:15.880543000Z [TRANSIENT, RDONLYGUEST]\n",
+		},
+		"6.1": {
+			version: "6.1.38r153438",
+			args:    []string{"guestproperty", "enumerate", "Ubuntu", "--patterns", "/VirtualBox/GuestAdd/*|motd"},
+			stdout:  "Name: /VirtualBox/GuestAdd/Version, value: 7.0.10, timestamp: 1691752335880543000, flags: TRANSIENT, RDONLYGUEST\n",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(
+				Call{Args: []string{"--version"}, Stdout: tc.version + "\n"},
+				Call{Args: tc.args, Stdout: tc.stdout},
+			)
+			m := NewManager(Replay(r))
+			props, err := m.EnumerateGuestProperties(context.Background(), "Ubuntu", "/VirtualBox/GuestAdd/*", "motd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(props) != 1 || props[0].Value != "7.0.10" || !props[0].HasFlag(GuestPropTransient) || props[0].Timestamp.IsZero() {
+				t.Errorf("EnumerateGuestProperties() = %+v", props)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package virtualbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GuestSession is a guest control session of a running machine, as listed by
+// 'guestcontrol list'.
+type GuestSession struct {
+	ID        uint32
+	User      string
+	Status    string // e.g. started or terminated
+	Name      string
+	Processes []GuestProcess
+	Files     []GuestOpenFile
+}
+
+// GuestProcess is a process started by a guest control session.
+type GuestProcess struct {
+	PID     uint32
+	Status  string // e.g. started or successfully terminated
+	Command string
+}
+
+// GuestOpenFile is a file opened by a guest control session.
+type GuestOpenFile struct {
+	ID     uint32
+	Status string
+	Name   string
+}
+
+// GuestSessions returns the guest control sessions of the running machine
+// with their processes and files.
+func (m *Manager) GuestSessions(ctx context.Context, vm string) ([]GuestSession, error) {
+	stdout, err := m.guestcontrol(ctx, vm, GuestCredentials{}, "list", "all")
+	if err != nil {
+		return nil, err
+	}
+	return parseGuestSessions(stdout)
+}
+
+// parseGuestSessions parses the output of 'guestcontrol list all', where the
+// processes and the files follow the session they belong to:
+//
+//	Session #0   | ID=1   | User=vagrant          | Status=[started] | Name=provision
+//		Process #0   | PID=1337   | Status=[started] | Command=/bin/sleep
+//		File #0   | ID=3      | Status=[open] | Name=/tmp/build.log
+func parseGuestSessions(s string) ([]GuestSession, error) {
+	var sessions []GuestSession
+	for _, line := range lines(s) {
+		line = strings.TrimSpace(line)
+		kind, _, _ := strings.Cut(line, " ")
+		var n int
+		switch kind {
+		case "Session":
+			n = 5
+		case "Process", "File":
+			n = 4
+		default:
+			continue
+		}
+		// The name or the command is the last field, it may contain the
+		// separator.
+		fields := strings.SplitN(line, "|", n)
+		if len(fields) != n {
+			return nil, fmt.Errorf("invalid guest control line %q", line)
+		}
+		vals := make([]string, 0, n-1)
+		for _, f := range fields[1:] {
+			_, v, _ := strings.Cut(strings.TrimSpace(f), "=")
+			vals = append(vals, strings.Trim(v, "[]"))
+		}
+		// The name is only trimmed of the spaces, it may be in brackets.
+		_, vals[n-2], _ = strings.Cut(strings.TrimLeft(fields[n-1], " "), "=")
+		id, err := strconv.ParseUint(vals[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guest control line %q: %w", line, err)
+		}
+		if kind == "Session" {
+			sessions = append(sessions, GuestSession{ID: uint32(id), User: vals[1], Status: vals[2], Name: vals[3]})
+			continue
+		}
+		if len(sessions) == 0 {
+			return nil, fmt.Errorf("%s without a session: %q", kind, line)
+		}
+		last := &sessions[len(sessions)-1]
+		if kind == "Process" {
+			last.Processes = append(last.Processes, GuestProcess{PID: uint32(id), Status: vals[1], Command: vals[2]})
+		} else {
+			last.Files = append(last.Files, GuestOpenFile{ID: uint32(id), Status: vals[1], Name: vals[2]})
+		}
+	}
+	return sessions, nil
+}
+
+// CloseGuestSession closes the guest control session with the ID, which
+// terminates its processes.
+func (m *Manager) CloseGuestSession(ctx context.Context, vm string, id uint32) error {
+	m.log.Printf("closing guest session %d of %q", id, vm)
+	_, err := m.guestcontrol(ctx, vm, GuestCredentials{}, "closesession", "--session-id", strconv.FormatUint(uint64(id), 10))
+	return err
+}
+
+// CloseGuestProcess terminates the processes of the guest control session
+// with the ID.
+func (m *Manager) CloseGuestProcess(ctx context.Context, vm string, session uint32, pids ...uint32) error {
+	if len(pids) == 0 {
+		return fmt.Errorf("no processes to close")
+	}
+	m.log.Printf("closing guest processes %v of %q", pids, vm)
+	args := []string{"--session-id", strconv.FormatUint(uint64(session), 10)}
+	for _, pid := range pids {
+		args = append(args, strconv.FormatUint(uint64(pid), 10))
+	}
+	_, err := m.guestcontrol(ctx, vm, GuestCredentials{}, "closeprocess", args...)
+	return err
+}
+
+// CleanupGuestSessions closes the guest control sessions whose name matches
+// the path.Match pattern and which have not terminated, e.g. the ones leaked
+// by a crashed provisioner. It returns the closed sessions, and the first
+// error after trying all of them.
+func (m *Manager) CleanupGuestSessions(ctx context.Context, vm, pattern string) ([]GuestSession, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	sessions, err := m.GuestSessions(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		closed   []GuestSession
+		firstErr error
+	)
+	for _, s := range sessions {
+		if ok, _ := path.Match(pattern, s.Name); !ok || s.Status == "terminated" {
+			continue
+		}
+		if err := m.CloseGuestSession(ctx, vm, s.ID); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		closed = append(closed, s)
+	}
+	return closed, firstErr
+}
+
+// GuestEvent is an event of the guest control, reported by WatchGuest.
+type GuestEvent struct {
+	Session uint32 // the ID of the session, or 0 when it is not known
+	PID     uint32 // the PID of the process, or 0 for the session events
+	Status  string // the new status, if the event is a status change
+	Message string // the line printed by VBoxManage
+}
+
+var (
+	reWatchSession = regexp.MustCompile(`Session ID=(\d+)\b.*?(?:status to \[([^\]]*)\]|(\bregistered|\bunregistered))`)
+	reWatchProcess = regexp.MustCompile(`\(PID (\d+)\).*?status changed to \[([^\]]*)\]`)
+)
+
+// parseGuestEvent parses a line of 'guestcontrol watch'.
+func parseGuestEvent(line string) GuestEvent {
+	e := GuestEvent{Message: line}
+	if m := reWatchSession.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.ParseUint(m[1], 10, 32)
+		e.Session, e.Status = uint32(id), m[2]+m[3]
+	}
+	if m := reWatchProcess.FindStringSubmatch(line); m != nil {
+		pid, _ := strconv.ParseUint(m[1], 10, 32)
+		e.PID, e.Status = uint32(pid), m[2]
+	}
+	return e
+}
+
+// WatchGuest calls fn with the guest control events of the running machine
+// until the context is done, which is not reported as an error. The watch
+// holds a slot of the concurrency limit of the manager until it returns.
+func (m *Manager) WatchGuest(ctx context.Context, vm string, fn func(GuestEvent)) error {
+	p, err := m.stream(ctx, "guestcontrol", vm, "watch")
+	if err != nil {
+		return fmt.Errorf("unable to watch %q: %w", vm, err)
+	}
+	errs := &vboxErrors{}
+	drained := make(chan struct{})
+	go func() {
+		// The stderr is drained, so VBoxManage does not block on it.
+		_, _ = io.Copy(errs, p.stderr)
+		close(drained)
+	}()
+	sc := bufio.NewScanner(p.stdout)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "Waiting for events") {
+			continue
+		}
+		fn(parseGuestEvent(line))
+	}
+	_, _ = io.Copy(io.Discard, p.stdout)
+	<-drained
+	err = p.wait()
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return nil
+	}
+	if err != nil {
+		if msg := errs.String(); msg != "" {
+			return fmt.Errorf("%w: %s", ErrGuestControl, msg)
+		}
+		return fmt.Errorf("unable to watch %q: %w", vm, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseGuestSessions(t *testing.T) {
+	got, err := parseGuestSessions(ReadTestData("vboxmanage-guestcontrol-list-all-1.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GuestSession{
+		{
+			ID: 1, User: "vagrant", Status: "started",
+			Name: "[1234] VBoxManage Guest Control [Ubuntu] - provision | bootstrap",
+			Processes: []GuestProcess{
+				{PID: 1337, Status: "started", Command: "/bin/sleep"},
+				{PID: 1338, Status: "successfully terminated", Command: "/usr/bin/env"},
+			},
+			Files: []GuestOpenFile{{ID: 3, Status: "open", Name: "/tmp/build.log"}},
+		},
+		{ID: 2, User: "root", Status: "terminated", Name: "cleanup"},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("parseGuestSessions() = %+v; diff = %v", got, diff)
+	}
+	if got, err := parseGuestSessions("Active guest sessions:\nNo active guest sessions\n\nTotal guest sessions: 0\n"); err != nil || len(got) != 0 {
+		t.Errorf("parseGuestSessions() without sessions = %v, %v", got, err)
+	}
+}
+
+func TestManagerCleanupGuestSessions(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"guestcontrol", "Ubuntu", "list", "all"},
+			Stdout: ReadTestData("vboxmanage-guestcontrol-list-all-1.out")},
+		Call{Args: []string{"guestcontrol", "Ubuntu", "closesession", "--session-id", "1"}},
+	)
+	m := NewManager(Replay(r))
+	closed, err := m.CleanupGuestSessions(context.Background(), "Ubuntu", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 1 || closed[0].ID != 1 {
+		t.Errorf("CleanupGuestSessions() = %+v; want the started session", closed)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWatchGuest(t *testing.T) {
+	r := NewReplayer(Call{
+		Args: []string{"guestcontrol", "Ubuntu", "watch"},
+		Stdout: `Waiting for events ...
+Session ID=3 "provision" got registered
+Session ID=3 "provision" changed status to [started]
+Process "/bin/sleep" (PID 1337) status changed to [started]
+`,
+	})
+	m := NewManager(Replay(r))
+	var got []GuestEvent
+	if err := m.WatchGuest(context.Background(), "Ubuntu", func(e GuestEvent) {
+		e.Message = ""
+		got = append(got, e)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []GuestEvent{
+		{Session: 3, Status: "registered"},
+		{Session: 3, Status: "started"},
+		{PID: 1337, Status: "started"},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("WatchGuest() = %+v; diff = %v", got, diff)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWatchGuestConcurrency(t *testing.T) {
+	m := NewManager(Concurrency(1))
+	m.runner = func(context.Context, ...string) (string, string, error) { return "", "", nil }
+	stdout, w := io.Pipe()
+	m.streamer = func(context.Context, ...string) (*process, error) {
+		return &process{stdout: stdout, stderr: strings.NewReader(""), wait: func() error { return nil }}, nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- m.WatchGuest(context.Background(), "Ubuntu", func(GuestEvent) {})
+	}()
+	// The first event is only read once the watch started.
+	if _, err := io.WriteString(w, "Session ID=3 \"provision\" got registered\n"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.run(ctx, "list", "vms"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run() while watching = %v; want %v", err, context.DeadlineExceeded)
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.run(context.Background(), "list", "vms"); err != nil {
+		t.Errorf("run() after the watch = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrHostInterfaceNotExist is returned when the host has no network
+	// interface with the given name.
+	ErrHostInterfaceNotExist = errors.New("host interface does not exist")
+)
+
+// HostInterface is a network interface of the host, which the NICs can be
+// bridged to.
+type HostInterface struct {
+	Name        string
+	GUID        string
+	DHCP        bool
+	IPv4        net.IPNet
+	IPv6        net.IPNet
+	HwAddr      net.HardwareAddr
+	Medium      string
+	Wireless    bool
+	Status      string // Up or Down
+	NetworkName string
+}
+
+// HostInfo is the information about the host running VirtualBox.
+type HostInfo struct {
+	OS                     string
+	OSVersion              string
+	CPUs                   int
+	OnlineCPUs             int
+	Cores                  int
+	CPUDescription         string
+	HWVirtualization       bool
+	NestedHWVirtualization bool
+	NestedPaging           bool
+	LongMode               bool
+	Memory                 uint // in MB
+	MemoryAvailable        uint // in MB
+}
+
+// BridgedInterfaces returns the network interfaces of the host, which can be
+// used as NIC.HostInterface in the bridged mode, keyed by their name.
+func (m *Manager) BridgedInterfaces(ctx context.Context) (map[string]*HostInterface, error) {
+	stdout, _, err := m.run(ctx, "list", "bridgedifs")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list bridged interfaces: %w", err)
+	}
+	ifs, err := parseHostInterfaces(stdout)
+	if err != nil {
+		return nil, err
+	}
+	hm := make(map[string]*HostInterface, len(ifs))
+	for _, hi := range ifs {
+		hm[hi.Name] = hi
+	}
+	return hm, nil
+}
+
+// BridgedInterface returns the network interface of the host with the given
+// name. The error of an unknown interface lists the known ones.
+func (m *Manager) BridgedInterface(ctx context.Context, name string) (*HostInterface, error) {
+	ifs, err := m.BridgedInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hi, ok := ifs[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w, known are %s", name, ErrHostInterfaceNotExist, interfaceNames(ifs))
+	}
+	return hi, nil
+}
+
+// DefaultBridgedInterface selects the network interface of the host to
+// bridge to: the one which is up and has an IPv4 address, preferring the
+// wired interfaces. The names decide between the equal ones.
+func (m *Manager) DefaultBridgedInterface(ctx context.Context) (*HostInterface, error) {
+	ifs, err := m.BridgedInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var best *HostInterface
+	for _, name := range sortedInterfaceNames(ifs) {
+		hi := ifs[name]
+		if hi.Status != "Up" || hi.IPv4.IP == nil || hi.IPv4.IP.IsUnspecified() || hi.IPv4.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if best == nil || (best.Wireless && !hi.Wireless) {
+			best = hi
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no host interface is up with an IPv4 address: %w", ErrHostInterfaceNotExist)
+	}
+	return best, nil
+}
+
+// ValidateNIC checks that the host interface or network the NIC is attached
+// to exists, so the machine is not modified with an unknown one. Like SetNIC,
+// a NICNetHostonly NIC can name a host-only network on VirtualBox 7.0.
+func (m *Manager) ValidateNIC(ctx context.Context, nic NIC) error {
+	attachment, err := m.hostonlyAttachment(ctx, nic)
+	if err != nil {
+		return fmt.Errorf("invalid %s NIC: %w", nic.Network, err)
+	}
+	switch attachment {
+	case NICNetBridged:
+		_, err = m.BridgedInterface(ctx, nic.HostInterface)
+	case NICNetHostonly:
+		_, err = m.HostonlyNet(ctx, nic.HostInterface)
+	case NICNetHostonlyNet:
+		_, err = m.HostonlyNetwork(ctx, nic.HostInterface)
+	case NICNetNATNetwork:
+		_, err = m.NATNet(ctx, nic.HostInterface)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s NIC: %w", nic.Network, err)
+	}
+	return nil
+}
+
+// IntNets returns the names of the internal networks in use by the machines.
+func (m *Manager) IntNets(ctx context.Context) ([]string, error) {
+	stdout, _, err := m.run(ctx, "list", "intnets")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list internal networks: %w", err)
+	}
+	return parseIntNets(stdout), nil
+}
+
+// HostInfo returns the information about the host.
+func (m *Manager) HostInfo(ctx context.Context) (*HostInfo, error) {
+	stdout, _, err := m.run(ctx, "list", "hostinfo")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host information: %w", err)
+	}
+	return parseHostInfo(stdout)
+}
+
+func sortedInterfaceNames(ifs map[string]*HostInterface) []string {
+	names := make([]string, 0, len(ifs))
+	for name := range ifs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func interfaceNames(ifs map[string]*HostInterface) string {
+	names := sortedInterfaceNames(ifs)
+	if len(names) == 0 {
+		return "none"
+	}
+	return "'" + strings.Join(names, "', '") + "'"
+}
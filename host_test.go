@@ -0,0 +1,121 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseHostInterfaces(t *testing.T) {
+	ifs, err := parseHostInterfaces(ReadTestData("vboxmanage-list-bridgedifs-1.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ifs) != 3 {
+		t.Fatalf("parseHostInterfaces() = %d interfaces; want 3", len(ifs))
+	}
+	want := &HostInterface{
+		Name:        "en0: Wi-Fi (AirPort)",
+		GUID:        "00306e65-0000-4000-8000-a45e60c5a2c3",
+		IPv4:        net.IPNet{IP: net.ParseIP("192.168.1.23"), Mask: net.IPv4Mask(255, 255, 255, 0)},
+		IPv6:        net.IPNet{IP: net.ParseIP("fe80::1c2a:4bff:fe3e:9d01"), Mask: net.CIDRMask(64, 128)},
+		HwAddr:      net.HardwareAddr{0xa4, 0x5e, 0x60, 0xc5, 0xa2, 0xc3},
+		Medium:      "Ethernet",
+		Wireless:    true,
+		Status:      "Up",
+		NetworkName: "HostInterfaceNetworking-en0",
+	}
+	if diff := deep.Equal(ifs[0], want); diff != nil {
+		t.Errorf("parseHostInterfaces()[0] = %+v; diff = %v", ifs[0], diff)
+	}
+}
+
+func TestParseHostInfo(t *testing.T) {
+	info, err := parseHostInfo(ReadTestData("vboxmanage-list-hostinfo-1.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &HostInfo{
+		OS:               "Darwin",
+		OSVersion:        "22.2.0",
+		CPUs:             8,
+		OnlineCPUs:       8,
+		Cores:            4,
+		CPUDescription:   "Intel(R) Core(TM) i7-1068NG7 CPU @ 2.30GHz",
+		HWVirtualization: true,
+		NestedPaging:     true,
+		LongMode:         true,
+		Memory:           32768,
+		MemoryAvailable:  11935,
+	}
+	if diff := deep.Equal(info, want); diff != nil {
+		t.Errorf("parseHostInfo() = %+v; diff = %v", info, diff)
+	}
+	if _, err := parseHostInfo("Host Information:\n\nMemory size: lots\n"); err == nil {
+		t.Error("parseHostInfo() of an invalid memory size succeeded")
+	}
+}
+
+func TestParseIntNets(t *testing.T) {
+	got := parseIntNets("Name:        intnet\n\nName:        my net\r\n\r\n")
+	if diff := deep.Equal(got, []string{"intnet", "my net"}); diff != nil {
+		t.Errorf("parseIntNets() = %v; diff = %v", got, diff)
+	}
+}
+
+func TestManagerBridgedInterfaces(t *testing.T) {
+	bridgedifs := ReadTestData("vboxmanage-list-bridgedifs-1.out")
+	list := Call{Args: []string{"list", "bridgedifs"}, Stdout: bridgedifs}
+	r := NewReplayer(list, list, list, list)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	hi, err := m.DefaultBridgedInterface(ctx)
+	if err != nil || hi.Name != "en7: USB 10/100/1000 LAN" {
+		t.Errorf("DefaultBridgedInterface() = %+v, %v; want the wired en7", hi, err)
+	}
+	if _, err := m.BridgedInterface(ctx, "en1: Thunderbolt 1"); err != nil {
+		t.Errorf("BridgedInterface() = %v; want nil", err)
+	}
+	_, err = m.BridgedInterface(ctx, "en9")
+	if !errors.Is(err, ErrHostInterfaceNotExist) || !strings.Contains(err.Error(), "'en0: Wi-Fi (AirPort)'") {
+		t.Errorf("BridgedInterface() = %v; want %v listing the known interfaces", err, ErrHostInterfaceNotExist)
+	}
+	err = m.ValidateNIC(ctx, NIC{Network: NICNetBridged, HostInterface: "en9"})
+	if !errors.Is(err, ErrHostInterfaceNotExist) {
+		t.Errorf("ValidateNIC() = %v; want %v", err, ErrHostInterfaceNotExist)
+	}
+	if err := m.ValidateNIC(ctx, NIC{Network: NICNetNAT}); err != nil {
+		t.Errorf("ValidateNIC() of a NAT NIC = %v; want nil", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerValidateNICHostonly(t *testing.T) {
+	listNets := Call{Args: []string{"list", "hostonlynets"}, Stdout: listHostonlyNets}
+	r := NewReplayer(
+		Call{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+		listNets,
+		listNets,
+		listNets,
+		Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	if err := m.ValidateNIC(ctx, NIC{Network: NICNetHostonly, HostInterface: "HostNetwork"}); err != nil {
+		t.Errorf("ValidateNIC() of a hostonly NIC naming a hostonly network = %v; want nil", err)
+	}
+	if err := m.ValidateNIC(ctx, NIC{Network: NICNetHostonly, HostInterface: "vboxnet9"}); !errors.Is(err, ErrHostonlyInterfaceNotExist) {
+		t.Errorf("ValidateNIC() = %v; want %v", err, ErrHostonlyInterfaceNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
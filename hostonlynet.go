@@ -1,7 +1,7 @@
 package virtualbox
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -9,16 +9,18 @@ import (
 	"regexp"
 	"runtime"
 	"strconv"
-	"strings"
 )
 
 var (
-	reHostonlyInterfaceCreated = regexp.MustCompile(`Interface '(.+)' was successfully created`)
+	reHostonlyInterfaceCreated  = regexp.MustCompile(`Interface '(.+)' was successfully created`)
+	reHostonlyInterfaceNotFound = regexp.MustCompile(`host network interface named '(.+)' could not be found`)
 )
 
 var (
 	// ErrHostonlyInterfaceCreation is the error message created when VBoxManaged failed to create a new hostonly interface.
 	ErrHostonlyInterfaceCreation = errors.New("failed to create hostonly interface")
+	// ErrHostonlyInterfaceNotExist is returned when the host-only interface does not exist.
+	ErrHostonlyInterfaceNotExist = errors.New("hostonly interface does not exist")
 )
 
 // HostonlyNet defines each host-only network.
@@ -34,123 +36,153 @@ type HostonlyNet struct {
 	NetworkName string // referenced in DHCP.NetworkName
 }
 
-// CreateHostonlyNet creates a new host-only network.
-func CreateHostonlyNet() (*HostonlyNet, error) {
-	out, _, err := Manage().run("hostonlyif", "create")
+// CreateHostonlyNet creates a new host-only interface and returns it with the
+// configuration VirtualBox has given to it.
+func (m *Manager) CreateHostonlyNet(ctx context.Context) (*HostonlyNet, error) {
+	m.log.Println("creating hostonly interface")
+	stdout, _, err := m.run(ctx, "hostonlyif", "create")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to create hostonly interface: %w", err)
 	}
-	res := reHostonlyInterfaceCreated.FindStringSubmatch(out)
+	res := reHostonlyInterfaceCreated.FindStringSubmatch(stdout)
 	if res == nil {
 		return nil, ErrHostonlyInterfaceCreation
 	}
-	return &HostonlyNet{Name: res[1]}, nil
+	return m.HostonlyNet(ctx, res[1])
 }
 
-// Config changes the configuration of the host-only network.
-func (n *HostonlyNet) Config() error {
-
-	//We need a windowsfix because of https://www.virtualbox.org/ticket/8796
+// ConfigHostonlyNet changes the IPv4 and IPv6 addresses of the host-only
+// interface, or switches it to DHCP. Addresses which are not set are left
+// untouched. On Windows, the addresses are set with netsh instead, see
+// https://www.virtualbox.org/ticket/8796.
+func (m *Manager) ConfigHostonlyNet(ctx context.Context, n *HostonlyNet) error {
+	m.log.Printf("configuring hostonly interface %q", n.Name)
+	var cmds [][]string
 	if runtime.GOOS == osWindows {
+		if err := configHostonlyNetsh(ctx, n); err != nil {
+			return err
+		}
+	} else {
 		if n.IPv4.IP != nil && n.IPv4.Mask != nil {
-			cmd := exec.Command("netsh", "interface", "ip", "set", "address", fmt.Sprintf("name=\"%s\"", n.Name), "static", n.IPv4.IP.String(), net.IP(n.IPv4.Mask).String()) // #nosec
-			if err := cmd.Run(); err != nil {
-				return err
-			}
+			cmds = append(cmds, []string{"--ip", n.IPv4.IP.String(), "--netmask", net.IP(n.IPv4.Mask).String()})
 		}
 		if n.IPv6.IP != nil && n.IPv6.Mask != nil {
 			prefixLen, _ := n.IPv6.Mask.Size()
-			cmd := exec.Command("netsh", "interface", "ipv6", "add", "address", n.Name, fmt.Sprintf("%s/%d", n.IPv6.IP.String(), prefixLen)) // #nosec
-			if err := cmd.Run(); err != nil {
-				return err
-			}
+			cmds = append(cmds, []string{"--ipv6", n.IPv6.IP.String(), "--netmasklengthv6", strconv.Itoa(prefixLen)})
 		}
-		if n.DHCP {
-			if _, _, err := Manage().run("hostonlyif", "ipconfig", fmt.Sprintf("\"%s\"", n.Name), "--dhcp"); err != nil { // not implemented as of VirtualBox 4.3
-				return err
+	}
+	if n.DHCP {
+		cmds = append(cmds, []string{"--dhcp"})
+	}
+	for _, c := range cmds {
+		args := append([]string{"hostonlyif", "ipconfig", n.Name}, c...)
+		if _, stderr, err := m.run(ctx, args...); err != nil {
+			if reHostonlyInterfaceNotFound.MatchString(stderr) {
+				return ErrHostonlyInterfaceNotExist
 			}
+			return fmt.Errorf("unable to configure hostonly interface: %w", err)
 		}
+	}
+	return nil
+}
 
-	} else {
-		if n.IPv4.IP != nil && n.IPv4.Mask != nil {
-			if _, _, err := Manage().run("hostonlyif", "ipconfig", n.Name, "--ip", n.IPv4.IP.String(), "--netmask", net.IP(n.IPv4.Mask).String()); err != nil {
-				return err
-			}
+// configHostonlyNetsh sets the addresses of the host-only interface with
+// netsh, as VBoxManage fails to do it on Windows.
+func configHostonlyNetsh(ctx context.Context, n *HostonlyNet) error {
+	if n.IPv4.IP != nil && n.IPv4.Mask != nil {
+		cmd := exec.CommandContext(ctx, "netsh", "interface", "ip", "set", "address", fmt.Sprintf("name=\"%s\"", n.Name), "static", n.IPv4.IP.String(), net.IP(n.IPv4.Mask).String()) // #nosec
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("unable to set the IPv4 address of %q: %w", n.Name, err)
 		}
-
-		if n.IPv6.IP != nil && n.IPv6.Mask != nil {
-			prefixLen, _ := n.IPv6.Mask.Size()
-			if _, _, err := Manage().run("hostonlyif", "ipconfig", n.Name, "--ipv6", n.IPv6.IP.String(), "--netmasklengthv6", fmt.Sprintf("%d", prefixLen)); err != nil {
-				return err
-			}
+	}
+	if n.IPv6.IP != nil && n.IPv6.Mask != nil {
+		prefixLen, _ := n.IPv6.Mask.Size()
+		cmd := exec.CommandContext(ctx, "netsh", "interface", "ipv6", "add", "address", n.Name, fmt.Sprintf("%s/%d", n.IPv6.IP.String(), prefixLen)) // #nosec
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("unable to set the IPv6 address of %q: %w", n.Name, err)
 		}
+	}
+	return nil
+}
 
-		if n.DHCP {
-			if _, _, err := Manage().run("hostonlyif", "ipconfig", n.Name, "--dhcp"); err != nil { // not implemented as of VirtualBox 4.3
-				return err
-			}
-		}
+// HostonlyNets returns all host-only interfaces keyed by their name.
+func (m *Manager) HostonlyNets(ctx context.Context) (map[string]*HostonlyNet, error) {
+	stdout, _, err := m.run(ctx, "list", "hostonlyifs")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list hostonly interfaces: %w", err)
+	}
+	nets, err := parseHostonlyNets(stdout)
+	if err != nil {
+		return nil, err
+	}
+	hm := make(map[string]*HostonlyNet, len(nets))
+	for _, n := range nets {
+		hm[n.Name] = n
 	}
+	return hm, nil
+}
 
+// HostonlyNet returns the host-only interface with the given name.
+func (m *Manager) HostonlyNet(ctx context.Context, name string) (*HostonlyNet, error) {
+	nets, err := m.HostonlyNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := nets[name]
+	if !ok {
+		return nil, ErrHostonlyInterfaceNotExist
+	}
+	return n, nil
+}
+
+// RemoveHostonlyNet removes the host-only interface with the given name.
+func (m *Manager) RemoveHostonlyNet(ctx context.Context, name string) error {
+	m.log.Printf("removing hostonly interface %q", name)
+	_, stderr, err := m.run(ctx, "hostonlyif", "remove", name)
+	if err != nil {
+		if reHostonlyInterfaceNotFound.MatchString(stderr) {
+			return ErrHostonlyInterfaceNotExist
+		}
+		return fmt.Errorf("unable to remove hostonly interface: %w", err)
+	}
 	return nil
+}
 
+// CreateHostonlyNet creates a new host-only network.
+// DEPRECATED: Use (*Manager).CreateHostonlyNet
+func CreateHostonlyNet() (*HostonlyNet, error) {
+	out, _, err := Manage().run(context.Background(), "hostonlyif", "create")
+	if err != nil {
+		return nil, err
+	}
+	res := reHostonlyInterfaceCreated.FindStringSubmatch(out)
+	if res == nil {
+		return nil, ErrHostonlyInterfaceCreation
+	}
+	return &HostonlyNet{Name: res[1]}, nil
+}
+
+// Config changes the configuration of the host-only network.
+// DEPRECATED: Use (*Manager).ConfigHostonlyNet
+func (n *HostonlyNet) Config() error {
+	return defaultManager.ConfigHostonlyNet(context.Background(), n)
 }
 
 // HostonlyNets gets all host-only networks in a  map keyed by HostonlyNet.NetworkName.
+// DEPRECATED: Use (*Manager).HostonlyNets, which keys the networks by their
+// name.
 func HostonlyNets() (map[string]*HostonlyNet, error) {
-	out, _, err := Manage().run("list", "hostonlyifs")
+	out, _, err := Manage().run(context.Background(), "list", "hostonlyifs")
 	if err != nil {
 		return nil, err
 	}
-	s := bufio.NewScanner(strings.NewReader(out))
-	m := map[string]*HostonlyNet{}
-	n := &HostonlyNet{}
-	for s.Scan() {
-		line := s.Text()
-		if line == "" {
-			m[n.NetworkName] = n
-			n = &HostonlyNet{}
-			continue
-		}
-		res := reColonLine.FindStringSubmatch(line)
-		if res == nil {
-			continue
-		}
-		switch key, val := res[1], res[2]; key {
-		case "Name":
-			n.Name = val
-		case "GUID":
-			n.GUID = val
-		case "DHCP":
-			n.DHCP = (val != "Disabled")
-		case "IPAddress":
-			n.IPv4.IP = net.ParseIP(val)
-		case "NetworkMask":
-			n.IPv4.Mask = ParseIPv4Mask(val)
-		case "IPV6Address":
-			n.IPv6.IP = net.ParseIP(val)
-		case "IPV6NetworkMaskPrefixLength":
-			l, err := strconv.ParseUint(val, 10, 7)
-			if err != nil {
-				return nil, err
-			}
-			n.IPv6.Mask = net.CIDRMask(int(l), net.IPv6len*8)
-		case "HardwareAddress":
-			mac, err := net.ParseMAC(val)
-			if err != nil {
-				return nil, err
-			}
-			n.HwAddr = mac
-		case "MediumType":
-			n.Medium = val
-		case "Status":
-			n.Status = val
-		case "VBoxNetworkName":
-			n.NetworkName = val
-		}
-	}
-	if err := s.Err(); err != nil {
+	nets, err := parseHostonlyNets(out)
+	if err != nil {
 		return nil, err
 	}
+	m := map[string]*HostonlyNet{}
+	for _, n := range nets {
+		m[n.NetworkName] = n
+	}
 	return m, nil
 }
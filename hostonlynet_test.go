@@ -1,8 +1,12 @@
 package virtualbox
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
 
+	"github.com/go-test/deep"
 	"github.com/golang/mock/gomock"
 )
 
@@ -12,7 +16,7 @@ func TestHostonlyNets(t *testing.T) {
 	if ManageMock != nil {
 		listHostOnlyIfsOut := ReadTestData("vboxmanage-list-hostonlyifs-1.out")
 		gomock.InOrder(
-			ManageMock.EXPECT().run("list", "hostonlyifs").Return(listHostOnlyIfsOut, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "list", "hostonlyifs").Return(listHostOnlyIfsOut, "", nil).Times(1),
 		)
 	}
 	m, err := HostonlyNets()
@@ -25,3 +29,133 @@ func TestHostonlyNets(t *testing.T) {
 
 	Teardown()
 }
+
+const listHostonlyIfs = `Name:            vboxnet0
+GUID:            786f6276-656e-4074-8000-0a0027000000
+DHCP:            Disabled
+IPAddress:       192.168.56.1
+NetworkMask:     255.255.255.0
+IPV6Address:
+IPV6NetworkMaskPrefixLength: 0
+HardwareAddress: 0a:00:27:00:00:00
+MediumType:      Ethernet
+Status:          Down
+VBoxNetworkName: HostInterfaceNetworking-vboxnet0
+
+Name:            vboxnet1
+GUID:            786f6276-656e-4174-8000-0a0027000001
+DHCP:            Disabled
+IPAddress:       192.168.57.1
+NetworkMask:     255.255.255.0
+IPV6Address:
+IPV6NetworkMaskPrefixLength: 0
+HardwareAddress: 0a:00:27:00:00:01
+MediumType:      Ethernet
+Status:          Down
+VBoxNetworkName: HostInterfaceNetworking-vboxnet1
+
+`
+
+func TestManagerHostonlyNets(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"hostonlyif", "create"},
+			Stdout: "0%...10%...20%...30%...40%...50%...60%...70%...80%...90%...100%\nInterface 'vboxnet1' was successfully created\n"},
+		Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs},
+		Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs},
+		Call{Args: []string{"hostonlyif", "remove", "vboxnet1"}},
+		Call{Args: []string{"hostonlyif", "remove", "vboxnet1"},
+			Stderr:   "VBoxManage: error: The host network interface named 'vboxnet1' could not be found\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	n, err := m.CreateHostonlyNet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &HostonlyNet{
+		Name: "vboxnet1",
+		GUID: "786f6276-656e-4174-8000-0a0027000001",
+		IPv4: net.IPNet{
+			IP:   net.IPv4(192, 168, 57, 1),
+			Mask: net.IPv4Mask(255, 255, 255, 0),
+		},
+		IPv6:        net.IPNet{Mask: net.CIDRMask(0, 128)},
+		HwAddr:      net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x01},
+		Medium:      "Ethernet",
+		Status:      "Down",
+		NetworkName: "HostInterfaceNetworking-vboxnet1",
+	}
+	if diff := deep.Equal(n, want); diff != nil {
+		t.Errorf("CreateHostonlyNet() = %+v; diff = %v", n, diff)
+	}
+
+	nets, err := m.HostonlyNets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 2 || nets["vboxnet0"] == nil || nets["vboxnet1"] == nil {
+		t.Errorf("HostonlyNets() = %v; want vboxnet0 and vboxnet1", nets)
+	}
+
+	if err := m.RemoveHostonlyNet(ctx, "vboxnet1"); err != nil {
+		t.Errorf("RemoveHostonlyNet() = %v; want nil", err)
+	}
+	if err := m.RemoveHostonlyNet(ctx, "vboxnet1"); !errors.Is(err, ErrHostonlyInterfaceNotExist) {
+		t.Errorf("RemoveHostonlyNet() = %v; want %v", err, ErrHostonlyInterfaceNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerConfigHostonlyNet(t *testing.T) {
+	testCases := map[string]struct {
+		net   HostonlyNet
+		calls []Call
+		err   error
+	}{
+		"ipv4 and ipv6": {
+			net: HostonlyNet{
+				Name: "vboxnet0",
+				IPv4: net.IPNet{IP: net.IPv4(192, 168, 99, 1), Mask: net.CIDRMask(24, 32)},
+				IPv6: net.IPNet{IP: net.ParseIP("fd00::1"), Mask: net.CIDRMask(64, 128)},
+			},
+			calls: []Call{
+				{Args: []string{"hostonlyif", "ipconfig", "vboxnet0", "--ip", "192.168.99.1", "--netmask", "255.255.255.0"}},
+				{Args: []string{"hostonlyif", "ipconfig", "vboxnet0", "--ipv6", "fd00::1", "--netmasklengthv6", "64"}},
+			},
+		},
+		"dhcp": {
+			net: HostonlyNet{Name: "vboxnet0", DHCP: true},
+			calls: []Call{
+				{Args: []string{"hostonlyif", "ipconfig", "vboxnet0", "--dhcp"}},
+			},
+		},
+		"not exist": {
+			net: HostonlyNet{Name: "vboxnet9", DHCP: true},
+			calls: []Call{
+				{Args: []string{"hostonlyif", "ipconfig", "vboxnet9", "--dhcp"},
+					Stderr:   "VBoxManage: error: The host network interface named 'vboxnet9' could not be found\n",
+					ExitCode: 1},
+			},
+			err: ErrHostonlyInterfaceNotExist,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(tc.calls...)
+			m := NewManager(Replay(r))
+
+			err := m.ConfigHostonlyNet(context.Background(), &tc.net)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("ConfigHostonlyNet() = %v; want %v", err, tc.err)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
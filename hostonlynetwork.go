@@ -0,0 +1,179 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	reHostonlyNetworkNotFound = regexp.MustCompile(`host-only network named '(.+)' could not be found`)
+)
+
+var (
+	// ErrHostonlyNetworkExist is returned when a host-only network with the
+	// same name already exists.
+	ErrHostonlyNetworkExist = errors.New("hostonly network already exists")
+	// ErrHostonlyNetworkNotExist is returned when the host-only network does
+	// not exist.
+	ErrHostonlyNetworkNotExist = errors.New("hostonly network does not exist")
+)
+
+// HostonlyNetwork is a host-only network of VirtualBox 7.0 or newer. On macOS
+// and on the newer Linux hosts it replaces the host-only interfaces
+// (HostonlyNet): instead of an adapter with an address, the network is
+// defined by the range of the addresses given to the machines.
+//
+// The NICs are attached to it with NICNetHostonlyNet, or NICNetHostonly when
+// the same code also runs with VirtualBox 6.x, using the name of the network
+// as the NIC.HostInterface.
+type HostonlyNetwork struct {
+	Name        string
+	GUID        string
+	Enabled     bool
+	Netmask     net.IPMask
+	LowerIP     net.IP
+	UpperIP     net.IP
+	NetworkName string // referenced in DHCP.NetworkName
+}
+
+// args returns the flags of 'hostonlynet add' and 'hostonlynet modify' for the
+// network. The unset addresses are omitted.
+func (n *HostonlyNetwork) args() []string {
+	var args []string
+	if n.Netmask != nil {
+		args = append(args, "--netmask", net.IP(n.Netmask).String())
+	}
+	if n.LowerIP != nil {
+		args = append(args, "--lower-ip", n.LowerIP.String())
+	}
+	if n.UpperIP != nil {
+		args = append(args, "--upper-ip", n.UpperIP.String())
+	}
+	if n.Enabled {
+		args = append(args, "--enable")
+	} else {
+		args = append(args, "--disable")
+	}
+	return args
+}
+
+// hostonlyAttachment returns the network the NIC is attached to. On
+// VirtualBox 7.0 or newer, a NICNetHostonly NIC whose HostInterface names a
+// host-only network rather than an interface is attached with
+// NICNetHostonlyNet, so the same NIC works with the host-only interfaces of
+// 6.x and the host-only networks of 7.x. NICNetHostonlyNet itself requires
+// VirtualBox 7.0.
+func (m *Manager) hostonlyAttachment(ctx context.Context, nic NIC) (NICNetwork, error) {
+	switch nic.Network {
+	case NICNetHostonlyNet:
+		if err := m.require(ctx, CapHostonlyNet); err != nil {
+			return "", err
+		}
+	case NICNetHostonly:
+		if v, err := m.Version(ctx); err != nil || !v.Supports(CapHostonlyNet) {
+			break
+		}
+		nets, err := m.HostonlyNetworks(ctx)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := nets[nic.HostInterface]; ok {
+			return NICNetHostonlyNet, nil
+		}
+	}
+	return nic.Network, nil
+}
+
+// AddHostonlyNetwork adds the host-only network, and updates n with the
+// configuration VirtualBox has given to it.
+func (m *Manager) AddHostonlyNetwork(ctx context.Context, n *HostonlyNetwork) error {
+	if err := m.require(ctx, CapHostonlyNet); err != nil {
+		return err
+	}
+	if n.Name == "" {
+		return fmt.Errorf("hostonly network name is empty")
+	}
+	m.log.Printf("adding hostonly network %q", n.Name)
+	args := append([]string{"hostonlynet", "add", "--name", n.Name}, n.args()...)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if strings.Contains(stderr, "already exists") {
+			return ErrHostonlyNetworkExist
+		}
+		return fmt.Errorf("unable to add hostonly network: %w", err)
+	}
+	nn, err := m.HostonlyNetwork(ctx, n.Name)
+	if err != nil {
+		return err
+	}
+	*n = *nn
+	return nil
+}
+
+// ModifyHostonlyNetwork changes the address range, the netmask and the state
+// of the host-only network.
+func (m *Manager) ModifyHostonlyNetwork(ctx context.Context, n *HostonlyNetwork) error {
+	if err := m.require(ctx, CapHostonlyNet); err != nil {
+		return err
+	}
+	m.log.Printf("modifying hostonly network %q", n.Name)
+	args := append([]string{"hostonlynet", "modify", "--name", n.Name}, n.args()...)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if reHostonlyNetworkNotFound.MatchString(stderr) {
+			return ErrHostonlyNetworkNotExist
+		}
+		return fmt.Errorf("unable to modify hostonly network: %w", err)
+	}
+	return nil
+}
+
+// HostonlyNetworks returns all host-only networks keyed by their name.
+func (m *Manager) HostonlyNetworks(ctx context.Context) (map[string]*HostonlyNetwork, error) {
+	if err := m.require(ctx, CapHostonlyNet); err != nil {
+		return nil, err
+	}
+	stdout, _, err := m.run(ctx, "list", "hostonlynets")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list hostonly networks: %w", err)
+	}
+	nets, err := parseHostonlyNetworks(stdout)
+	if err != nil {
+		return nil, err
+	}
+	hm := make(map[string]*HostonlyNetwork, len(nets))
+	for _, n := range nets {
+		hm[n.Name] = n
+	}
+	return hm, nil
+}
+
+// HostonlyNetwork returns the host-only network with the given name.
+func (m *Manager) HostonlyNetwork(ctx context.Context, name string) (*HostonlyNetwork, error) {
+	nets, err := m.HostonlyNetworks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := nets[name]
+	if !ok {
+		return nil, ErrHostonlyNetworkNotExist
+	}
+	return n, nil
+}
+
+// RemoveHostonlyNetwork removes the host-only network with the given name.
+func (m *Manager) RemoveHostonlyNetwork(ctx context.Context, name string) error {
+	if err := m.require(ctx, CapHostonlyNet); err != nil {
+		return err
+	}
+	m.log.Printf("removing hostonly network %q", name)
+	if _, stderr, err := m.run(ctx, "hostonlynet", "remove", "--name", name); err != nil {
+		if reHostonlyNetworkNotFound.MatchString(stderr) {
+			return ErrHostonlyNetworkNotExist
+		}
+		return fmt.Errorf("unable to remove hostonly network: %w", err)
+	}
+	return nil
+}
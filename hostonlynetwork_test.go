@@ -0,0 +1,139 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+const listHostonlyNets = `Name:            HostNetwork
+GUID:            f9d5c3a4-5b8e-4a9c-9c2e-1d2f4b8a7e61
+State:           Enabled
+NetworkMask:     255.255.255.0
+LowerIP:         192.168.56.2
+UpperIP:         192.168.56.199
+VBoxNetworkName: hostonly-HostNetwork
+
+`
+
+func TestParseHostonlyNetworks(t *testing.T) {
+	nets, err := parseHostonlyNetworks(listHostonlyNets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*HostonlyNetwork{{
+		Name:        "HostNetwork",
+		GUID:        "f9d5c3a4-5b8e-4a9c-9c2e-1d2f4b8a7e61",
+		Enabled:     true,
+		Netmask:     net.IPv4Mask(255, 255, 255, 0),
+		LowerIP:     net.IPv4(192, 168, 56, 2),
+		UpperIP:     net.IPv4(192, 168, 56, 199),
+		NetworkName: "hostonly-HostNetwork",
+	}}
+	if diff := deep.Equal(nets, want); diff != nil {
+		t.Errorf("parseHostonlyNetworks() = %+v; diff = %v", nets, diff)
+	}
+}
+
+func TestManagerHostonlyNetworks(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+		Call{Args: []string{"hostonlynet", "add", "--name", "HostNetwork", "--netmask", "255.255.255.0",
+			"--lower-ip", "192.168.56.2", "--upper-ip", "192.168.56.199", "--enable"}},
+		Call{Args: []string{"list", "hostonlynets"}, Stdout: listHostonlyNets},
+		Call{Args: []string{"hostonlynet", "modify", "--name", "HostNetwork", "--disable"}},
+		Call{Args: []string{"hostonlynet", "remove", "--name", "HostNetwork"}},
+		Call{Args: []string{"hostonlynet", "remove", "--name", "HostNetwork"},
+			Stderr:   "VBoxManage: error: The host-only network named 'HostNetwork' could not be found\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	n := &HostonlyNetwork{
+		Name:    "HostNetwork",
+		Enabled: true,
+		Netmask: net.CIDRMask(24, 32),
+		LowerIP: net.IPv4(192, 168, 56, 2),
+		UpperIP: net.IPv4(192, 168, 56, 199),
+	}
+	if err := m.AddHostonlyNetwork(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if n.GUID == "" || n.NetworkName != "hostonly-HostNetwork" {
+		t.Errorf("AddHostonlyNetwork() network = %+v; want the GUID and network name", n)
+	}
+	if err := m.ModifyHostonlyNetwork(ctx, &HostonlyNetwork{Name: "HostNetwork"}); err != nil {
+		t.Errorf("ModifyHostonlyNetwork() = %v; want nil", err)
+	}
+	if err := m.RemoveHostonlyNetwork(ctx, "HostNetwork"); err != nil {
+		t.Errorf("RemoveHostonlyNetwork() = %v; want nil", err)
+	}
+	if err := m.RemoveHostonlyNetwork(ctx, "HostNetwork"); !errors.Is(err, ErrHostonlyNetworkNotExist) {
+		t.Errorf("RemoveHostonlyNetwork() = %v; want %v", err, ErrHostonlyNetworkNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerHostonlyNetworksUnsupported(t *testing.T) {
+	r := NewReplayer(Call{Args: []string{"--version"}, Stdout: "6.1.38r153438\n"})
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	if _, err := m.HostonlyNetworks(ctx); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("HostonlyNetworks() = %v; want %v", err, ErrUnsupported)
+	}
+	vm := &Machine{Name: "go-virtualbox", NICs: []NIC{{Network: NICNetHostonlyNet, Hardware: VirtIO, HostInterface: "HostNetwork"}}}
+	if err := m.ModifyMachine(ctx, vm); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ModifyMachine() = %v; want %v", err, ErrUnsupported)
+	}
+}
+
+func TestManagerHostonlyAttachment(t *testing.T) {
+	saved := ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")
+	showvminfo := Call{Args: []string{"showvminfo", "go-virtualbox", "--machinereadable"}, Stdout: saved}
+	listNets := Call{Args: []string{"list", "hostonlynets"}, Stdout: listHostonlyNets}
+	testCases := map[string]struct {
+		version string
+		host    string
+		calls   []Call
+	}{
+		"network on 7.0": {
+			version: "7.0.10r158379\n",
+			host:    "HostNetwork",
+			calls: []Call{listNets, showvminfo,
+				{Args: []string{"modifyvm", "go-virtualbox", "--nic1", "hostonlynet", "--host-only-net1", "HostNetwork"}}},
+		},
+		"interface on 7.0": {
+			version: "7.0.10r158379\n",
+			host:    "vboxnet0",
+			calls: []Call{listNets, showvminfo,
+				{Args: []string{"modifyvm", "go-virtualbox", "--nic1", "hostonly", "--host-only-adapter1", "vboxnet0"}}},
+		},
+		"interface on 6.1": {
+			version: "6.1.40r156084\n",
+			host:    "vboxnet0",
+			calls: []Call{showvminfo,
+				{Args: []string{"modifyvm", "go-virtualbox", "--nic1", "hostonly", "--hostonlyadapter1", "vboxnet0"}}},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(append([]Call{{Args: []string{"--version"}, Stdout: tc.version}}, tc.calls...)...)
+			m := NewManager(Replay(r))
+			nic := NIC{Network: NICNetHostonly, HostInterface: tc.host}
+			if err := m.SetNIC(context.Background(), "go-virtualbox", 1, nic); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package virtualbox
 
+import "context"
+
 // ImportOV imports ova or ovf from the given path
 func ImportOV(path string) error {
-	_, _, err := Manage().run("import", path)
+	_, _, err := Manage().run(context.Background(), "import", path)
 	return err
 }
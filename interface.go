@@ -5,10 +5,23 @@ import "context"
 // Virtualbox interface defines all the actions which can be performed by the
 // Manager. This is mostly a utility interface designed for the customers of the
 // package.
+//
+// It only covers the machines, so the existing implementations keep compiling.
+// The networks and the host are managed through the separate interfaces below,
+// which the Manager implements as well.
 type Virtualbox interface {
 	MachineManager
 }
 
+var (
+	_ Virtualbox             = (*Manager)(nil)
+	_ HostonlyNetManager     = (*Manager)(nil)
+	_ HostonlyNetworkManager = (*Manager)(nil)
+	_ NATNetManager          = (*Manager)(nil)
+	_ DHCPManager            = (*Manager)(nil)
+	_ HostManager            = (*Manager)(nil)
+)
+
 // MachineManager defines the actions that can be performed to manage machines
 type MachineManager interface {
 	// Machine gets a machine name based on its name or UUID
@@ -29,3 +42,119 @@ type MachineManager interface {
 	// DeleteMachine deletes a machine by its name or UUID
 	DeleteMachine(context.Context, string) error
 }
+
+// HostonlyNetManager defines the actions that can be performed to manage
+// host-only interfaces
+type HostonlyNetManager interface {
+	// CreateHostonlyNet creates a new host-only interface
+	CreateHostonlyNet(context.Context) (*HostonlyNet, error)
+
+	// ConfigHostonlyNet changes the addresses or DHCP of the interface
+	ConfigHostonlyNet(context.Context, *HostonlyNet) error
+
+	// HostonlyNets returns all host-only interfaces keyed by their name
+	HostonlyNets(context.Context) (map[string]*HostonlyNet, error)
+
+	// HostonlyNet gets a host-only interface by its name
+	HostonlyNet(context.Context, string) (*HostonlyNet, error)
+
+	// RemoveHostonlyNet removes the host-only interface with the given name
+	RemoveHostonlyNet(context.Context, string) error
+}
+
+// HostonlyNetworkManager defines the actions that can be performed to manage
+// the host-only networks of VirtualBox 7.0 or newer
+type HostonlyNetworkManager interface {
+	// AddHostonlyNetwork adds a new host-only network
+	AddHostonlyNetwork(context.Context, *HostonlyNetwork) error
+
+	// ModifyHostonlyNetwork changes the address range or state of the network
+	ModifyHostonlyNetwork(context.Context, *HostonlyNetwork) error
+
+	// HostonlyNetworks returns all host-only networks keyed by their name
+	HostonlyNetworks(context.Context) (map[string]*HostonlyNetwork, error)
+
+	// HostonlyNetwork gets a host-only network by its name
+	HostonlyNetwork(context.Context, string) (*HostonlyNetwork, error)
+
+	// RemoveHostonlyNetwork removes the host-only network with the given name
+	RemoveHostonlyNetwork(context.Context, string) error
+}
+
+// NATNetManager defines the actions that can be performed to manage NAT
+// networks
+type NATNetManager interface {
+	// AddNATNet adds a new NAT network
+	AddNATNet(context.Context, NATNet) error
+
+	// ModifyNATNet changes the NAT network to match the given one
+	ModifyNATNet(context.Context, NATNet) error
+
+	// NATNets returns all NAT networks keyed by their name
+	NATNets(context.Context) (map[string]NATNet, error)
+
+	// NATNet gets a NAT network by its name
+	NATNet(context.Context, string) (NATNet, error)
+
+	// RemoveNATNet removes the NAT network with the given name
+	RemoveNATNet(context.Context, string) error
+
+	// StartNATNet starts the services of the NAT network
+	StartNATNet(context.Context, string) error
+
+	// StopNATNet stops the services of the NAT network
+	StopNATNet(context.Context, string) error
+}
+
+// DHCPManager defines the actions that can be performed to manage the DHCP
+// servers
+type DHCPManager interface {
+	// AddDHCP adds a DHCP server to a network
+	AddDHCP(context.Context, DHCP) error
+
+	// ModifyDHCP changes the settings and options of the DHCP server
+	ModifyDHCP(context.Context, DHCP) error
+
+	// EnableDHCP enables the DHCP server of the network
+	EnableDHCP(context.Context, string) error
+
+	// DisableDHCP disables the DHCP server of the network
+	DisableDHCP(context.Context, string) error
+
+	// DHCPs returns all DHCP servers keyed by their network name
+	DHCPs(context.Context) (map[string]*DHCP, error)
+
+	// RemoveDHCP removes the DHCP server of the network
+	RemoveDHCP(context.Context, string) error
+
+	// StartDHCP starts the DHCP server of the network
+	StartDHCP(context.Context, string) error
+
+	// StopDHCP stops the DHCP server of the network
+	StopDHCP(context.Context, string) error
+
+	// RestartDHCP restarts the DHCP server of the network
+	RestartDHCP(context.Context, string) error
+
+	// FindLease returns the lease of the MAC address in the network
+	FindLease(ctx context.Context, network, mac string) (*DHCPLease, error)
+}
+
+// HostManager defines the actions that can be performed to inspect the host
+type HostManager interface {
+	// BridgedInterfaces returns the network interfaces of the host keyed by
+	// their name
+	BridgedInterfaces(context.Context) (map[string]*HostInterface, error)
+
+	// BridgedInterface gets a network interface of the host by its name
+	BridgedInterface(context.Context, string) (*HostInterface, error)
+
+	// DefaultBridgedInterface selects the network interface to bridge to
+	DefaultBridgedInterface(context.Context) (*HostInterface, error)
+
+	// IntNets returns the names of the internal networks
+	IntNets(context.Context) ([]string, error)
+
+	// HostInfo returns the information about the host
+	HostInfo(context.Context) (*HostInfo, error)
+}
@@ -0,0 +1,186 @@
+package virtualbox
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// reUnknownSubcommand matches the errors of VBoxManage for the subcommands
+// it does not know, e.g. findlease of the builds which predate it.
+var reUnknownSubcommand = regexp.MustCompile(`(?i)syntax error|unknown subcommand|invalid parameter`)
+
+// FindLease returns the lease of the DHCP server of the network for the MAC
+// address. The network is the DHCP.NetworkName, e.g.
+// HostInterfaceNetworking-vboxnet0 or the name of a NAT network, and the MAC
+// address can be given as NIC.MacAddr.
+//
+// It uses 'dhcpserver findlease' when it is available, and otherwise reads
+// the leases file which the DHCP server keeps in the VirtualBox home. The
+// other failures of findlease, or a done context, are returned as is.
+func (m *Manager) FindLease(ctx context.Context, network, mac string) (*DHCPLease, error) {
+	lease, err := m.findLease(ctx, network, mac)
+	if err == nil || ctx.Err() != nil || !errors.Is(err, ErrUnsupported) {
+		return lease, err
+	}
+	m.log.Printf("reading the DHCP leases file: %v", err)
+	name, herr := m.leasesFile(network)
+	if herr != nil {
+		return nil, herr
+	}
+	return findLeaseInFile(name, mac)
+}
+
+func (m *Manager) findLease(ctx context.Context, network, mac string) (*DHCPLease, error) {
+	if err := m.require(ctx, CapDHCPFindLease); err != nil {
+		return nil, err
+	}
+	stdout, stderr, err := m.run(ctx, "dhcpserver", "findlease", "--network", network, "--mac-address", formatMAC(mac))
+	if err != nil {
+		if reDHCPLeaseNotFound.MatchString(stderr) {
+			return nil, ErrLeaseNotFound
+		}
+		if reDHCPNotFound.MatchString(stderr) {
+			return nil, ErrDHCPNotExist
+		}
+		if reUnknownSubcommand.MatchString(stderr) {
+			return nil, fmt.Errorf("dhcpserver findlease: %w", ErrUnsupported)
+		}
+		return nil, fmt.Errorf("unable to find DHCP lease: %w", err)
+	}
+	return parseDHCPLease(stdout)
+}
+
+// leasesFile returns the path of the leases file of the network, it is
+// <network>-Dhcpd.leases in the VirtualBox home.
+func (m *Manager) leasesFile(network string) (string, error) {
+	home, err := m.userHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, network+"-Dhcpd.leases"), nil
+}
+
+// userHome returns the VirtualBox home used by the manager: VBOX_USER_HOME
+// in the environment of the manager or of the process, or the default of the
+// host OS.
+func (m *Manager) userHome() (string, error) {
+	for i := len(m.cmd.env) - 1; i >= 0; i-- {
+		if dir := strings.TrimPrefix(m.cmd.env[i], "VBOX_USER_HOME="); dir != m.cmd.env[i] {
+			return dir, nil
+		}
+	}
+	if dir := os.Getenv("VBOX_USER_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the VirtualBox home: %w", err)
+	}
+	switch runtime.GOOS {
+	case osWindows:
+		return filepath.Join(home, ".VirtualBox"), nil
+	case "darwin":
+		return filepath.Join(home, "Library", "VirtualBox"), nil
+	}
+	// The home of the old versions is still used when it exists.
+	if fi, err := os.Stat(filepath.Join(home, ".VirtualBox")); err == nil && fi.IsDir() {
+		return filepath.Join(home, ".VirtualBox"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "VirtualBox"), nil
+	}
+	return filepath.Join(home, ".config", "VirtualBox"), nil
+}
+
+// findLeaseInFile returns the lease of the MAC address from the leases file.
+func findLeaseInFile(name, mac string) (*DHCPLease, error) {
+	data, err := os.ReadFile(name) // #nosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrLeaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read DHCP leases: %w", err)
+	}
+	leases, err := parseDHCPLeases(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+	mac = formatMAC(mac)
+	for i := range leases {
+		if strings.EqualFold(leases[i].MAC, mac) {
+			return &leases[i], nil
+		}
+	}
+	return nil, ErrLeaseNotFound
+}
+
+// dhcpLeasesXML is the leases file written by VBoxNetDHCP, e.g.
+//
+//	<Leases version="1.0">
+//	  <Lease mac="08:00:27:ee:1d:f7" id="01080027ee1df7" state="acked">
+//	    <Address value="192.168.56.101"/>
+//	    <Time issued="1672653600" expiration="600"/>
+//	  </Lease>
+//	</Leases>
+type dhcpLeasesXML struct {
+	Leases []struct {
+		MAC     string `xml:"mac,attr"`
+		State   string `xml:"state,attr"`
+		Address struct {
+			Value string `xml:"value,attr"`
+		} `xml:"Address"`
+		Time struct {
+			Issued     int64 `xml:"issued,attr"`
+			Expiration int64 `xml:"expiration,attr"`
+		} `xml:"Time"`
+	} `xml:"Lease"`
+}
+
+// parseDHCPLeases parses the leases file of VBoxNetDHCP. The expiration is
+// stored as the duration of the lease after it was issued.
+func parseDHCPLeases(data []byte) ([]DHCPLease, error) {
+	var x dhcpLeasesXML
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	leases := make([]DHCPLease, 0, len(x.Leases))
+	for _, l := range x.Leases {
+		lease := DHCPLease{
+			IP:     net.ParseIP(l.Address.Value),
+			MAC:    l.MAC,
+			State:  l.State,
+			Issued: time.Unix(l.Time.Issued, 0),
+			Expire: time.Unix(l.Time.Issued+l.Time.Expiration, 0),
+		}
+		if lease.IP == nil {
+			return nil, fmt.Errorf("invalid address %q of the lease of %s", l.Address.Value, l.MAC)
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// formatMAC returns the MAC address in the lower case, colon separated form
+// used by the DHCP server. The NICs of the machines have it without the
+// separators, e.g. 080027EE1DF7.
+func formatMAC(mac string) string {
+	hex := strings.NewReplacer(":", "", "-", "").Replace(mac)
+	if len(hex) != 12 {
+		return mac
+	}
+	parts := make([]string, 6)
+	for i := range parts {
+		parts[i] = hex[2*i : 2*i+2]
+	}
+	return strings.ToLower(strings.Join(parts, ":"))
+}
@@ -0,0 +1,135 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseDHCPLeases(t *testing.T) {
+	leases, err := parseDHCPLeases([]byte(ReadTestData("HostInterfaceNetworking-vboxnet0-Dhcpd.leases")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DHCPLease{
+		{
+			IP:     net.ParseIP("192.168.56.101"),
+			MAC:    "08:00:27:ee:1d:f7",
+			State:  "acked",
+			Issued: time.Unix(1672653600, 0),
+			Expire: time.Unix(1672654200, 0),
+		},
+		{
+			IP:     net.ParseIP("192.168.56.102"),
+			MAC:    "08:00:27:aa:bb:cc",
+			State:  "released",
+			Issued: time.Unix(1672650000, 0),
+			Expire: time.Unix(1672651200, 0),
+		},
+	}
+	if diff := deep.Equal(leases, want); diff != nil {
+		t.Errorf("parseDHCPLeases() = %+v; diff = %v", leases, diff)
+	}
+
+	if _, err := parseDHCPLeases([]byte(`<Leases><Lease mac="x"><Address value="bad"/></Lease></Leases>`)); err == nil {
+		t.Error("parseDHCPLeases() of an invalid address succeeded")
+	}
+}
+
+func TestFindLeaseFallback(t *testing.T) {
+	const network = "HostInterfaceNetworking-vboxnet0"
+	home := t.TempDir()
+	data := ReadTestData(network + "-Dhcpd.leases")
+	if err := os.WriteFile(filepath.Join(home, network+"-Dhcpd.leases"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		calls    []Call
+		mac      string
+		canceled bool
+		ip       net.IP
+		err      error
+		fail     bool
+	}{
+		"unsupported": {
+			calls: []Call{{Args: []string{"--version"}, Stdout: "6.0.24r139119\n"}},
+			mac:   "080027EE1DF7",
+			ip:    net.ParseIP("192.168.56.101"),
+		},
+		"unknown subcommand": {
+			calls: []Call{
+				{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+				{Args: []string{"dhcpserver", "findlease", "--network", network, "--mac-address", "08:00:27:aa:bb:cc"},
+					Stderr: "VBoxManage: error: Unknown subcommand: findlease\n", ExitCode: 2},
+			},
+			mac: "08:00:27:AA:BB:CC",
+			ip:  net.ParseIP("192.168.56.102"),
+		},
+		"findlease failed": {
+			calls: []Call{
+				{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+				{Args: []string{"dhcpserver", "findlease", "--network", network, "--mac-address", "08:00:27:aa:bb:cc"},
+					Stderr: "VBoxManage: error: Code E_FAIL (0x80004005)\n", ExitCode: 1},
+			},
+			mac:  "08:00:27:AA:BB:CC",
+			fail: true,
+		},
+		"canceled": {
+			mac:      "080027EE1DF7",
+			canceled: true,
+			err:      context.Canceled,
+		},
+		"not in file": {
+			calls: []Call{{Args: []string{"--version"}, Stdout: "6.0.24r139119\n"}},
+			mac:   "080027000001",
+			err:   ErrLeaseNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(tc.calls...)
+			m := NewManager(Replay(r), UserHome(home))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.canceled {
+				cancel()
+			}
+			lease, err := m.FindLease(ctx, network, tc.mac)
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("FindLease() = %+v; want error", lease)
+				}
+			} else if !errors.Is(err, tc.err) {
+				t.Fatalf("FindLease() = %v; want %v", err, tc.err)
+			}
+			if err == nil && !lease.IP.Equal(tc.ip) {
+				t.Errorf("FindLease() IP = %s; want %s", lease.IP, tc.ip)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFormatMAC(t *testing.T) {
+	for in, want := range map[string]string{
+		"080027EE1DF7":      "08:00:27:ee:1d:f7",
+		"08:00:27:ee:1d:f7": "08:00:27:ee:1d:f7",
+		"08-00-27-EE-1D-F7": "08:00:27:ee:1d:f7",
+		"invalid":           "invalid",
+	} {
+		if got := formatMAC(in); got != want {
+			t.Errorf("formatMAC(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package virtualbox
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var k keyedMutex
+
+	// Different keys must not block each other.
+	unlockA := k.lock("a")
+	unlockB := k.lock("b")
+	unlockB()
+
+	// The same key must block until released.
+	locked := make(chan struct{})
+	go func() {
+		unlock := k.lock("a")
+		close(locked)
+		unlock()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("second lock of the same key was acquired while held")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlockA()
+	<-locked
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k.lock("c")()
+		}()
+	}
+	wg.Wait()
+
+	if n := len(k.locks); n != 0 {
+		t.Errorf("len(locks) = %d after all were released; want 0", n)
+	}
+}
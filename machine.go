@@ -1,13 +1,11 @@
 package virtualbox
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,13 +15,12 @@ func (m *Manager) Machine(ctx context.Context, id string) (*Machine, error) {
 	m.log.Printf("getting information for %q", id)
 	// There is a strage behavior where running multiple instances of
 	// 'VBoxManage showvminfo' on same VM simultaneously can return an error of
-	// 'object is not ready (E_ACCESSDENIED)', so we sequential the operation with a mutex.
+	// 'object is not ready (E_ACCESSDENIED)', so we sequential the operation
+	// with the lock of the machine.
 	// Note if you are running multiple process of go-virtualbox or 'showvminfo'
 	// in the command line side by side, this not gonna work.
 	// TODO: Verify the above is still true.
-	m.lock.Lock()
-	stdout, stderr, err := m.run(ctx, "showvminfo", id, "--machinereadable")
-	m.lock.Unlock()
+	stdout, stderr, err := m.runMachine(ctx, id, "showvminfo", id, "--machinereadable")
 	if err != nil {
 		if reMachineNotFound.FindString(stderr) != "" {
 			return nil, ErrMachineNotExist
@@ -31,133 +28,65 @@ func (m *Manager) Machine(ctx context.Context, id string) (*Machine, error) {
 		return nil, err
 	}
 
-	/* Read all VM info into a map */
-	props := make(map[string]string)
-	s := bufio.NewScanner(strings.NewReader(stdout))
-	for s.Scan() {
-		res := reVMInfoLine.FindStringSubmatch(s.Text())
-		if res == nil {
-			continue
-		}
-		key := res[1]
-		if key == "" {
-			key = res[2]
-		}
-		val := res[3]
-		if val == "" {
-			val = res[4]
-		}
-		props[key] = val
-	}
-
-	if err := s.Err(); err != nil {
-		return nil, fmt.Errorf("unable to scan all fields: %w", err)
-	}
-
-	// error that occured during parsing
-	var perr error
-
-	sp := func(field string, def ...string) string {
-		if v, exists := props[field]; exists {
-			return v
-		}
-		if len(def) < 1 {
-			return ""
-		}
-		return def[0]
-	}
-
-	up := func(field string, def ...uint) uint {
-		if v, exists := props[field]; exists {
-			n, err := strconv.ParseUint(v, 10, 32)
-			if err != nil {
-				perr = err
-				return 0
-			}
-			return uint(n)
-		}
-		if len(def) < 1 {
-			return 0
-		}
-		return def[0]
-	}
-
-	/* Extract basic info */
-	vm := &Machine{
-		// TODO: This was in New, verify is this still correct.
-		BootOrder:  make([]string, 0, 4),
-		NICs:       make([]NIC, 0, 4),
-		Name:       sp("name"),
-		Firmware:   sp("firmware"),
-		UUID:       sp("UUID"),
-		State:      MachineState(sp("VMState")),
-		Memory:     up("memory"),
-		CPUs:       up("cpus"),
-		VRAM:       up("vram"),
-		CfgFile:    sp("CfgFile"),
-		BaseFolder: filepath.Dir(sp("CfgFile")),
-	}
-
-	/* Extract NIC info */
-	for i := 1; i <= 4; i++ {
-		var nic NIC
-		nicType, ok := props[fmt.Sprintf("nic%d", i)]
-		if !ok || nicType == "none" {
-			break
-		}
-		nic.Network = NICNetwork(nicType)
-		nic.Hardware = NICHardware(props[fmt.Sprintf("nictype%d", i)])
-		if nic.Hardware == "" {
-			return nil, fmt.Errorf("Could not find corresponding 'nictype%d'", i)
-		}
-		nic.MacAddr = props[fmt.Sprintf("macaddress%d", i)]
-		if nic.MacAddr == "" {
-			return nil, fmt.Errorf("Could not find corresponding 'macaddress%d'", i)
-		}
-		if nic.Network == NICNetHostonly {
-			nic.HostInterface = props[fmt.Sprintf("hostonlyadapter%d", i)]
-		} else if nic.Network == NICNetBridged {
-			nic.HostInterface = props[fmt.Sprintf("bridgeadapter%d", i)]
-		}
-		vm.NICs = append(vm.NICs, nic)
-	}
-
-	if perr != nil {
-		return nil, fmt.Errorf("parsing machine props failed: %w", perr)
-	}
-
-	return vm, nil
+	return parseMachine(stdout)
 }
 
-// ListMachines returns the list of the machines
+// ListMachines returns the list of the machines. The details of the machines
+// are fetched in parallel, bounded by the concurrency limit of the manager.
 func (m *Manager) ListMachines(ctx context.Context) ([]*Machine, error) {
 	m.log.Println("listing vms")
 	stdout, _, err := m.run(ctx, "list", "vms")
 	if err != nil {
 		return nil, fmt.Errorf("unable to list vms: %w", err)
 	}
-	vms := []*Machine{}
-	s := bufio.NewScanner(strings.NewReader(stdout))
-	for s.Scan() {
-		res := reVMNameUUID.FindStringSubmatch(s.Text())
-		if res == nil {
-			continue
-		}
-		m, err := m.Machine(ctx, res[1])
-		if err != nil {
-			// Sometimes a VM is listed but not available, so we need to handle this.
-			if errors.Is(err, ErrMachineNotExist) {
-				continue
-			} else {
-				return nil, fmt.Errorf("unable to get machine info: %w", err)
+	names := parseMachineList(stdout)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Every worker writes only to its own slots, so the order of the list is
+	// kept without any further synchronisation. The first failure is recorded
+	// before the other workers are cancelled, so their context errors do not
+	// mask it.
+	vms := make([]*Machine, len(names))
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < m.maxProcs && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				vm, err := m.Machine(ctx, names[i])
+				// Sometimes a VM is listed but not available, so we need to
+				// handle this.
+				if err != nil && !errors.Is(err, ErrMachineNotExist) {
+					once.Do(func() { firstErr = err })
+					cancel()
+				}
+				vms[i] = vm
 			}
-		}
-		vms = append(vms, m)
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("unable to get machine info: %w", firstErr)
 	}
-	if err := s.Err(); err != nil {
-		return nil, fmt.Errorf("error reading machine list: %w", err)
+	list := make([]*Machine, 0, len(vms))
+	for _, vm := range vms {
+		if vm != nil {
+			list = append(list, vm)
+		}
 	}
-	return vms, nil
+	return list, nil
 }
 
 // ModifyMachine modifies the data of the machine
@@ -197,23 +126,45 @@ func (m *Manager) ModifyMachine(ctx context.Context, vm *Machine) error {
 		args = append(args, fmt.Sprintf("--boot%d", i+1), dev)
 	}
 
+	// When the version is unknown the legacy spelling of the flags is used,
+	// it is still accepted by the newer versions.
+	v, err := m.Version(ctx)
+	if err != nil {
+		m.log.Printf("using legacy modifyvm flags: %v", err)
+	}
 	for i, nic := range vm.NICs {
 		n := i + 1
+		network, err := m.hostonlyAttachment(ctx, nic)
+		if err != nil {
+			return fmt.Errorf("nic%d: %w", n, err)
+		}
 		args = append(args,
-			fmt.Sprintf("--nic%d", n), string(nic.Network),
-			fmt.Sprintf("--nictype%d", n), string(nic.Hardware),
-			fmt.Sprintf("--cableconnected%d", n), "on")
-		if nic.Network == NICNetHostonly {
-			args = append(args, fmt.Sprintf("--hostonlyadapter%d", n), nic.HostInterface)
+			fmt.Sprintf("--nic%d", n), string(network),
+			v.flag("nictype", n), string(nic.Hardware),
+			v.flag("cableconnected", n), "on")
+		if network == NICNetHostonly {
+			args = append(args, v.flag("hostonlyadapter", n), nic.HostInterface)
+		} else if network == NICNetHostonlyNet {
+			args = append(args, fmt.Sprintf("--host-only-net%d", n), nic.HostInterface)
+		} else if nic.Network == NICNetNATNetwork {
+			args = append(args, fmt.Sprintf("--nat-network%d", n), nic.HostInterface)
 		} else if nic.Network == NICNetBridged {
-			args = append(args, fmt.Sprintf("--bridgeadapter%d", n), nic.HostInterface)
+			args = append(args, v.flag("bridgeadapter", n), nic.HostInterface)
+		}
+		if nic.BandwidthGroup != "" {
+			args = append(args, v.flag("nicbandwidthgroup", n), nic.BandwidthGroup)
 		}
 	}
 
-	if _, _, err := m.run(ctx, args...); err != nil {
+	if _, _, err := m.runMachine(ctx, vm.Name, args...); err != nil {
+		return err
+	}
+	mm, err := m.Machine(ctx, vm.Name)
+	if err != nil {
 		return err
 	}
-	return vm.Refresh()
+	*vm = *mm
+	return nil
 }
 
 // StartMachine will start the machine based on its current state.
@@ -232,7 +183,7 @@ func (m *Manager) StartMachine(ctx context.Context, id string) error {
 		args = []string{"startvm", id, "--type", "headless"}
 	}
 
-	_, msg, err := m.run(ctx, args...)
+	_, msg, err := m.runMachine(ctx, id, args...)
 	if err != nil {
 		return errors.New(msg)
 	}
@@ -240,6 +191,52 @@ func (m *Manager) StartMachine(ctx context.Context, id string) error {
 	return nil
 }
 
+// CreateMachine creates and registers a new machine with the name, base
+// folder, OS type and UUID of the given machine, and updates it with the
+// information of the created one. The rest of the settings can be applied
+// with ModifyMachine.
+func (m *Manager) CreateMachine(ctx context.Context, vm *Machine) error {
+	if vm.Name == "" {
+		return fmt.Errorf("machine name is empty")
+	}
+	args := []string{"createvm", "--name", vm.Name, "--register"}
+	if vm.BaseFolder != "" {
+		args = append(args, "--basefolder", vm.BaseFolder)
+	}
+	if vm.OSType != "" {
+		args = append(args, "--ostype", vm.OSType)
+	}
+	if vm.UUID != "" {
+		args = append(args, "--uuid", vm.UUID)
+	}
+	_, stderr, err := m.run(ctx, args...)
+	if err != nil {
+		if strings.Contains(stderr, "already exists") {
+			return ErrMachineExist
+		}
+		return fmt.Errorf("unable to create machine: %w", err)
+	}
+	mm, err := m.Machine(ctx, vm.Name)
+	if err != nil {
+		return err
+	}
+	*vm = *mm
+	return nil
+}
+
+// DeleteMachine unregisters the machine and deletes all its files, including
+// the disk images.
+func (m *Manager) DeleteMachine(ctx context.Context, id string) error {
+	_, stderr, err := m.runMachine(ctx, id, "unregistervm", id, "--delete")
+	if err != nil {
+		if reMachineNotFound.FindString(stderr) != "" {
+			return ErrMachineNotExist
+		}
+		return fmt.Errorf("unable to delete machine: %w", err)
+	}
+	return nil
+}
+
 // MachineState stores the last retrieved VM state.
 type MachineState string
 
@@ -336,7 +333,7 @@ func (m *Machine) Start() error {
 
 // DisconnectSerialPort sets given serial port to disconnected.
 func (m *Machine) DisconnectSerialPort(portNumber int) error {
-	_, _, err := Manage().run("modifyvm", m.Name, fmt.Sprintf("--uartmode%d", portNumber), "disconnected")
+	_, _, err := Manage().run(context.Background(), "modifyvm", m.Name, fmt.Sprintf("--uartmode%d", portNumber), "disconnected")
 	return err
 }
 
@@ -350,7 +347,7 @@ func (m *Machine) Save() error {
 	case Poweroff, Aborted, Saved:
 		return nil
 	}
-	_, _, err := Manage().run("controlvm", m.Name, "savestate")
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, "savestate")
 	return err
 }
 
@@ -360,7 +357,7 @@ func (m *Machine) Pause() error {
 	case Paused, Poweroff, Aborted, Saved:
 		return nil
 	}
-	_, _, err := Manage().run("controlvm", m.Name, "pause")
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, "pause")
 	return err
 }
 
@@ -376,7 +373,7 @@ func (m *Machine) Stop() error {
 	}
 
 	for m.State != Poweroff { // busy wait until the machine is stopped
-		if _, _, err := Manage().run("controlvm", m.Name, "acpipowerbutton"); err != nil {
+		if _, _, err := Manage().run(context.Background(), "controlvm", m.Name, "acpipowerbutton"); err != nil {
 			return err
 		}
 		time.Sleep(1 * time.Second)
@@ -393,7 +390,7 @@ func (m *Machine) Poweroff() error {
 	case Poweroff, Aborted, Saved:
 		return nil
 	}
-	_, _, err := Manage().run("controlvm", m.Name, "poweroff")
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, "poweroff")
 	return err
 }
 
@@ -419,7 +416,7 @@ func (m *Machine) Reset() error {
 			return err
 		}
 	}
-	_, _, err := Manage().run("controlvm", m.Name, "reset")
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, "reset")
 	return err
 }
 
@@ -428,7 +425,7 @@ func (m *Machine) Delete() error {
 	if err := m.Poweroff(); err != nil {
 		return err
 	}
-	_, _, err := Manage().run("unregistervm", m.Name, "--delete")
+	_, _, err := Manage().run(context.Background(), "unregistervm", m.Name, "--delete")
 	return err
 }
 
@@ -466,7 +463,7 @@ func CreateMachine(name, basefolder string) (*Machine, error) {
 	if basefolder != "" {
 		args = append(args, "--basefolder", basefolder)
 	}
-	if _, _, err = Manage().run(args...); err != nil {
+	if _, _, err = Manage().run(context.Background(), args...); err != nil {
 		return nil, err
 	}
 
@@ -486,14 +483,14 @@ func (m *Machine) Modify() error {
 
 // AddNATPF adds a NAT port forarding rule to the n-th NIC with the given name.
 func (m *Machine) AddNATPF(n int, name string, rule PFRule) error {
-	_, _, err := Manage().run("controlvm", m.Name, fmt.Sprintf("natpf%d", n),
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, fmt.Sprintf("natpf%d", n),
 		fmt.Sprintf("%s,%s", name, rule.Format()))
 	return err
 }
 
 // DelNATPF deletes the NAT port forwarding rule with the given name from the n-th NIC.
 func (m *Machine) DelNATPF(n int, name string) error {
-	_, _, err := Manage().run("controlvm", m.Name, fmt.Sprintf("natpf%d", n), "delete", name)
+	_, _, err := Manage().run(context.Background(), "controlvm", m.Name, fmt.Sprintf("natpf%d", n), "delete", name)
 	return err
 }
 
@@ -510,7 +507,7 @@ func (m *Machine) SetNIC(n int, nic NIC) error {
 	} else if nic.Network == NICNetBridged {
 		args = append(args, fmt.Sprintf("--bridgeadapter%d", n), nic.HostInterface)
 	}
-	_, _, err := Manage().run(args...)
+	_, _, err := Manage().run(context.Background(), args...)
 	return err
 }
 
@@ -529,36 +526,31 @@ func (m *Machine) AddStorageCtl(name string, ctl StorageController) error {
 	args = append(args, "--hostiocache", bool2string(ctl.HostIOCache))
 	args = append(args, "--bootable", bool2string(ctl.Bootable))
 
-	_, _, err := Manage().run(args...)
+	_, _, err := Manage().run(context.Background(), args...)
 	return err
 }
 
 // DelStorageCtl deletes the storage controller with the given name.
 func (m *Machine) DelStorageCtl(name string) error {
-	_, _, err := Manage().run("storagectl", m.Name, "--name", name, "--remove")
+	_, _, err := Manage().run(context.Background(), "storagectl", m.Name, "--name", name, "--remove")
 	return err
 }
 
 // AttachStorage attaches a storage medium to the named storage controller.
 func (m *Machine) AttachStorage(ctlName string, medium StorageMedium) error {
-	_, _, err := Manage().run("storageattach", m.Name, "--storagectl", ctlName,
-		"--port", fmt.Sprintf("%d", medium.Port),
-		"--device", fmt.Sprintf("%d", medium.Device),
-		"--type", string(medium.DriveType),
-		"--medium", medium.Medium,
-	)
+	_, _, err := Manage().run(context.Background(), medium.attachArgs(m.Name, ctlName)...)
 	return err
 }
 
 // SetExtraData attaches custom string to the VM.
 func (m *Machine) SetExtraData(key, val string) error {
-	_, _, err := Manage().run("setextradata", m.Name, key, val)
+	_, _, err := Manage().run(context.Background(), "setextradata", m.Name, key, val)
 	return err
 }
 
 // GetExtraData retrieves custom string from the VM.
 func (m *Machine) GetExtraData(key string) (*string, error) {
-	value, _, err := Manage().run("getextradata", m.Name, key)
+	value, _, err := Manage().run(context.Background(), "getextradata", m.Name, key)
 	if err != nil {
 		return nil, err
 	}
@@ -574,16 +566,16 @@ func (m *Machine) GetExtraData(key string) (*string, error) {
 
 // DeleteExtraData removes custom string from the VM.
 func (m *Machine) DeleteExtraData(key string) error {
-	_, _, err := Manage().run("setextradata", m.Name, key)
+	_, _, err := Manage().run(context.Background(), "setextradata", m.Name, key)
 	return err
 }
 
 // CloneMachine clones the given machine name into a new one.
 func CloneMachine(baseImageName string, newImageName string, register bool) error {
 	if register {
-		_, _, err := Manage().run("clonevm", baseImageName, "--name", newImageName, "--register")
+		_, _, err := Manage().run(context.Background(), "clonevm", baseImageName, "--name", newImageName, "--register")
 		return err
 	}
-	_, _, err := Manage().run("clonevm", baseImageName, "--name", newImageName)
+	_, _, err := Manage().run(context.Background(), "clonevm", baseImageName, "--name", newImageName)
 	return err
 }
@@ -3,6 +3,8 @@ package virtualbox
 import (
 	"context"
 	"errors"
+	"net"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -18,7 +20,8 @@ var (
 		Memory:   1024, VRAM: 8, CfgFile: "/Users/fix/VirtualBox VMs/go-virtualbox/go-virtualbox.vbox",
 		BaseFolder: "/Users/fix/VirtualBox VMs/go-virtualbox", OSType: "", Flag: 0, BootOrder: []string{},
 		NICs: []NIC{
-			{Network: "nat", Hardware: "82540EM", HostInterface: "", MacAddr: "080027EE1DF7"},
+			{Network: "nat", Hardware: "82540EM", HostInterface: "", MacAddr: "080027EE1DF7",
+				Forwarding: []PFRule{{Name: "ssh", Proto: PFTCP, HostIP: net.IPv4(127, 0, 0, 1), HostPort: 2222, GuestPort: 22}}},
 		},
 	}
 	testGoVirtualboxMachine = &Machine{
@@ -30,7 +33,8 @@ var (
 		Memory:   1024, VRAM: 8, CfgFile: "/Users/fix/VirtualBox VMs/go-virtualbox/go-virtualbox.vbox",
 		BaseFolder: "/Users/fix/VirtualBox VMs/go-virtualbox", OSType: "", Flag: 0, BootOrder: []string{},
 		NICs: []NIC{
-			{Network: "nat", Hardware: "82540EM", HostInterface: "", MacAddr: "080027EE1DF7"},
+			{Network: "nat", Hardware: "82540EM", HostInterface: "", MacAddr: "080027EE1DF7",
+				Forwarding: []PFRule{{Name: "ssh", Proto: PFTCP, HostIP: net.IPv4(127, 0, 0, 1), HostPort: 2222, GuestPort: 22}}},
 		},
 	}
 )
@@ -86,8 +90,138 @@ func TestListMachines(t *testing.T) {
 	}
 }
 
+func TestListMachinesFirstError(t *testing.T) {
+	errFailed := errors.New("E_ACCESSDENIED")
+	m := NewManager(Concurrency(2))
+	m.runner = func(ctx context.Context, args ...string) (string, string, error) {
+		switch {
+		case args[0] == "list":
+			return "\"a\" {00000000-0000-0000-0000-00000000000a}\n\"b\" {00000000-0000-0000-0000-00000000000b}\n", "", nil
+		case args[1] == "a":
+			// The first machine only fails once the others cancel it.
+			<-ctx.Done()
+			return "", "", ctx.Err()
+		}
+		return "", "", errFailed
+	}
+
+	if _, err := m.ListMachines(context.Background()); !errors.Is(err, errFailed) {
+		t.Errorf("ListMachines() = %v; want %v", err, errFailed)
+	}
+}
+
 func TestModifyMachine(t *testing.T) {
-	// TODO: Figure out how we can do this test, it has pretty extensive flag list
-	//       so having a file in the testdata with such a long name doesn't make
-	//       sense.
+	testCases := map[string]struct {
+		fixture  string
+		want     *Machine
+		exitCode int
+	}{
+		"good": {
+			fixture: "modifymachine.json",
+			want:    testGoVirtualboxMachine,
+		},
+		"locked": {
+			fixture:  "modifymachine-locked.json",
+			exitCode: 1,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r, err := LoadReplayer(filepath.Join("testdata", tc.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewManager(Replay(r))
+
+			vm := &Machine{
+				Name:      "go-virtualbox",
+				Firmware:  "BIOS",
+				OSType:    "Ubuntu_64",
+				CPUs:      1,
+				Memory:    1024,
+				VRAM:      8,
+				Flag:      ACPI | IOAPIC,
+				BootOrder: []string{"disk", "dvd"},
+				NICs: []NIC{
+					{Network: NICNetNAT, Hardware: IntelPro1000MTDesktop},
+				},
+			}
+			err = m.ModifyMachine(context.Background(), vm)
+			if tc.exitCode != 0 {
+				var ee *ExitError
+				if !errors.As(err, &ee) || ee.Code != tc.exitCode {
+					t.Errorf("ModifyMachine() = %v; want exit status %d", err, tc.exitCode)
+				}
+			} else if diff := deep.Equal(vm, tc.want); err != nil || diff != nil {
+				t.Errorf("ModifyMachine() = %v, machine %+v; want nil, %+v; diff = %v",
+					err, vm, tc.want, diff)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCreateMachine(t *testing.T) {
+	info := ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")
+	testCases := map[string]struct {
+		calls []Call
+		want  *Machine
+		err   error
+	}{
+		"good": {
+			calls: []Call{
+				{Args: []string{"createvm", "--name", "go-virtualbox", "--register", "--ostype", "Ubuntu_64"},
+					Stdout: "Virtual machine 'go-virtualbox' is created and registered.\n"},
+				{Args: []string{"showvminfo", "go-virtualbox", "--machinereadable"}, Stdout: info},
+			},
+			want: testGoVirtualboxMachine,
+		},
+		"exists": {
+			calls: []Call{
+				{Args: []string{"createvm", "--name", "go-virtualbox", "--register", "--ostype", "Ubuntu_64"},
+					Stderr:   "VBoxManage: error: Machine settings file '/Users/fix/VirtualBox VMs/go-virtualbox/go-virtualbox.vbox' already exists\n",
+					ExitCode: 1},
+			},
+			err: ErrMachineExist,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(tc.calls...)
+			m := NewManager(Replay(r))
+
+			vm := &Machine{Name: "go-virtualbox", OSType: "Ubuntu_64"}
+			err := m.CreateMachine(context.Background(), vm)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("CreateMachine() = %v; want %v", err, tc.err)
+			}
+			if diff := deep.Equal(vm, tc.want); err == nil && diff != nil {
+				t.Errorf("CreateMachine() machine = %+v; want %+v; diff = %v", vm, tc.want, diff)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDeleteMachine(t *testing.T) {
+	r := NewReplayer(
+		Call{Args: []string{"unregistervm", "go-virtualbox", "--delete"}},
+		Call{Args: []string{"unregistervm", "go-virtualbox", "--delete"},
+			Stderr:   "VBoxManage: error: Could not find a registered machine named 'go-virtualbox'\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+
+	if err := m.DeleteMachine(context.Background(), "go-virtualbox"); err != nil {
+		t.Errorf("DeleteMachine() = %v; want nil", err)
+	}
+	if err := m.DeleteMachine(context.Background(), "go-virtualbox"); !errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("DeleteMachine() = %v; want %v", err, ErrMachineNotExist)
+	}
 }
@@ -2,23 +2,57 @@ package virtualbox
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"runtime"
 	"sync"
+	"time"
 )
 
 // runFn is the function which is used to actually run the commands. This is
 // abstracted into a function so it can be easily replaced for testing purposes.
 type runFn func(context.Context, ...string) (string, string, error)
 
+// streamFn starts a command with its output streamed. The commands which
+// stream their output, e.g. guestcontrol run, fall back to runFn when it is
+// nil, like when the manager replays or records the commands.
+type streamFn func(context.Context, ...string) (*process, error)
+
 // Manager of the virtualbox instance.
+//
+// The operations on the same machine are serialised, as long as the machine
+// is always referred to in the same way: a machine referred to by its name
+// and by its UUID gets two separate locks.
 type Manager struct {
-	// lock the whole manager to only allow one action at a time
-	// TODO: Decide if this is a good idea, maybe one mutex per type of operation?
-	lock sync.Mutex
+	// locks serialises the operations which need a session lock on a machine,
+	// keyed by the machine name or UUID they were called with, which are not
+	// resolved to each other.
+	locks keyedMutex
+
+	// sem limits the number of VBoxManage processes running at once.
+	sem      chan struct{}
+	maxProcs int
+
+	runner   runFn
+	streamer streamFn
+
+	// cmd is the VBoxManage command used by the default runner, it is looked
+	// up on the first use unless the path was given explicitly.
+	cmd     command
+	cmdOnce sync.Once
+	cmdErr  error
 
-	run runFn
+	timeout time.Duration
+
+	version     *Version
+	versionLock sync.Mutex
+
+	// portLock serialises the allocation of host ports, so concurrent
+	// allocations do not pick the same port.
+	portLock sync.Mutex
 
 	log *log.Logger
 }
@@ -26,8 +60,8 @@ type Manager struct {
 // NewManager returns a manager capable of managing everything in virtualbox.
 func NewManager(opts ...Option) *Manager {
 	m := &Manager{
-		run: vboxManageRun,
-		log: log.New(io.Discard, "", 0),
+		maxProcs: runtime.NumCPU(),
+		log:      log.New(io.Discard, "", 0),
 	}
 
 	// if the debug env var for the virtualbox is set to true, we want to set the
@@ -36,22 +70,122 @@ func NewManager(opts ...Option) *Manager {
 		m.log = log.Default()
 	}
 
+	m.runner = m.vboxManageRun
+	m.streamer = m.vboxManageStream
 	for _, opt := range opts {
 		opt(m)
 	}
 
+	m.sem = make(chan struct{}, m.maxProcs)
+
 	return m
 }
 
-// vboxManageRun is a function which actually runs the VboxManage
-func vboxManageRun(_ context.Context, args ...string) (string, string, error) {
-	// TODO: reimplement and do not use the old function
-	return Manage().run(args...)
+// run executes VBoxManage with the given arguments once there is a free slot
+// within the concurrency limit.
+func (m *Manager) run(ctx context.Context, args ...string) (string, string, error) {
+	select {
+	case m.sem <- struct{}{}:
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+	defer func() { <-m.sem }()
+
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	return m.runner(ctx, args...)
+}
+
+// runMachine is like run, but holds the lock of the given machine while the
+// command is running. It should be used by all the commands which need a
+// session lock on the machine, e.g. showvminfo, modifyvm or controlvm.
+func (m *Manager) runMachine(ctx context.Context, id string, args ...string) (string, string, error) {
+	unlock := m.locks.lock(id)
+	defer unlock()
+
+	return m.run(ctx, args...)
+}
+
+// lookup finds the VBoxManage command on the first use, unless its path was
+// given explicitly. Like Manage, it also detects when it runs in a guest, where
+// only VBoxControl is installed.
+func (m *Manager) lookup() error {
+	m.cmdOnce.Do(func() {
+		if m.cmd.program != "" {
+			return
+		}
+		m.cmd.program, m.cmdErr = lookupVBoxProgram("VBoxManage")
+		if m.cmdErr != nil {
+			m.log.Printf("unable to find VBoxManage: %v", m.cmdErr)
+			m.cmdErr = ErrCommandNotFound
+			if _, err := lookupVBoxProgram("VBoxControl"); err == nil {
+				m.cmd.guest = true
+			}
+		}
+	})
+	return m.cmdErr
+}
+
+// hostOnly returns ErrNotHost instead of ErrCommandNotFound when the manager
+// runs in a guest, for the operations which are only available on the host,
+// e.g. the guest control.
+func (m *Manager) hostOnly(err error) error {
+	if errors.Is(err, ErrCommandNotFound) && m.lookup() != nil && m.cmd.isGuest() {
+		return fmt.Errorf("VBoxManage not found in the guest: %w", ErrNotHost)
+	}
+	return err
+}
+
+// vboxManageRun runs the VBoxManage command configured for the manager.
+func (m *Manager) vboxManageRun(ctx context.Context, args ...string) (string, string, error) {
+	if err := m.lookup(); err != nil {
+		return "", "", err
+	}
+	return m.cmd.run(ctx, args...)
+}
+
+// vboxManageStream starts the VBoxManage command configured for the manager
+// with its output streamed.
+func (m *Manager) vboxManageStream(ctx context.Context, args ...string) (*process, error) {
+	if err := m.lookup(); err != nil {
+		return nil, m.hostOnly(err)
+	}
+	return m.cmd.start(ctx, args...)
+}
+
+// vboxManageRun is a function which runs the commands using the global
+// Command returned by Manage.
+func vboxManageRun(ctx context.Context, args ...string) (string, string, error) {
+	return Manage().run(ctx, args...)
+}
+
+// vboxManageStream starts the commands with the global Command returned by
+// Manage. The guest control is only available on the host, with VBoxManage.
+func vboxManageStream(ctx context.Context, args ...string) (*process, error) {
+	cmd, ok := Manage().(command)
+	if !ok {
+		// A mocked Command can only run the commands.
+		return nil, errNoStream
+	}
+	if cmd.isGuest() {
+		return nil, fmt.Errorf("%s: %w", cmd.path(), ErrNotHost)
+	}
+	return cmd.start(ctx, args...)
 }
 
 // defaultManager is used for backwards compatibility so that the older
-// functions can use it.
-var defaultManager = NewManager()
+// functions can use it. It keeps running the commands through Manage, so the
+// older functions and the methods share the same VBoxManage.
+var defaultManager = func() *Manager {
+	m := NewManager()
+	m.runner = vboxManageRun
+	m.streamer = vboxManageStream
+	return m
+}()
 
 // Option modifies the manager options
 type Option func(*Manager)
@@ -62,3 +196,63 @@ func Logger(l *log.Logger) Option {
 		m.log = l
 	}
 }
+
+// Concurrency limits the number of VBoxManage processes the manager runs at
+// the same time. It defaults to the number of CPUs, values lower than one are
+// treated as one.
+func Concurrency(n int) Option {
+	return func(m *Manager) {
+		if n < 1 {
+			n = 1
+		}
+		m.maxProcs = n
+	}
+}
+
+// VBoxManagePath sets the path of the VBoxManage executable used by the
+// manager, instead of looking it up in the PATH and the default installation
+// directories.
+func VBoxManagePath(path string) Option {
+	return func(m *Manager) {
+		m.cmd.program = path
+	}
+}
+
+// Env adds environment variables, in the form of "KEY=value", to the ones
+// inherited from the current process when running VBoxManage.
+func Env(env ...string) Option {
+	return func(m *Manager) {
+		m.cmd.env = append(m.cmd.env, env...)
+	}
+}
+
+// UserHome sets the VBOX_USER_HOME of the manager, so it uses the
+// VirtualBox configuration and machine registry within the given directory.
+// Managers with different homes are isolated from each other.
+func UserHome(dir string) Option {
+	return Env("VBOX_USER_HOME=" + dir)
+}
+
+// Sudo runs VBoxManage through the given privilege escalation command, e.g.
+// Sudo("sudo", "-n") runs "sudo -n VBoxManage <args>".
+func Sudo(wrapper ...string) Option {
+	return func(m *Manager) {
+		m.cmd.wrapper = wrapper
+	}
+}
+
+// Dir sets the working directory of VBoxManage, relative paths in the
+// arguments are resolved against it.
+func Dir(dir string) Option {
+	return func(m *Manager) {
+		m.cmd.dir = dir
+	}
+}
+
+// Timeout limits how long a single VBoxManage command can run before it is
+// killed. There is no limit by default, apart from the passed in context.
+func Timeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.timeout = d
+	}
+}
@@ -2,16 +2,20 @@ package virtualbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"testing"
+	"time"
 )
 
 func newTestManager() *Manager {
 	m := NewManager(Logger(log.Default()))
-	m.run = testDataRun
+	m.runner = testDataRun
 
 	return m
 }
@@ -26,3 +30,49 @@ func testDataRun(_ context.Context, args ...string) (string, string, error) {
 	}
 	return string(data), "", nil
 }
+
+func TestConcurrency(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	m := NewManager(Concurrency(2))
+	m.runner = func(context.Context, ...string) (string, string, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "", "", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = m.run(context.Background(), "list", "vms")
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("max concurrent commands = %d; want 2", peak)
+	}
+}
+
+func TestConcurrencyCancel(t *testing.T) {
+	m := NewManager(Concurrency(1))
+	m.sem <- struct{}{} // occupy the only slot
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := m.run(ctx, "list", "vms"); !errors.Is(err, context.Canceled) {
+		t.Errorf("run() = %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build !windows
+// +build !windows
+
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestManagerCommandOptions(t *testing.T) {
+	dir := t.TempDir()
+	testCases := map[string]struct {
+		opts []Option
+		args []string
+		want string
+	}{
+		"path": {
+			opts: []Option{VBoxManagePath("echo")},
+			args: []string{"list", "vms"},
+			want: "list vms\n",
+		},
+		"env": {
+			opts: []Option{VBoxManagePath("sh"), Env("FOO=bar"), UserHome("/tmp/vbox")},
+			args: []string{"-c", "echo $FOO $VBOX_USER_HOME"},
+			want: "bar /tmp/vbox\n",
+		},
+		"sudo": {
+			opts: []Option{VBoxManagePath("echo"), Sudo("env", "FOO=bar")},
+			args: []string{"list", "vms"},
+			want: "list vms\n",
+		},
+		"dir": {
+			opts: []Option{VBoxManagePath("pwd"), Dir(dir)},
+			want: dir + "\n",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(tc.opts...)
+			got, _, err := m.run(context.Background(), tc.args...)
+			if err != nil || got != tc.want {
+				t.Errorf("run(%v) = %q, %v; want %q, nil", tc.args, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestManagerTimeout(t *testing.T) {
+	m := NewManager(VBoxManagePath("sleep"), Timeout(50*time.Millisecond))
+	if _, _, err := m.run(context.Background(), "10"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run() = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestManagerCommandNotFound(t *testing.T) {
+	m := NewManager(VBoxManagePath("/nonexistent/VBoxManage"))
+	if _, _, err := m.run(context.Background(), "list", "vms"); !errors.Is(err, ErrCommandNotFound) {
+		t.Errorf("run() = %v; want %v", err, ErrCommandNotFound)
+	}
+}
+
+func TestManagerDirNotExist(t *testing.T) {
+	m := NewManager(VBoxManagePath("/bin/true"), Dir("/nonexistent"))
+	_, _, err := m.run(context.Background(), "list", "vms")
+	if errors.Is(err, ErrCommandNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("run() = %v; want %v", err, fs.ErrNotExist)
+	}
+}
@@ -1,74 +1,301 @@
 package virtualbox
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+var (
+	reNATNetNotFound = regexp.MustCompile(`VBOX_E_OBJECT_NOT_FOUND|NAT network named '(.+)' could not be found`)
+	reNATNetPFRule   = regexp.MustCompile(`^(.+?):(tcp|udp):\[(.*)\]:(\d+):\[(.*)\]:(\d+)$`)
+)
+
+var (
+	// ErrNATNetExist is returned when a NAT network with the same name
+	// already exists.
+	ErrNATNetExist = errors.New("NAT network already exists")
+	// ErrNATNetNotExist is returned when the NAT network does not exist.
+	ErrNATNetNotExist = errors.New("NAT network does not exist")
+)
+
 // A NATNet defines a NAT network.
 type NATNet struct {
-	Name    string
-	IPv4    net.IPNet
-	IPv6    net.IPNet
-	DHCP    bool
-	Enabled bool
+	Name         string
+	IPv4         net.IPNet // the gateway and the network
+	IPv6         net.IPNet // the IPv6 prefix
+	DHCP         bool
+	Enabled      bool
+	IPv6Enabled  bool
+	IPv6Default  bool // advertise the default IPv6 route
+	PortForward4 []PFRule
+	PortForward6 []PFRule
+	Loopback4    []NATLoopback
+	Loopback6    []NATLoopback
 }
 
-// NATNets gets all NAT networks in a  map keyed by NATNet.Name.
-func NATNets() (map[string]NATNet, error) {
-	out, _, err := Manage().run("list", "natnets")
+// NATLoopback maps a loopback address of the host to the address at the
+// offset from the network address of the NAT network, e.g. 127.0.0.1=2 makes
+// the host reachable at 10.0.2.2 in the 10.0.2.0/24 network.
+type NATLoopback struct {
+	IP     net.IP
+	Offset int
+}
+
+// String returns the mapping in the format used by VBoxManage.
+func (l NATLoopback) String() string {
+	return fmt.Sprintf("%s=%d", l.IP, l.Offset)
+}
+
+// parseNATLoopback parses the "127.0.0.1=2" mapping.
+func parseNATLoopback(s string) (NATLoopback, error) {
+	ip, offset, ok := strings.Cut(strings.TrimSpace(s), "=")
+	l := NATLoopback{IP: net.ParseIP(ip)}
+	if !ok || l.IP == nil {
+		return NATLoopback{}, fmt.Errorf("invalid loopback mapping %q", s)
+	}
+	n, err := strconv.Atoi(offset)
 	if err != nil {
-		return nil, err
+		return NATLoopback{}, fmt.Errorf("invalid loopback mapping %q: %w", s, err)
+	}
+	l.Offset = n
+	return l, nil
+}
+
+// formatNATNetPFRule returns the rule in the format of the --port-forward-4
+// and --port-forward-6 flags.
+func formatNATNetPFRule(r PFRule) string {
+	hostip, guestip := grab(r)
+	return fmt.Sprintf("%s:%s:[%s]:%d:[%s]:%d", r.Name, r.Proto, hostip, r.HostPort, guestip, r.GuestPort)
+}
+
+// parseNATNetPFRule parses the "ssh:tcp:[]:2222:[10.0.2.15]:22" rule.
+func parseNATNetPFRule(s string) (PFRule, error) {
+	res := reNATNetPFRule.FindStringSubmatch(strings.TrimSpace(s))
+	if res == nil {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q", s)
+	}
+	hostPort, err := strconv.ParseUint(res[4], 10, 16)
+	if err != nil {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q: %w", s, err)
+	}
+	guestPort, err := strconv.ParseUint(res[6], 10, 16)
+	if err != nil {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q: %w", s, err)
 	}
-	s := bufio.NewScanner(strings.NewReader(out))
-	m := map[string]NATNet{}
-	n := NATNet{}
-	for s.Scan() {
-		line := s.Text()
-		if line == "" {
-			m[n.Name] = n
-			n = NATNet{}
-			continue
+	return PFRule{
+		Name:      res[1],
+		Proto:     PFProto(res[2]),
+		HostIP:    net.ParseIP(res[3]),
+		HostPort:  uint16(hostPort),
+		GuestIP:   net.ParseIP(res[5]),
+		GuestPort: uint16(guestPort),
+	}, nil
+}
+
+// args returns the flags of 'natnetwork add' and 'natnetwork modify' which
+// change cur into n, and the capabilities they require. Only the IPv6 prefix,
+// port forwarding rules and loopback mappings which differ are deleted and
+// added again. For a new network cur is nil.
+func (n NATNet) args(cur *NATNet) ([]string, []Capability) {
+	var caps []Capability
+	args := []string{"--netname", n.Name}
+	if n.IPv4.IP != nil && n.IPv4.Mask != nil {
+		network := net.IPNet{IP: n.IPv4.IP.Mask(n.IPv4.Mask), Mask: n.IPv4.Mask}
+		args = append(args, "--network", network.String())
+	}
+	if n.Enabled {
+		args = append(args, "--enable")
+	} else {
+		args = append(args, "--disable")
+	}
+	args = append(args, "--dhcp", bool2string(n.DHCP), "--ipv6", bool2string(n.IPv6Enabled))
+	if cur == nil {
+		cur = &NATNet{}
+	}
+	if n.IPv6.IP != nil && n.IPv6.Mask != nil && n.IPv6.String() != cur.IPv6.String() {
+		args = append(args, "--ipv6-prefix", n.IPv6.String())
+		caps = append(caps, CapNATNetIPv6Prefix)
+	}
+	if n.IPv6Default != cur.IPv6Default {
+		args = append(args, "--ipv6-default", bool2string(n.IPv6Default))
+		caps = append(caps, CapNATNetIPv6Default)
+	}
+
+	for _, pf := range []struct {
+		flag      string
+		old, rule []PFRule
+	}{
+		{"--port-forward-4", cur.PortForward4, n.PortForward4},
+		{"--port-forward-6", cur.PortForward6, n.PortForward6},
+	} {
+		old := map[string]string{}
+		for _, r := range pf.old {
+			old[r.Name] = formatNATNetPFRule(r)
+		}
+		want := map[string]string{}
+		for _, r := range pf.rule {
+			want[r.Name] = formatNATNetPFRule(r)
+		}
+		for _, r := range pf.old {
+			if want[r.Name] != old[r.Name] {
+				args = append(args, pf.flag, "delete", r.Name)
+			}
+		}
+		for _, r := range pf.rule {
+			if want[r.Name] != old[r.Name] {
+				args = append(args, pf.flag, want[r.Name])
+			}
+		}
+	}
+	for _, lb := range []struct {
+		flag      string
+		old, want []NATLoopback
+	}{
+		{"--loopback-4", cur.Loopback4, n.Loopback4},
+		{"--loopback-6", cur.Loopback6, n.Loopback6},
+	} {
+		old := map[string]string{}
+		for _, l := range lb.old {
+			old[l.IP.String()] = l.String()
 		}
-		res := reColonLine.FindStringSubmatch(line)
-		if res == nil {
-			continue
+		want := map[string]string{}
+		for _, l := range lb.want {
+			want[l.IP.String()] = l.String()
 		}
-		switch key, val := res[1], res[2]; key {
-		case "NetworkName":
-			n.Name = val
-		case "IP":
-			n.IPv4.IP = net.ParseIP(val)
-		case "Network":
-			_, ipnet, err := net.ParseCIDR(val)
-			if err != nil {
-				return nil, err
+		// A mapping with the offset 0 is removed.
+		for _, l := range lb.old {
+			if _, ok := want[l.IP.String()]; !ok {
+				args = append(args, lb.flag, NATLoopback{IP: l.IP}.String())
 			}
-			n.IPv4.Mask = ipnet.Mask
-		case "IPv6 Prefix":
-			// TODO: IPv6 CIDR parsing works fine on macOS, check on Windows
-			// if val == "" {
-			// 	continue
-			// }
-			// l, err := strconv.ParseUint(val, 10, 7)
-			// if err != nil {
-			// 	return nil, err
-			// }
-			// n.IPv6.Mask = net.CIDRMask(int(l), net.IPv6len*8)
-			_, ipnet, err := net.ParseCIDR(val)
-			if err != nil {
-				return nil, err
+		}
+		for _, l := range lb.want {
+			if old[l.IP.String()] != l.String() {
+				args = append(args, lb.flag, l.String())
 			}
-			n.IPv6.Mask = ipnet.Mask
-		case "DHCP Enabled":
-			n.DHCP = (val == stringYes)
-		case "Enabled":
-			n.Enabled = (val == stringYes)
 		}
 	}
-	if err := s.Err(); err != nil {
+	return args, caps
+}
+
+// natnetworkArgs returns the arguments of the natnetwork subcommand changing
+// cur into n, once the version of VirtualBox is known to support them.
+func (m *Manager) natnetworkArgs(ctx context.Context, sub string, n NATNet, cur *NATNet) ([]string, error) {
+	args, caps := n.args(cur)
+	for _, c := range caps {
+		if err := m.require(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	return append([]string{"natnetwork", sub}, args...), nil
+}
+
+// AddNATNet adds the NAT network. The network address is taken from
+// n.IPv4, VirtualBox uses the first address of it as the gateway.
+func (m *Manager) AddNATNet(ctx context.Context, n NATNet) error {
+	if n.Name == "" {
+		return fmt.Errorf("NAT network name is empty")
+	}
+	if n.IPv4.IP == nil || n.IPv4.Mask == nil {
+		return fmt.Errorf("NAT network %q has no IPv4 network", n.Name)
+	}
+	args, err := m.natnetworkArgs(ctx, "add", n, nil)
+	if err != nil {
+		return err
+	}
+	m.log.Printf("adding NAT network %q", n.Name)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if strings.Contains(stderr, "already exists") {
+			return ErrNATNetExist
+		}
+		return fmt.Errorf("unable to add NAT network: %w", err)
+	}
+	return nil
+}
+
+// ModifyNATNet changes the NAT network to match n. The port forwarding rules
+// and loopback mappings which are not in n are removed.
+func (m *Manager) ModifyNATNet(ctx context.Context, n NATNet) error {
+	cur, err := m.NATNet(ctx, n.Name)
+	if err != nil {
+		return err
+	}
+	args, err := m.natnetworkArgs(ctx, "modify", n, &cur)
+	if err != nil {
+		return err
+	}
+	m.log.Printf("modifying NAT network %q", n.Name)
+	if _, stderr, err := m.run(ctx, args...); err != nil {
+		if reNATNetNotFound.MatchString(stderr) {
+			return ErrNATNetNotExist
+		}
+		return fmt.Errorf("unable to modify NAT network: %w", err)
+	}
+	return nil
+}
+
+// RemoveNATNet removes the NAT network with the given name.
+func (m *Manager) RemoveNATNet(ctx context.Context, name string) error {
+	return m.natnetwork(ctx, "remove", name)
+}
+
+// StartNATNet starts the NAT and DHCP services of the NAT network.
+func (m *Manager) StartNATNet(ctx context.Context, name string) error {
+	return m.natnetwork(ctx, "start", name)
+}
+
+// StopNATNet stops the NAT and DHCP services of the NAT network.
+func (m *Manager) StopNATNet(ctx context.Context, name string) error {
+	return m.natnetwork(ctx, "stop", name)
+}
+
+func (m *Manager) natnetwork(ctx context.Context, cmd, name string) error {
+	m.log.Printf("%s NAT network %q", cmd, name)
+	if _, stderr, err := m.run(ctx, "natnetwork", cmd, "--netname", name); err != nil {
+		if reNATNetNotFound.MatchString(stderr) {
+			return ErrNATNetNotExist
+		}
+		return fmt.Errorf("unable to %s NAT network: %w", cmd, err)
+	}
+	return nil
+}
+
+// NATNets returns all NAT networks keyed by their name.
+func (m *Manager) NATNets(ctx context.Context) (map[string]NATNet, error) {
+	stdout, _, err := m.run(ctx, "list", "natnets")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list NAT networks: %w", err)
+	}
+	nets, err := parseNATNets(stdout)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	nm := make(map[string]NATNet, len(nets))
+	for _, n := range nets {
+		nm[n.Name] = n
+	}
+	return nm, nil
+}
+
+// NATNet returns the NAT network with the given name.
+func (m *Manager) NATNet(ctx context.Context, name string) (NATNet, error) {
+	nets, err := m.NATNets(ctx)
+	if err != nil {
+		return NATNet{}, err
+	}
+	n, ok := nets[name]
+	if !ok {
+		return NATNet{}, ErrNATNetNotExist
+	}
+	return n, nil
+}
+
+// NATNets gets all NAT networks in a  map keyed by NATNet.Name.
+// DEPRECATED: Use (*Manager).NATNets
+func NATNets() (map[string]NATNet, error) {
+	return defaultManager.NATNets(context.Background())
 }
@@ -1,8 +1,12 @@
 package virtualbox
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
 
+	"github.com/go-test/deep"
 	"github.com/golang/mock/gomock"
 )
 
@@ -12,7 +16,7 @@ func TestNATNets(t *testing.T) {
 	if ManageMock != nil {
 		listHostOnlyIfsOut := ReadTestData("vboxmanage-list-natnets-1.out")
 		gomock.InOrder(
-			ManageMock.EXPECT().run("list", "natnets").Return(listHostOnlyIfsOut, "", nil).Times(1),
+			ManageMock.EXPECT().run(gomock.Any(), "list", "natnets").Return(listHostOnlyIfsOut, "", nil).Times(1),
 		)
 	}
 	m, err := NATNets()
@@ -23,3 +27,141 @@ func TestNATNets(t *testing.T) {
 
 	Teardown()
 }
+
+const listNATNets7 = `Name:         NatNetwork
+Network:      10.0.2.0/24
+Gateway:      10.0.2.1
+DHCP Server:  Yes
+IPv6:         Yes
+IPv6 Prefix:  fd17:625c:f037:2::/64
+IPv6 Default: No
+Port-forwarding (ipv4)
+        ssh:tcp:[]:2222:[10.0.2.15]:22
+        dns:udp:[127.0.0.1]:5353:[10.0.2.15]:53
+Port-forwarding (ipv6)
+        ssh6:tcp:[]:2223:[fd17:625c:f037:2::15]:22
+loopback mappings (ipv4)
+        127.0.0.1=2
+
+`
+
+var testNATNet = NATNet{
+	Name:        "NatNetwork",
+	IPv4:        net.IPNet{IP: net.ParseIP("10.0.2.1"), Mask: net.CIDRMask(24, 32)},
+	IPv6:        net.IPNet{IP: net.ParseIP("fd17:625c:f037:2::"), Mask: net.CIDRMask(64, 128)},
+	DHCP:        true,
+	IPv6Enabled: true,
+	PortForward4: []PFRule{
+		{Name: "ssh", Proto: PFTCP, HostPort: 2222, GuestIP: net.ParseIP("10.0.2.15"), GuestPort: 22},
+		{Name: "dns", Proto: PFUDP, HostIP: net.ParseIP("127.0.0.1"), HostPort: 5353, GuestIP: net.ParseIP("10.0.2.15"), GuestPort: 53},
+	},
+	PortForward6: []PFRule{
+		{Name: "ssh6", Proto: PFTCP, HostPort: 2223, GuestIP: net.ParseIP("fd17:625c:f037:2::15"), GuestPort: 22},
+	},
+	Loopback4: []NATLoopback{{IP: net.ParseIP("127.0.0.1"), Offset: 2}},
+}
+
+func TestParseNATNets(t *testing.T) {
+	testCases := map[string]struct {
+		in   string
+		want []NATNet
+	}{
+		"6.1": {
+			in: ReadTestData("vboxmanage-list-natnets-1.out"),
+			want: []NATNet{{
+				Name:      "NatNetwork",
+				IPv4:      net.IPNet{IP: net.ParseIP("10.0.2.1"), Mask: net.CIDRMask(24, 32)},
+				IPv6:      net.IPNet{IP: net.ParseIP("fd17:625c:f037:2::"), Mask: net.CIDRMask(64, 128)},
+				DHCP:      true,
+				Enabled:   true,
+				Loopback4: []NATLoopback{{IP: net.ParseIP("127.0.0.1"), Offset: 2}},
+			}},
+		},
+		"7.0": {
+			in:   listNATNets7,
+			want: []NATNet{testNATNet},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			got, err := parseNATNets(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Errorf("parseNATNets() = %+v; diff = %v", got, diff)
+			}
+		})
+	}
+}
+
+func TestManagerNATNet(t *testing.T) {
+	n := testNATNet
+	n.PortForward4 = []PFRule{
+		{Name: "ssh", Proto: PFTCP, HostPort: 2200, GuestIP: net.ParseIP("10.0.2.15"), GuestPort: 22},
+		n.PortForward4[1],
+	}
+	n.PortForward6 = nil
+	n.Loopback4 = nil
+	n.IPv6Default = true
+
+	r := NewReplayer(
+		Call{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+		Call{Args: []string{"natnetwork", "add", "--netname", "NatNetwork", "--network", "10.0.2.0/24",
+			"--disable", "--dhcp", "on", "--ipv6", "on", "--ipv6-prefix", "fd17:625c:f037:2::/64",
+			"--port-forward-4", "ssh:tcp:[]:2222:[10.0.2.15]:22",
+			"--port-forward-4", "dns:udp:[127.0.0.1]:5353:[10.0.2.15]:53",
+			"--port-forward-6", "ssh6:tcp:[]:2223:[fd17:625c:f037:2::15]:22",
+			"--loopback-4", "127.0.0.1=2"}},
+		Call{Args: []string{"list", "natnets"}, Stdout: listNATNets7},
+		Call{Args: []string{"natnetwork", "modify", "--netname", "NatNetwork", "--network", "10.0.2.0/24",
+			"--disable", "--dhcp", "on", "--ipv6", "on", "--ipv6-default", "on",
+			"--port-forward-4", "delete", "ssh",
+			"--port-forward-4", "ssh:tcp:[]:2200:[10.0.2.15]:22",
+			"--port-forward-6", "delete", "ssh6",
+			"--loopback-4", "127.0.0.1=0"}},
+		Call{Args: []string{"natnetwork", "start", "--netname", "NatNetwork"}},
+		Call{Args: []string{"natnetwork", "stop", "--netname", "NatNetwork"}},
+		Call{Args: []string{"natnetwork", "remove", "--netname", "NatNetwork"}},
+		Call{Args: []string{"natnetwork", "remove", "--netname", "NatNetwork"},
+			Stderr:   "VBoxManage: error: Code VBOX_E_OBJECT_NOT_FOUND (0x80bb0001) - Object corresponding to the supplied arguments does not exist (extended info not available)\n",
+			ExitCode: 1},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	if err := m.AddNATNet(ctx, testNATNet); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ModifyNATNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartNATNet(ctx, n.Name); err != nil {
+		t.Error(err)
+	}
+	if err := m.StopNATNet(ctx, n.Name); err != nil {
+		t.Error(err)
+	}
+	if err := m.RemoveNATNet(ctx, n.Name); err != nil {
+		t.Error(err)
+	}
+	if err := m.RemoveNATNet(ctx, n.Name); !errors.Is(err, ErrNATNetNotExist) {
+		t.Errorf("RemoveNATNet() = %v; want %v", err, ErrNATNetNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerNATNetIPv6Unsupported(t *testing.T) {
+	r := NewReplayer(Call{Args: []string{"--version"}, Stdout: "6.1.40r156084\n"})
+	m := NewManager(Replay(r))
+	n := NATNet{Name: "NatNetwork", IPv4: testNATNet.IPv4, IPv6Enabled: true, IPv6Default: true}
+	if err := m.AddNATNet(context.Background(), n); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("AddNATNet() = %v; want %v", err, ErrUnsupported)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
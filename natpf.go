@@ -0,0 +1,193 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrPFRuleExist is returned when the NIC already has a port forwarding
+	// rule with the same name, or for the same host address and port.
+	ErrPFRuleExist = errors.New("port forwarding rule already exists")
+	// ErrNoFreePort is returned when all the host ports of the range are in
+	// use.
+	ErrNoFreePort = errors.New("no free host port")
+)
+
+// PortRange is the range of host ports AllocateNATPF picks from.
+type PortRange struct {
+	Min uint16
+	Max uint16
+	// Probe makes AllocateNATPF also check that the port is free on the host
+	// by listening on it, so the ports used by other programs are skipped.
+	Probe bool
+}
+
+// AddNATPF adds the port forwarding rule to the NAT NIC in the slot, which
+// starts at 1. The rule is added with controlvm when the machine is running
+// and with modifyvm otherwise. A rule without a name is named after its
+// protocol and host port, e.g. tcp2222.
+func (m *Manager) AddNATPF(ctx context.Context, id string, slot int, rule PFRule) error {
+	if rule.Name == "" {
+		rule.Name = fmt.Sprintf("%s%d", rule.Proto, rule.HostPort)
+	}
+	m.log.Printf("adding port forwarding rule %q to nic%d of %q", rule.Name, slot, id)
+	args, err := m.natpfArgs(ctx, id, slot, rule.Name+","+rule.Format())
+	if err != nil {
+		return err
+	}
+	if _, stderr, err := m.runMachine(ctx, id, args...); err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return ErrMachineNotExist
+		}
+		if strings.Contains(stderr, "already exists") {
+			return fmt.Errorf("%s: %w", rule.Name, ErrPFRuleExist)
+		}
+		return fmt.Errorf("unable to add port forwarding rule: %w", err)
+	}
+	return nil
+}
+
+// DelNATPF deletes the port forwarding rule with the given name from the NAT
+// NIC in the slot.
+func (m *Manager) DelNATPF(ctx context.Context, id string, slot int, name string) error {
+	m.log.Printf("deleting port forwarding rule %q from nic%d of %q", name, slot, id)
+	args, err := m.natpfArgs(ctx, id, slot, "delete", name)
+	if err != nil {
+		return err
+	}
+	if _, stderr, err := m.runMachine(ctx, id, args...); err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return ErrMachineNotExist
+		}
+		return fmt.Errorf("unable to delete port forwarding rule: %w", err)
+	}
+	return nil
+}
+
+// natpfArgs returns the controlvm arguments changing the port forwarding of
+// a running or paused machine, and the modifyvm ones otherwise.
+func (m *Manager) natpfArgs(ctx context.Context, id string, slot int, vals ...string) ([]string, error) {
+	vm, err := m.Machine(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if vm.State == Running || vm.State == Paused {
+		return append([]string{"controlvm", id, fmt.Sprintf("natpf%d", slot)}, vals...), nil
+	}
+	v, err := m.Version(ctx)
+	if err != nil {
+		m.log.Printf("using legacy modifyvm flags: %v", err)
+	}
+	return append([]string{"modifyvm", id, v.flag("natpf", slot)}, vals...), nil
+}
+
+// hostPort is a host port of a protocol.
+type hostPort struct {
+	proto PFProto
+	port  uint16
+}
+
+// usedHostPorts returns the host ports forwarded by the NAT NICs of all the
+// machines and by the NAT networks.
+func (m *Manager) usedHostPorts(ctx context.Context) (map[hostPort]bool, error) {
+	vms, err := m.ListMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[hostPort]bool)
+	for _, vm := range vms {
+		for _, nic := range vm.NICs {
+			for _, r := range nic.Forwarding {
+				used[hostPort{r.Proto, r.HostPort}] = true
+			}
+		}
+	}
+	natnets, err := m.NATNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range natnets {
+		for _, r := range append(n.PortForward4, n.PortForward6...) {
+			used[hostPort{r.Proto, r.HostPort}] = true
+		}
+	}
+	return used, nil
+}
+
+// AllocateNATPF adds the port forwarding rule to the NAT NIC in the slot with
+// a host port which is not forwarded by any machine or NAT network, and
+// returns the port. The host port of the rule is tried first when it is within
+// the range, like the auto_correct of Vagrant, then the range in order.
+func (m *Manager) AllocateNATPF(ctx context.Context, id string, slot int, rule PFRule, r PortRange) (uint16, error) {
+	if r.Min == 0 || r.Max < r.Min {
+		return 0, fmt.Errorf("invalid port range %d-%d", r.Min, r.Max)
+	}
+	if rule.Proto == "" {
+		rule.Proto = PFTCP
+	}
+
+	m.portLock.Lock()
+	defer m.portLock.Unlock()
+
+	used, err := m.usedHostPorts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	free := func(port uint16) bool {
+		if used[hostPort{rule.Proto, port}] {
+			return false
+		}
+		return !r.Probe || probeHostPort(rule.Proto, rule.HostIP, port)
+	}
+
+	port := rule.HostPort
+	if port < r.Min || port > r.Max || !free(port) {
+		port = 0
+		for p := int(r.Min); p <= int(r.Max); p++ {
+			if p != int(rule.HostPort) && free(uint16(p)) {
+				port = uint16(p)
+				break
+			}
+		}
+	}
+	if port == 0 {
+		return 0, fmt.Errorf("%s %d-%d: %w", rule.Proto, r.Min, r.Max, ErrNoFreePort)
+	}
+	if port != rule.HostPort && rule.HostPort != 0 {
+		m.log.Printf("host port %d is in use, using %d instead", rule.HostPort, port)
+	}
+	rule.HostPort = port
+	if err := m.AddNATPF(ctx, id, slot, rule); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// probeHostPort reports whether the port can be listened on at the host
+// address, or all the addresses when it is nil.
+func probeHostPort(proto PFProto, ip net.IP, port uint16) bool {
+	host := ""
+	if ip != nil {
+		host = ip.String()
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	if proto == PFUDP {
+		c, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		c.Close()
+		return true
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
@@ -0,0 +1,93 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseNICPFRule(t *testing.T) {
+	got, err := parseNICPFRule("ssh,tcp,127.0.0.1,2222,,22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PFRule{Name: "ssh", Proto: PFTCP, HostIP: net.IPv4(127, 0, 0, 1), HostPort: 2222, GuestPort: 22}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("parseNICPFRule() = %v; diff = %v", got, diff)
+	}
+	for _, in := range []string{"ssh,tcp,,2222,22", "ssh,tcp,,65536,,22", "ssh,tcp,localhost,2222,,22"} {
+		if _, err := parseNICPFRule(in); err == nil {
+			t.Errorf("parseNICPFRule(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseMachineForwarding(t *testing.T) {
+	vm, err := parseMachine(`nic1="nat"
+nictype1="82540EM"
+macaddress1="080027EE1DF7"
+Forwarding(0)="ssh,tcp,,2222,,22"
+nic2="nat"
+nictype2="82540EM"
+macaddress2="080027EE1DF8"
+Forwarding(0)="dns,udp,,5353,,53"
+Forwarding(1)="web,tcp,,8080,,80"
+nic3="none"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.NICs) != 2 || len(vm.NICs[0].Forwarding) != 1 || len(vm.NICs[1].Forwarding) != 2 ||
+		vm.NICs[1].Forwarding[1].Name != "web" {
+		t.Errorf("parseMachine() NICs = %+v; want the rules of each NIC", vm.NICs)
+	}
+}
+
+func TestManagerAllocateNATPF(t *testing.T) {
+	list := []Call{
+		{Args: []string{"list", "vms"}, Stdout: ReadTestData("list_vms.out")},
+		{Args: []string{"showvminfo", "Ubuntu", "--machinereadable"},
+			Stdout: ReadTestData("showvminfo_Ubuntu_--machinereadable.out")},
+		{Args: []string{"showvminfo", "go-virtualbox", "--machinereadable"},
+			Stdout: ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")},
+		{Args: []string{"list", "natnets"}},
+	}
+	calls := append(append([]Call{}, list...),
+		Call{Args: []string{"showvminfo", "go-virtualbox", "--machinereadable"},
+			Stdout: ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")},
+		Call{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+		Call{Args: []string{"modifyvm", "go-virtualbox", "--nat-pf1", "web,tcp,,2200,,80"}},
+	)
+	calls = append(calls, list...)
+	r := NewReplayer(calls...)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	rule := PFRule{Name: "web", Proto: PFTCP, HostPort: 2222, GuestPort: 80}
+	port, err := m.AllocateNATPF(ctx, "go-virtualbox", 1, rule, PortRange{Min: 2200, Max: 2299})
+	if err != nil || port != 2200 {
+		t.Errorf("AllocateNATPF() = %d, %v; want 2200", port, err)
+	}
+	_, err = m.AllocateNATPF(ctx, "go-virtualbox", 1, rule, PortRange{Min: 2222, Max: 2222})
+	if !errors.Is(err, ErrNoFreePort) {
+		t.Errorf("AllocateNATPF() = %v; want %v", err, ErrNoFreePort)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProbeHostPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+	if probeHostPort(PFTCP, net.IPv4(127, 0, 0, 1), port) {
+		t.Errorf("probeHostPort(%d) = true; want false for a port in use", port)
+	}
+}
@@ -2,10 +2,12 @@ package virtualbox
 
 // NIC represents a virtualized network interface card.
 type NIC struct {
-	Network       NICNetwork
-	Hardware      NICHardware
-	HostInterface string // The host interface name to bind to in 'hostonly' and 'bridged' mode
-	MacAddr       string
+	Network        NICNetwork
+	Hardware       NICHardware
+	HostInterface  string // The host interface or network name to bind to in 'hostonly', 'hostonlynet', 'natnetwork' and 'bridged' mode
+	MacAddr        string
+	Forwarding     []PFRule // The port forwarding rules in 'nat' mode, only read from the machine
+	BandwidthGroup string   // The bandwidth group limiting the NIC, only set on the machine
 }
 
 // NICNetwork represents the type of NIC networks.
@@ -18,12 +20,19 @@ const (
 	NICNetDisconnected = NICNetwork("null")
 	// NICNetNAT when the NIC is NAT-ed to access the external network.
 	NICNetNAT = NICNetwork("nat")
+	// NICNetNATNetwork when the NIC is attached to a NAT network, see NATNet.
+	NICNetNATNetwork = NICNetwork("natnetwork")
 	// NICNetBridged when the NIC is the bridge to the external network.
 	NICNetBridged = NICNetwork("bridged")
 	// NICNetInternal when the NIC does not have access to the external network.
 	NICNetInternal = NICNetwork("intnet")
-	// NICNetHostonly when the NIC can only access one host-only network.
+	// NICNetHostonly when the NIC can only access one host-only network. On
+	// VirtualBox 7.0 or newer, the NIC is attached with NICNetHostonlyNet
+	// when its HostInterface names a host-only network.
 	NICNetHostonly = NICNetwork("hostonly")
+	// NICNetHostonlyNet when the NIC can only access one host-only network of
+	// VirtualBox 7.0 or newer, see HostonlyNetwork.
+	NICNetHostonlyNet = NICNetwork("hostonlynet")
 	// NICNetGeneric when the NIC behaves like a standard physical one.
 	NICNetGeneric = NICNetwork("generic")
 )
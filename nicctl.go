@@ -0,0 +1,229 @@
+package virtualbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrMachineRunning is returned when a change is only possible while the
+	// machine is not running.
+	ErrMachineRunning = errors.New("machine is running")
+)
+
+// NICPromisc is the promiscuous mode policy of a NIC.
+type NICPromisc string
+
+const (
+	// NICPromiscDeny hides the traffic not addressed to the machine.
+	NICPromiscDeny = NICPromisc("deny")
+	// NICPromiscAllowVMs shows the traffic of the other machines.
+	NICPromiscAllowVMs = NICPromisc("allow-vms")
+	// NICPromiscAllowAll shows all the traffic, including the host's.
+	NICPromiscAllowAll = NICPromisc("allow-all")
+)
+
+// onOff returns the on or off value of the VBoxManage flags.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// changeNIC changes the NIC of the machine in the slot, which starts at 1.
+// When the machine is running or paused the change is made with the controlvm
+// arguments, otherwise with the modifyvm flags returned for the version.
+func (m *Manager) changeNIC(ctx context.Context, id, what string, controlvm []string, modifyvm func(v Version) []string) error {
+	return m.changeNICOf(ctx, id, what, func(*Machine) ([]string, error) { return controlvm, nil }, modifyvm)
+}
+
+// changeNICOf is like changeNIC, but the controlvm arguments are built from
+// the running machine, which fails when the change needs modifyvm.
+func (m *Manager) changeNICOf(ctx context.Context, id, what string, controlvm func(vm *Machine) ([]string, error), modifyvm func(v Version) []string) error {
+	vm, err := m.Machine(ctx, id)
+	if err != nil {
+		return err
+	}
+	var args []string
+	if vm.State == Running || vm.State == Paused {
+		cargs, err := controlvm(vm)
+		if err != nil {
+			return fmt.Errorf("unable to change %s of %q: %w", what, id, err)
+		}
+		args = append([]string{"controlvm", id}, cargs...)
+	} else {
+		v, err := m.Version(ctx)
+		if err != nil {
+			m.log.Printf("using legacy modifyvm flags: %v", err)
+		}
+		args = append([]string{"modifyvm", id}, modifyvm(v)...)
+	}
+	if _, stderr, err := m.runMachine(ctx, id, args...); err != nil {
+		if reMachineNotFound.MatchString(stderr) {
+			return ErrMachineNotExist
+		}
+		return fmt.Errorf("unable to change %s: %w", what, err)
+	}
+	return nil
+}
+
+// SetNIC attaches the NIC in the slot to the network of the given NIC. The
+// hardware and the bandwidth group of the NIC can only be changed while the
+// machine is not running, ErrMachineRunning is returned otherwise. They are
+// left alone when they are empty or the same as the current ones, so a NIC
+// read from the machine can be passed back with another network.
+func (m *Manager) SetNIC(ctx context.Context, id string, slot int, nic NIC) error {
+	m.log.Printf("setting nic%d of %q to %s", slot, id, nic.Network)
+	attachment, err := m.hostonlyAttachment(ctx, nic)
+	if err != nil {
+		return fmt.Errorf("nic%d: %w", slot, err)
+	}
+	network := string(attachment)
+	controlvm := []string{fmt.Sprintf("nic%d", slot), network}
+	if nic.HostInterface != "" {
+		controlvm = append(controlvm, nic.HostInterface)
+	}
+	running := func(vm *Machine) ([]string, error) {
+		var cur NIC
+		if slot >= 1 && slot <= len(vm.NICs) {
+			cur = vm.NICs[slot-1]
+		}
+		if nic.Hardware != "" && !strings.EqualFold(string(nic.Hardware), string(cur.Hardware)) ||
+			nic.BandwidthGroup != "" && nic.BandwidthGroup != cur.BandwidthGroup {
+			return nil, ErrMachineRunning
+		}
+		return controlvm, nil
+	}
+	return m.changeNICOf(ctx, id, fmt.Sprintf("nic%d", slot), running, func(v Version) []string {
+		args := []string{fmt.Sprintf("--nic%d", slot), network}
+		if nic.Hardware != "" {
+			args = append(args, v.flag("nictype", slot), string(nic.Hardware))
+		}
+		switch attachment {
+		case NICNetHostonly:
+			args = append(args, v.flag("hostonlyadapter", slot), nic.HostInterface)
+		case NICNetHostonlyNet:
+			args = append(args, fmt.Sprintf("--host-only-net%d", slot), nic.HostInterface)
+		case NICNetNATNetwork:
+			args = append(args, fmt.Sprintf("--nat-network%d", slot), nic.HostInterface)
+		case NICNetBridged:
+			args = append(args, v.flag("bridgeadapter", slot), nic.HostInterface)
+		case NICNetInternal:
+			args = append(args, fmt.Sprintf("--intnet%d", slot), nic.HostInterface)
+		}
+		if nic.BandwidthGroup != "" {
+			args = append(args, v.flag("nicbandwidthgroup", slot), nic.BandwidthGroup)
+		}
+		return args
+	})
+}
+
+// SetLinkState connects or disconnects the virtual cable of the NIC in the
+// slot, e.g. to simulate a cable pull while the machine is running.
+func (m *Manager) SetLinkState(ctx context.Context, id string, slot int, up bool) error {
+	m.log.Printf("setting link state of nic%d of %q to %s", slot, id, onOff(up))
+	controlvm := []string{fmt.Sprintf("setlinkstate%d", slot), onOff(up)}
+	return m.changeNIC(ctx, id, "link state", controlvm, func(v Version) []string {
+		return []string{v.flag("cableconnected", slot), onOff(up)}
+	})
+}
+
+// SetNICPromisc changes the promiscuous mode policy of the NIC in the slot.
+func (m *Manager) SetNICPromisc(ctx context.Context, id string, slot int, mode NICPromisc) error {
+	m.log.Printf("setting promiscuous mode of nic%d of %q to %s", slot, id, mode)
+	controlvm := []string{fmt.Sprintf("nicpromisc%d", slot), string(mode)}
+	return m.changeNIC(ctx, id, "promiscuous mode", controlvm, func(v Version) []string {
+		return []string{v.flag("nicpromisc", slot), string(mode)}
+	})
+}
+
+// SetNICProperty sets a property of the network driver of the NIC in the
+// slot, e.g. the ones of the generic or the UDP tunnel drivers.
+func (m *Manager) SetNICProperty(ctx context.Context, id string, slot int, name, value string) error {
+	m.log.Printf("setting property %q of nic%d of %q", name, slot, id)
+	prop := name + "=" + value
+	controlvm := []string{fmt.Sprintf("nicproperty%d", slot), prop}
+	return m.changeNIC(ctx, id, "NIC property", controlvm, func(v Version) []string {
+		return []string{v.flag("nicproperty", slot), prop}
+	})
+}
+
+// SetNICTrace turns the tracing of the NIC in the slot on or off. The traffic
+// is written to the file in the pcap format, the file is left unchanged when
+// it is empty.
+func (m *Manager) SetNICTrace(ctx context.Context, id string, slot int, on bool, file string) error {
+	m.log.Printf("setting trace of nic%d of %q to %s", slot, id, onOff(on))
+	if file != "" {
+		controlvm := []string{fmt.Sprintf("nictracefile%d", slot), file}
+		err := m.changeNIC(ctx, id, "NIC trace file", controlvm, func(v Version) []string {
+			return []string{v.flag("nictracefile", slot), file}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	controlvm := []string{fmt.Sprintf("nictrace%d", slot), onOff(on)}
+	return m.changeNIC(ctx, id, "NIC trace", controlvm, func(v Version) []string {
+		return []string{v.flag("nictrace", slot), onOff(on)}
+	})
+}
+
+// A Capture is a running trace of a NIC started by CaptureNIC.
+type Capture struct {
+	path string
+	done chan struct{}
+	err  error
+}
+
+// CaptureNIC traces the traffic of the NIC in the slot into the pcap file at
+// the path until the context ends. The trace is turned on with modifyvm when
+// the machine is not running, so it starts with the machine, and with
+// controlvm otherwise.
+func (m *Manager) CaptureNIC(ctx context.Context, id string, slot int, path string) (*Capture, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.SetNICTrace(ctx, id, slot, true, path); err != nil {
+		return nil, err
+	}
+	c := &Capture{path: path, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		<-ctx.Done()
+		// The context has ended, so the trace is stopped without it.
+		c.err = m.SetNICTrace(context.Background(), id, slot, false, "")
+	}()
+	return c, nil
+}
+
+// Wait waits for the end of the capture and returns a reader of the captured
+// packets, which must be closed by the caller.
+func (c *Capture) Wait() (*PcapReader, error) {
+	<-c.done
+	if c.err != nil {
+		return nil, fmt.Errorf("unable to stop the capture: %w", c.err)
+	}
+	f, err := os.Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := NewPcapReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	pr.closer = f
+	return pr, nil
+}
+
+// Path returns the path of the pcap file.
+func (c *Capture) Path() string {
+	return c.path
+}
@@ -0,0 +1,119 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestManagerChangeNIC(t *testing.T) {
+	saved := ReadTestData("showvminfo_go-virtualbox_--machinereadable.out")
+	running := strings.Replace(saved, `VMState="saved"`, `VMState="running"`, 1)
+	showvminfo := []string{"showvminfo", "go-virtualbox", "--machinereadable"}
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		info  string
+		calls []Call
+		fn    func(m *Manager) error
+		err   error
+	}{
+		"nic running": {
+			info:  running,
+			calls: []Call{{Args: []string{"controlvm", "go-virtualbox", "nic1", "bridged", "en0"}}},
+			fn: func(m *Manager) error {
+				return m.SetNIC(ctx, "go-virtualbox", 1, NIC{Network: NICNetBridged, HostInterface: "en0"})
+			},
+		},
+		"nic current hardware running": {
+			info:  running,
+			calls: []Call{{Args: []string{"controlvm", "go-virtualbox", "nic1", "bridged", "en0"}}},
+			fn: func(m *Manager) error {
+				return m.SetNIC(ctx, "go-virtualbox", 1, NIC{Network: NICNetBridged, Hardware: IntelPro1000MTDesktop, HostInterface: "en0"})
+			},
+		},
+		"nic hardware running": {
+			info: running,
+			fn: func(m *Manager) error {
+				return m.SetNIC(ctx, "go-virtualbox", 1, NIC{Network: NICNetNAT, Hardware: VirtIO})
+			},
+			err: ErrMachineRunning,
+		},
+		"nic bandwidth group running": {
+			info: running,
+			fn: func(m *Manager) error {
+				return m.SetNIC(ctx, "go-virtualbox", 1, NIC{Network: NICNetNAT, BandwidthGroup: "slow"})
+			},
+			err: ErrMachineRunning,
+		},
+		"nic off": {
+			info: saved,
+			calls: []Call{
+				{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+				{Args: []string{"modifyvm", "go-virtualbox", "--nic1", "intnet", "--nic-type1", "virtio", "--intnet1", "test"}},
+			},
+			fn: func(m *Manager) error {
+				return m.SetNIC(ctx, "go-virtualbox", 1, NIC{Network: NICNetInternal, Hardware: VirtIO, HostInterface: "test"})
+			},
+		},
+		"link running": {
+			info:  running,
+			calls: []Call{{Args: []string{"controlvm", "go-virtualbox", "setlinkstate2", "off"}}},
+			fn:    func(m *Manager) error { return m.SetLinkState(ctx, "go-virtualbox", 2, false) },
+		},
+		"link off legacy": {
+			info: saved,
+			calls: []Call{
+				{Args: []string{"--version"}, Stdout: "6.1.40r156084\n"},
+				{Args: []string{"modifyvm", "go-virtualbox", "--cableconnected2", "on"}},
+			},
+			fn: func(m *Manager) error { return m.SetLinkState(ctx, "go-virtualbox", 2, true) },
+		},
+		"promisc running": {
+			info:  running,
+			calls: []Call{{Args: []string{"controlvm", "go-virtualbox", "nicpromisc1", "allow-all"}}},
+			fn:    func(m *Manager) error { return m.SetNICPromisc(ctx, "go-virtualbox", 1, NICPromiscAllowAll) },
+		},
+		"property off": {
+			info: saved,
+			calls: []Call{
+				{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+				{Args: []string{"modifyvm", "go-virtualbox", "--nic-property1", "dest=10.0.0.1"}},
+			},
+			fn: func(m *Manager) error { return m.SetNICProperty(ctx, "go-virtualbox", 1, "dest", "10.0.0.1") },
+		},
+		"trace running": {
+			info: running,
+			calls: []Call{
+				{Args: []string{"controlvm", "go-virtualbox", "nictracefile1", "/tmp/nic1.pcap"}},
+				{Args: append([]string{}, showvminfo...), Stdout: running},
+				{Args: []string{"controlvm", "go-virtualbox", "nictrace1", "on"}},
+			},
+			fn: func(m *Manager) error { return m.SetNICTrace(ctx, "go-virtualbox", 1, true, "/tmp/nic1.pcap") },
+		},
+		"not found": {
+			calls: []Call{},
+			fn:    func(m *Manager) error { return m.SetLinkState(ctx, "go-virtualbox", 1, true) },
+			err:   ErrMachineNotExist,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			first := Call{Args: showvminfo, Stdout: tc.info}
+			if tc.info == "" {
+				first = Call{Args: showvminfo, ExitCode: 1,
+					Stderr: "VBoxManage: error: Could not find a registered machine named 'go-virtualbox'\n"}
+			}
+			r := NewReplayer(append([]Call{first}, tc.calls...)...)
+			m := NewManager(Replay(r))
+			if err := tc.fn(m); !errors.Is(err, tc.err) {
+				t.Errorf("err = %v; want %v", err, tc.err)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,865 @@
+package virtualbox
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The parsers of the VBoxManage output are pure functions over strings, so
+// they can be tested and fuzzed without running VBoxManage. All of them accept
+// both LF and CRLF line endings.
+
+// lines splits the output into lines, without the line endings. All the
+// trailing carriage returns are removed, so a line never ends with one.
+func lines(out string) []string {
+	ls := strings.Split(out, "\n")
+	for i, l := range ls {
+		ls[i] = strings.TrimRight(l, "\r")
+	}
+	if n := len(ls); n > 0 && ls[n-1] == "" {
+		ls = ls[:n-1]
+	}
+	return ls
+}
+
+// vmInfoLine is a single key="value" line of the --machinereadable output.
+type vmInfoLine struct {
+	key string
+	val string
+}
+
+// parseMachineReadable parses the --machinereadable output of showvminfo and
+// similar commands into key and value pairs, in the order of the output.
+//
+// Keys and values can be either bare or quoted. Within the quotes, \" and \\
+// are unescaped to " and \, and \n to a new line, as escaped by VBoxManage.
+// Bare keys end at the first '=', the quoted values can contain any character.
+// Lines without any '=' are skipped.
+func parseMachineReadable(out string) []vmInfoLine {
+	var res []vmInfoLine
+	for _, l := range lines(out) {
+		var key string
+		rest := l
+		if strings.HasPrefix(rest, `"`) {
+			var ok bool
+			key, rest, ok = unquote(rest)
+			if !ok || !strings.HasPrefix(rest, "=") {
+				continue
+			}
+			rest = rest[1:]
+		} else {
+			var ok bool
+			key, rest, ok = strings.Cut(rest, "=")
+			if !ok {
+				continue
+			}
+		}
+		val := rest
+		if strings.HasPrefix(rest, `"`) {
+			if v, _, ok := unquote(rest); ok {
+				val = v
+			}
+		}
+		res = append(res, vmInfoLine{key: key, val: val})
+	}
+	return res
+}
+
+// parseMachine parses the --machinereadable output of showvminfo.
+func parseMachine(out string) (*Machine, error) {
+	/* Read all VM info into a map */
+	props := make(map[string]string)
+	// The Forwarding(<i>) rules are listed after the nic<N> line of the NIC
+	// they belong to, and the index restarts for every NIC.
+	forwarding := make(map[int][]PFRule)
+	slot := 0
+	for _, l := range parseMachineReadable(out) {
+		props[l.key] = l.val
+		if strings.HasPrefix(l.key, "nic") {
+			if n, err := strconv.Atoi(l.key[len("nic"):]); err == nil {
+				slot = n
+			}
+		}
+		if strings.HasPrefix(l.key, "Forwarding(") && slot > 0 {
+			r, err := parseNICPFRule(l.val)
+			if err != nil {
+				return nil, err
+			}
+			forwarding[slot] = append(forwarding[slot], r)
+		}
+	}
+
+	// error that occured during parsing
+	var perr error
+
+	sp := func(field string, def ...string) string {
+		if v, exists := props[field]; exists {
+			return v
+		}
+		if len(def) < 1 {
+			return ""
+		}
+		return def[0]
+	}
+
+	up := func(field string, def ...uint) uint {
+		if v, exists := props[field]; exists {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				perr = err
+				return 0
+			}
+			return uint(n)
+		}
+		if len(def) < 1 {
+			return 0
+		}
+		return def[0]
+	}
+
+	/* Extract basic info */
+	vm := &Machine{
+		// TODO: This was in New, verify is this still correct.
+		BootOrder:  make([]string, 0, 4),
+		NICs:       make([]NIC, 0, 4),
+		Name:       sp("name"),
+		Firmware:   sp("firmware"),
+		UUID:       sp("UUID"),
+		State:      MachineState(sp("VMState")),
+		Memory:     up("memory"),
+		CPUs:       up("cpus"),
+		VRAM:       up("vram"),
+		CfgFile:    sp("CfgFile"),
+		BaseFolder: filepath.Dir(sp("CfgFile")),
+	}
+
+	/* Extract NIC info */
+	for i := 1; i <= 4; i++ {
+		var nic NIC
+		nicType, ok := props[fmt.Sprintf("nic%d", i)]
+		if !ok || nicType == "none" {
+			break
+		}
+		nic.Network = NICNetwork(nicType)
+		if nicType == "hostonlynetwork" {
+			// showvminfo names the attachment differently than modifyvm.
+			nic.Network = NICNetHostonlyNet
+		}
+		nic.Hardware = NICHardware(props[fmt.Sprintf("nictype%d", i)])
+		if nic.Hardware == "" {
+			return nil, fmt.Errorf("Could not find corresponding 'nictype%d'", i)
+		}
+		nic.MacAddr = props[fmt.Sprintf("macaddress%d", i)]
+		if nic.MacAddr == "" {
+			return nil, fmt.Errorf("Could not find corresponding 'macaddress%d'", i)
+		}
+		if nic.Network == NICNetHostonly {
+			nic.HostInterface = props[fmt.Sprintf("hostonlyadapter%d", i)]
+		} else if nic.Network == NICNetHostonlyNet {
+			nic.HostInterface = props[fmt.Sprintf("hostonly-network%d", i)]
+		} else if nic.Network == NICNetNATNetwork {
+			nic.HostInterface = props[fmt.Sprintf("nat-network%d", i)]
+		} else if nic.Network == NICNetBridged {
+			nic.HostInterface = props[fmt.Sprintf("bridgeadapter%d", i)]
+		} else if nic.Network == NICNetInternal {
+			nic.HostInterface = props[fmt.Sprintf("intnet%d", i)]
+		}
+		nic.Forwarding = forwarding[i]
+		vm.NICs = append(vm.NICs, nic)
+	}
+
+	if perr != nil {
+		return nil, fmt.Errorf("parsing machine props failed: %w", perr)
+	}
+
+	return vm, nil
+}
+
+// parseNICPFRule parses the "ssh,tcp,127.0.0.1,2222,,22" port forwarding rule
+// of a NAT NIC.
+func parseNICPFRule(s string) (PFRule, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 6 {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q", s)
+	}
+	r := PFRule{Name: fields[0], Proto: PFProto(fields[1])}
+	if fields[2] != "" {
+		if r.HostIP = net.ParseIP(fields[2]); r.HostIP == nil {
+			return PFRule{}, fmt.Errorf("invalid port forwarding rule %q", s)
+		}
+	}
+	if fields[4] != "" {
+		if r.GuestIP = net.ParseIP(fields[4]); r.GuestIP == nil {
+			return PFRule{}, fmt.Errorf("invalid port forwarding rule %q", s)
+		}
+	}
+	hostPort, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q: %w", s, err)
+	}
+	guestPort, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return PFRule{}, fmt.Errorf("invalid port forwarding rule %q: %w", s, err)
+	}
+	r.HostPort, r.GuestPort = uint16(hostPort), uint16(guestPort)
+	return r, nil
+}
+
+// parseMachineList parses the output of 'list vms' into the machine names.
+func parseMachineList(out string) []string {
+	var names []string
+	for _, l := range lines(out) {
+		if res := reVMNameUUID.FindStringSubmatch(l); res != nil {
+			names = append(names, res[1])
+		}
+	}
+	return names
+}
+
+// unquote reads the quoted string at the start of s, and returns it unescaped
+// together with the rest of s after the closing quote.
+func unquote(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], true
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case '"', '\\':
+					b.WriteByte(s[i])
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(s[i])
+				}
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", s, false
+}
+
+// quote is the inverse of unquote.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// parseRecords splits the output of the list commands into records of lines.
+// The records are separated by blank lines, the last record does not need to
+// be followed by one.
+func parseRecords(out string) [][]string {
+	var records [][]string
+	var record []string
+	for _, l := range lines(out) {
+		if strings.TrimSpace(l) == "" {
+			if len(record) > 0 {
+				records = append(records, record)
+			}
+			record = nil
+			continue
+		}
+		record = append(record, l)
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records
+}
+
+// splitColonLine splits the "Key: value" line at the first colon which is
+// followed by white space or the end of the line, so the values can contain
+// colons, e.g. the MAC or IPv6 addresses.
+func splitColonLine(l string) (string, string, bool) {
+	for i := 0; i < len(l); i++ {
+		if l[i] != ':' {
+			continue
+		}
+		if i+1 == len(l) || l[i+1] == ' ' || l[i+1] == '\t' {
+			key := strings.TrimSpace(l[:i])
+			if key == "" {
+				return "", "", false
+			}
+			return key, strings.TrimSpace(l[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// parseHostonlyNets parses the output of 'list hostonlyifs'.
+func parseHostonlyNets(out string) ([]*HostonlyNet, error) {
+	ifs, err := parseHostInterfaces(out)
+	if err != nil {
+		return nil, err
+	}
+	nets := make([]*HostonlyNet, len(ifs))
+	for i, hi := range ifs {
+		nets[i] = &HostonlyNet{
+			Name:        hi.Name,
+			GUID:        hi.GUID,
+			DHCP:        hi.DHCP,
+			IPv4:        hi.IPv4,
+			IPv6:        hi.IPv6,
+			HwAddr:      hi.HwAddr,
+			Medium:      hi.Medium,
+			Status:      hi.Status,
+			NetworkName: hi.NetworkName,
+		}
+	}
+	return nets, nil
+}
+
+// parseHostInterfaces parses the output of 'list bridgedifs' and 'list
+// hostonlyifs'.
+func parseHostInterfaces(out string) ([]*HostInterface, error) {
+	var ifs []*HostInterface
+	for _, record := range parseRecords(out) {
+		n := &HostInterface{}
+		for _, l := range record {
+			key, val, ok := splitColonLine(l)
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Name":
+				n.Name = val
+			case "GUID":
+				n.GUID = val
+			case "DHCP":
+				n.DHCP = (val != "Disabled")
+			case "IPAddress":
+				n.IPv4.IP = net.ParseIP(val)
+			case "NetworkMask":
+				n.IPv4.Mask = ParseIPv4Mask(val)
+			case "IPV6Address":
+				n.IPv6.IP = net.ParseIP(val)
+			case "IPV6NetworkMaskPrefixLength":
+				l, err := strconv.ParseUint(val, 10, 8)
+				if err != nil || l > net.IPv6len*8 {
+					return nil, fmt.Errorf("invalid IPv6 prefix length %q of %s", val, n.Name)
+				}
+				n.IPv6.Mask = net.CIDRMask(int(l), net.IPv6len*8)
+			case "HardwareAddress":
+				if val == "" {
+					continue
+				}
+				mac, err := net.ParseMAC(val)
+				if err != nil {
+					return nil, err
+				}
+				n.HwAddr = mac
+			case "MediumType":
+				n.Medium = val
+			case "Wireless":
+				n.Wireless = (val == stringYes)
+			case "Status":
+				n.Status = val
+			case "VBoxNetworkName":
+				n.NetworkName = val
+			}
+		}
+		ifs = append(ifs, n)
+	}
+	return ifs, nil
+}
+
+// parseIntNets parses the output of 'list intnets'.
+func parseIntNets(out string) []string {
+	var names []string
+	for _, l := range lines(out) {
+		if key, val, ok := splitColonLine(l); ok && key == "Name" && val != "" {
+			names = append(names, val)
+		}
+	}
+	return names
+}
+
+// parseHostInfo parses the output of 'list hostinfo'. Only the first
+// processor is described, the others are usually the same.
+func parseHostInfo(out string) (*HostInfo, error) {
+	info := &HostInfo{}
+	for _, l := range lines(out) {
+		key, val, ok := splitColonLine(l)
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "Operating system":
+			info.OS = val
+		case "Operating system version":
+			info.OSVersion = val
+		case "Processor count":
+			info.CPUs, err = strconv.Atoi(val)
+		case "Processor online count":
+			info.OnlineCPUs, err = strconv.Atoi(val)
+		case "Processor core count":
+			info.Cores, err = strconv.Atoi(val)
+		case "Processor#0 description":
+			info.CPUDescription = val
+		case "Processor supports HW virtualization":
+			info.HWVirtualization = (val == "yes")
+		case "Processor supports nested HW virtualization":
+			info.NestedHWVirtualization = (val == "yes")
+		case "Processor supports nested paging":
+			info.NestedPaging = (val == "yes")
+		case "Processor supports long mode":
+			info.LongMode = (val == "yes")
+		case "Memory size", "Memory available":
+			n, perr := strconv.ParseUint(strings.TrimSuffix(val, " MByte"), 10, 64)
+			if perr != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", strings.ToLower(key), val, perr)
+			}
+			if key == "Memory size" {
+				info.Memory = uint(n)
+			} else {
+				info.MemoryAvailable = uint(n)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", strings.ToLower(key), val, err)
+		}
+	}
+	if info.OS == "" {
+		return nil, fmt.Errorf("no operating system in the host information")
+	}
+	return info, nil
+}
+
+// parseHostonlyNetworks parses the output of 'list hostonlynets'.
+func parseHostonlyNetworks(out string) ([]*HostonlyNetwork, error) {
+	var nets []*HostonlyNetwork
+	for _, record := range parseRecords(out) {
+		n := &HostonlyNetwork{}
+		for _, l := range record {
+			key, val, ok := splitColonLine(l)
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Name":
+				n.Name = val
+			case "GUID":
+				n.GUID = val
+			case "State":
+				n.Enabled = (val == "Enabled")
+			case "NetworkMask":
+				n.Netmask = ParseIPv4Mask(val)
+			case "LowerIP":
+				n.LowerIP = net.ParseIP(val)
+			case "UpperIP":
+				n.UpperIP = net.ParseIP(val)
+			case "VBoxNetworkName":
+				n.NetworkName = val
+			}
+		}
+		if n.Name == "" {
+			return nil, fmt.Errorf("host-only network without a name")
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// parseNATNets parses the output of 'list natnets'. The port forwarding rules
+// and the loopback mappings are listed in indented lines after the heading of
+// their section.
+func parseNATNets(out string) ([]NATNet, error) {
+	var nets []NATNet
+	for _, record := range parseRecords(out) {
+		n := NATNet{}
+		section := ""
+		for _, l := range record {
+			key, val, ok := splitColonLine(l)
+			if !ok {
+				if l != strings.TrimLeft(l, " \t") {
+					if err := n.parseSectionLine(section, l); err != nil {
+						return nil, err
+					}
+				} else {
+					section = strings.TrimSpace(l)
+				}
+				continue
+			}
+			section = ""
+			switch key {
+			case "NetworkName", "Name":
+				n.Name = val
+			case "IP", "Gateway":
+				n.IPv4.IP = net.ParseIP(val)
+			case "Network":
+				_, ipnet, err := net.ParseCIDR(val)
+				if err != nil {
+					return nil, err
+				}
+				n.IPv4.Mask = ipnet.Mask
+			case "IPv6 Prefix":
+				// Older versions print an empty prefix when IPv6 is disabled.
+				if val == "" {
+					continue
+				}
+				ip, ipnet, err := net.ParseCIDR(val)
+				if err != nil {
+					return nil, err
+				}
+				n.IPv6.IP = ip
+				n.IPv6.Mask = ipnet.Mask
+			case "IPv6 Enabled", "IPv6":
+				n.IPv6Enabled = (val == stringYes)
+			case "IPv6 Default":
+				n.IPv6Default = (val == stringYes)
+			case "DHCP Enabled", "DHCP Server":
+				n.DHCP = (val == stringYes)
+			case "Enabled":
+				n.Enabled = (val == stringYes)
+			case "State":
+				n.Enabled = (val == "Enabled")
+			}
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// parseSectionLine parses the indented line of the section of 'list natnets'.
+// The lines of the unknown sections are ignored.
+func (n *NATNet) parseSectionLine(section, l string) error {
+	switch section {
+	case "Port-forwarding (ipv4)", "Port-forwarding (ipv6)":
+		r, err := parseNATNetPFRule(l)
+		if err != nil {
+			return err
+		}
+		if section == "Port-forwarding (ipv4)" {
+			n.PortForward4 = append(n.PortForward4, r)
+		} else {
+			n.PortForward6 = append(n.PortForward6, r)
+		}
+	case "loopback mappings (ipv4)", "loopback mappings (ipv6)":
+		lb, err := parseNATLoopback(l)
+		if err != nil {
+			return err
+		}
+		if section == "loopback mappings (ipv4)" {
+			n.Loopback4 = append(n.Loopback4, lb)
+		} else {
+			n.Loopback6 = append(n.Loopback6, lb)
+		}
+	}
+	return nil
+}
+
+// parseDHCPs parses the output of 'list dhcpservers'. The global, group and
+// machine configurations follow the basic settings, each of them starts with
+// a heading line and the options and lease times apply to the last one.
+func parseDHCPs(out string) ([]*DHCP, error) {
+	var dhcps []*DHCP
+	for _, record := range parseRecords(out) {
+		dhcp := &DHCP{}
+		var opts *DHCPOptions
+		var group *DHCPGroupConfig
+		var machine *DHCPMachineConfig
+		for _, l := range record {
+			key, val, ok := splitColonLine(l)
+			if !ok {
+				// The conditions of the group after the first one.
+				if group != nil {
+					group.parseCondition(l)
+				}
+				continue
+			}
+			if res := reDHCPOption.FindStringSubmatch(key); res != nil && opts != nil {
+				n, _ := strconv.Atoi(res[1])
+				opts.set(n, val)
+				continue
+			}
+			switch key {
+			case "NetworkName":
+				dhcp.NetworkName = val
+			case "IP", "Dhcpd IP":
+				dhcp.IPv4.IP = net.ParseIP(val)
+			case "upperIPAddress", "UpperIPAddress":
+				dhcp.UpperIP = net.ParseIP(val)
+			case "lowerIPAddress", "LowerIPAddress":
+				dhcp.LowerIP = net.ParseIP(val)
+			case "NetworkMask":
+				dhcp.IPv4.Mask = ParseIPv4Mask(val)
+			case "Enabled":
+				dhcp.Enabled = (val == stringYes)
+			case "Global Configuration", "Global Config":
+				opts, group, machine = &dhcp.Options, nil, nil
+			case "Group":
+				dhcp.Groups = append(dhcp.Groups, DHCPGroupConfig{Name: val})
+				group, machine = &dhcp.Groups[len(dhcp.Groups)-1], nil
+				opts = &group.DHCPOptions
+			case "Conditions":
+				if group != nil {
+					group.parseCondition(val)
+				}
+			case "Individual Config":
+				c := DHCPMachineConfig{}
+				if res := reDHCPMACConfig.FindStringSubmatch(val); res != nil {
+					c.MAC = res[1]
+				} else if res := reDHCPVMConfig.FindStringSubmatch(val); res != nil {
+					c.VM = res[1]
+					c.NIC, _ = strconv.Atoi(res[2])
+				}
+				dhcp.Machines = append(dhcp.Machines, c)
+				machine, group = &dhcp.Machines[len(dhcp.Machines)-1], nil
+				opts = &machine.DHCPOptions
+			case "Fixed Address", "Fixed address":
+				if machine != nil {
+					machine.FixedAddress = net.ParseIP(val)
+				}
+			case "defaultLeaseTime":
+				if n, err := strconv.Atoi(val); err == nil && opts != nil {
+					opts.LeaseTime = time.Duration(n) * time.Second
+				}
+			}
+		}
+		dhcps = append(dhcps, dhcp)
+	}
+	return dhcps, nil
+}
+
+// set sets the option with the given number from its listed value.
+func (o *DHCPOptions) set(n int, val string) {
+	ips := func() []net.IP {
+		var ips []net.IP
+		for _, f := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' }) {
+			if ip := net.ParseIP(f); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		return ips
+	}
+	switch n {
+	case 1:
+		// The netmask is always listed, it is DHCP.IPv4.Mask.
+	case dhcpOptRouters:
+		o.Routers = ips()
+	case dhcpOptDNSServers:
+		o.DNSServers = ips()
+	case dhcpOptDomainName:
+		o.DomainName = val
+	default:
+		if o.Other == nil {
+			o.Other = map[int]string{}
+		}
+		o.Other[n] = val
+	}
+}
+
+// parseCondition parses the "include MAC 08:00:27:00:00:01" condition of the
+// group, the other conditions are ignored.
+func (g *DHCPGroupConfig) parseCondition(l string) {
+	f := strings.Fields(l)
+	if len(f) == 3 && f[0] == "include" && f[1] == "MAC" {
+		g.MACs = append(g.MACs, f[2])
+	}
+}
+
+// parseDHCPLease parses the output of 'dhcpserver findlease'. The times are
+// followed by the Unix time in parentheses, which is used instead of the
+// formatted one.
+func parseDHCPLease(out string) (*DHCPLease, error) {
+	lease := &DHCPLease{}
+	for _, l := range lines(out) {
+		key, val, ok := splitColonLine(l)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "IP Address":
+			lease.IP = net.ParseIP(val)
+		case "MAC Address":
+			lease.MAC = val
+		case "State":
+			lease.State = val
+		case "Issued", "Expire":
+			res := reDHCPLeaseTime.FindStringSubmatch(val)
+			if res == nil {
+				return nil, fmt.Errorf("invalid lease time %q", val)
+			}
+			sec, err := strconv.ParseInt(res[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid lease time %q: %w", val, err)
+			}
+			if key == "Issued" {
+				lease.Issued = time.Unix(sec, 0)
+			} else {
+				lease.Expire = time.Unix(sec, 0)
+			}
+		}
+	}
+	if lease.IP == nil {
+		return nil, fmt.Errorf("no IP address in the lease")
+	}
+	return lease, nil
+}
+
+// parseBandwidthGroups parses the machine-readable output of 'bandwidthctl
+// list', where every group starts with its name.
+func parseBandwidthGroups(out string) ([]BandwidthGroup, error) {
+	var groups []BandwidthGroup
+	for _, l := range parseMachineReadable(out) {
+		if l.key == "name" {
+			groups = append(groups, BandwidthGroup{Name: l.val})
+			continue
+		}
+		if len(groups) == 0 {
+			continue
+		}
+		g := &groups[len(groups)-1]
+		switch l.key {
+		case "type":
+			g.Type = BandwidthType(strings.ToLower(l.val))
+		case "maxbytespersec":
+			n, err := strconv.ParseUint(l.val, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bandwidth limit %q: %w", l.val, err)
+			}
+			g.Limit = n
+		}
+	}
+	return groups, nil
+}
+
+// parseGuestPropertyValue parses the output of 'guestproperty get'. The
+// value can contain any character apart from a new line.
+func parseGuestPropertyValue(out string) (string, error) {
+	for _, l := range lines(out) {
+		res := getRegexp.FindStringSubmatch(l)
+		if res != nil {
+			return res[1], nil
+		}
+		if strings.HasPrefix(strings.TrimSpace(l), "No value set") {
+			return "", ErrGuestPropertyNotSet
+		}
+	}
+	return "", fmt.Errorf("No match with get guestproperty output")
+}
+
+// parseGuestPropertyWait parses the output of 'guestproperty wait', which is
+// "Name: <name>, value: <value>, flags: <flags>". The value can contain commas,
+// only the last ", flags:" ends it.
+func parseGuestPropertyWait(out string) (GuestProperty, error) {
+	for _, l := range lines(out) {
+		if !strings.HasPrefix(l, "Name: ") {
+			continue
+		}
+		rest := strings.TrimPrefix(l, "Name: ")
+		name, rest, ok := strings.Cut(rest, ", value: ")
+		if !ok {
+			break
+		}
+		p := GuestProperty{Name: name, Value: rest}
+		if i := strings.LastIndex(rest, ", flags:"); i >= 0 {
+			p.Value = rest[:i]
+			p.Flags = parseGuestPropertyFlags(rest[i+len(", flags:"):])
+		}
+		return p, nil
+	}
+	return GuestProperty{}, fmt.Errorf("No match with VBoxManage wait guestproperty output")
+}
+
+// parseGuestPropertyFlags parses the flags of a guest property, which are
+// separated by commas and spaces, e.g. "TRANSIENT, RDONLYGUEST".
+func parseGuestPropertyFlags(s string) []GuestPropertyFlag {
+	var flags []GuestPropertyFlag
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		flags = append(flags, GuestPropertyFlag(strings.ToUpper(f)))
+	}
+	return flags
+}
+
+// parseGuestProperties parses the output of 'guestproperty enumerate', in the
+// format of VirtualBox 7.0 or newer:
+//
+//	/VirtualBox/GuestInfo/Net/0/V4/IP = '10.0.2.15' @ 2023-08-11T11:12:15.880543000Z [TRANSIENT, TRANSRESET]
+//
+// or the older one:
+//
+//	Name: /VirtualBox/GuestInfo/Net/0/V4/IP, value: 10.0.2.15, timestamp: 1691752335880543000, flags: TRANSIENT, TRANSRESET
+//
+// The values can contain any character apart from a new line.
+func parseGuestProperties(out string) ([]GuestProperty, error) {
+	var props []GuestProperty
+	for _, l := range lines(out) {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		var (
+			p   GuestProperty
+			err error
+		)
+		if strings.HasPrefix(l, "Name: ") {
+			p, err = parseGuestPropertyOld(l)
+		} else {
+			p, err = parseGuestPropertyNew(l)
+		}
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// parseGuestPropertyOld parses a property of the format before VirtualBox 7.0.
+func parseGuestPropertyOld(l string) (GuestProperty, error) {
+	name, rest, ok := strings.Cut(strings.TrimPrefix(l, "Name: "), ", value: ")
+	i := strings.LastIndex(rest, ", timestamp: ")
+	if !ok || i < 0 {
+		return GuestProperty{}, fmt.Errorf("invalid guest property %q", l)
+	}
+	p := GuestProperty{Name: name, Value: rest[:i]}
+	ts, flags, _ := strings.Cut(rest[i+len(", timestamp: "):], ", flags:")
+	n, err := strconv.ParseInt(strings.TrimSpace(ts), 10, 64)
+	if err != nil {
+		return GuestProperty{}, fmt.Errorf("invalid timestamp of guest property %q: %w", name, err)
+	}
+	if n > 0 {
+		p.Timestamp = time.Unix(0, n).UTC()
+	}
+	p.Flags = parseGuestPropertyFlags(flags)
+	return p, nil
+}
+
+// parseGuestPropertyNew parses a property of the format of VirtualBox 7.0 or
+// newer, where the timestamp and the flags are optional.
+func parseGuestPropertyNew(l string) (GuestProperty, error) {
+	name, rest, ok := strings.Cut(l, " = '")
+	if !ok {
+		return GuestProperty{}, fmt.Errorf("invalid guest property %q", l)
+	}
+	p := GuestProperty{Name: strings.TrimSpace(name)}
+	rest = strings.TrimRight(rest, " ")
+	// The value ends with a quote, so a closing bracket ends the flags.
+	if i := strings.LastIndex(rest, " ["); i >= 0 && strings.HasSuffix(rest, "]") {
+		p.Flags = parseGuestPropertyFlags(rest[i+2 : len(rest)-1])
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "' @ "); i >= 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, rest[i+len("' @ "):]); err == nil {
+			p.Timestamp = ts.UTC()
+			rest = rest[:i+1]
+		}
+	}
+	if !strings.HasSuffix(rest, "'") {
+		return GuestProperty{}, fmt.Errorf("invalid guest property %q", l)
+	}
+	p.Value = rest[:len(rest)-1]
+	return p, nil
+}
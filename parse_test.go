@@ -0,0 +1,216 @@
+package virtualbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseMachineReadable(t *testing.T) {
+	testCases := map[string]struct {
+		in   string
+		want []vmInfoLine
+	}{
+		"bare and quoted": {
+			in:   "memory=1024\nname=\"Ubuntu\"\n\"SATA Controller-0-0\"=\"disk.vmdk\"\n",
+			want: []vmInfoLine{{"memory", "1024"}, {"name", "Ubuntu"}, {"SATA Controller-0-0", "disk.vmdk"}},
+		},
+		"escaped quotes": {
+			in:   `description="say \"hi\" \\o/"` + "\n",
+			want: []vmInfoLine{{"description", `say "hi" \o/`}},
+		},
+		"equal signs and colons": {
+			in:   "name=\"a=b:c\"\nForwarding(0)=\"ssh,tcp,,2222,,22\"\n",
+			want: []vmInfoLine{{"name", "a=b:c"}, {"Forwarding(0)", "ssh,tcp,,2222,,22"}},
+		},
+		"CRLF and no final new line": {
+			in:   "name=\"Ubuntu\"\r\nmemory=1024",
+			want: []vmInfoLine{{"name", "Ubuntu"}, {"memory", "1024"}},
+		},
+		"escaped new line": {
+			in:   `description="line1\nline2"`,
+			want: []vmInfoLine{{"description", "line1\nline2"}},
+		},
+		"not set and garbage": {
+			in:   "vrdeproperty[TCP/Address]=<not set>\ngarbage\n",
+			want: []vmInfoLine{{"vrdeproperty[TCP/Address]", "<not set>"}},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			got := parseMachineReadable(tc.in)
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Errorf("parseMachineReadable(%q) = %v; want %v; diff = %v", tc.in, got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestParseRecords(t *testing.T) {
+	in := "Name: a\r\nGUID: 1\r\n\r\n\r\nName: b\r\n        \r\nName: c"
+	want := [][]string{{"Name: a", "GUID: 1"}, {"Name: b"}, {"Name: c"}}
+	if diff := deep.Equal(parseRecords(in), want); diff != nil {
+		t.Errorf("parseRecords(%q) diff = %v", in, diff)
+	}
+}
+
+func TestSplitColonLine(t *testing.T) {
+	testCases := []struct {
+		in       string
+		key, val string
+		ok       bool
+	}{
+		{"Name:            vboxnet0", "Name", "vboxnet0", true},
+		{"HardwareAddress: 0a:00:27:00:00:00", "HardwareAddress", "0a:00:27:00:00:00", true},
+		{"IPv6 Prefix:    fd17:625c:f037:2::/64", "IPv6 Prefix", "fd17:625c:f037:2::/64", true},
+		{"IPV6Address:", "IPV6Address", "", true},
+		{"Name: foo: bar", "Name", "foo: bar", true},
+		{"        ssh:tcp:[]:2222:[10.0.2.15]:22", "", "", false},
+		{"loopback mappings (ipv4)", "", "", false},
+	}
+	for _, tc := range testCases {
+		key, val, ok := splitColonLine(tc.in)
+		if key != tc.key || val != tc.val || ok != tc.ok {
+			t.Errorf("splitColonLine(%q) = %q, %q, %v; want %q, %q, %v",
+				tc.in, key, val, ok, tc.key, tc.val, tc.ok)
+		}
+	}
+}
+
+func TestParseListsWithoutFinalBlankLine(t *testing.T) {
+	hostonly := strings.TrimSpace(ReadTestData("vboxmanage-list-hostonlyifs-1.out"))
+	nets, err := parseHostonlyNets(hostonly)
+	if err != nil || len(nets) != 1 || nets[0].Name != "vboxnet0" {
+		t.Errorf("parseHostonlyNets() = %v, %v; want vboxnet0", nets, err)
+	}
+
+	dhcp := strings.TrimSpace(ReadTestData("vboxmanage-list-dhcpservers-1.out"))
+	dhcps, err := parseDHCPs(strings.ReplaceAll(dhcp, "\n", "\r\n"))
+	if err != nil || len(dhcps) != 1 || !dhcps[0].Enabled || dhcps[0].UpperIP.String() != "192.168.56.254" {
+		t.Errorf("parseDHCPs() = %+v, %v; want one enabled server", dhcps, err)
+	}
+
+	natnets, err := parseNATNets(ReadTestData("vboxmanage-list-natnets-1.out"))
+	if err != nil || len(natnets) != 1 || natnets[0].IPv6.String() != "fd17:625c:f037:2::/64" {
+		t.Errorf("parseNATNets() = %+v, %v; want NatNetwork with IPv6 prefix", natnets, err)
+	}
+}
+
+func TestParseGuestProperty(t *testing.T) {
+	if v, err := parseGuestPropertyValue("Value: a, b\r\n"); err != nil || v != "a, b" {
+		t.Errorf("parseGuestPropertyValue() = %q, %v; want \"a, b\", nil", v, err)
+	}
+	if _, err := parseGuestPropertyValue("No value set!\n"); err != ErrGuestPropertyNotSet {
+		t.Errorf("parseGuestPropertyValue() = %v; want %v", err, ErrGuestPropertyNotSet)
+	}
+	p, err := parseGuestPropertyWait("\nName: key, value: a, b, flags: TRANSIENT, RDONLYGUEST\n")
+	want := GuestProperty{Name: "key", Value: "a, b", Flags: []GuestPropertyFlag{GuestPropTransient, GuestPropReadOnlyGuest}}
+	if diff := deep.Equal(p, want); err != nil || diff != nil {
+		t.Errorf("parseGuestPropertyWait() = %+v, %v; diff = %v", p, err, diff)
+	}
+}
+
+func TestParseGuestProperties(t *testing.T) {
+	ts := time.Date(2023, 8, 11, 11, 12, 15, 880543000, time.UTC)
+	tests := map[string]string{
+		"7.0": `/VirtualBox/GuestAdd/Version         = '7.0.10' @ 2023-08-11T11:12:15.880543000Z [TRANSIENT, RDONLYGUEST]
+/VirtualBox/GuestInfo/Net/0/V4/IP     = '10.0.2.15' @ 2023-08-11T11:12:15.880543000Z [TRANSIENT, TRANSRESET]
+motd                                  = 'it's ' @ [brackets]' @ 2023-08-11T11:12:15.880543000Z
+`,
+		"6.1": `Name: /VirtualBox/GuestAdd/Version, value: 7.0.10, timestamp: 1691752335880543000, flags: TRANSIENT, RDONLYGUEST
+Name: /VirtualBox/GuestInfo/Net/0/V4/IP, value: 10.0.2.15, timestamp: 1691752335880543000, flags: TRANSIENT, TRANSRESET
+Name: motd, value: it's ' @ [brackets], timestamp: 1691752335880543000, flags: 
+`,
+	}
+	want := []GuestProperty{
+		{Name: "/VirtualBox/GuestAdd/Version", Value: "7.0.10", Timestamp: ts,
+			Flags: []GuestPropertyFlag{GuestPropTransient, GuestPropReadOnlyGuest}},
+		{Name: "/VirtualBox/GuestInfo/Net/0/V4/IP", Value: "10.0.2.15", Timestamp: ts,
+			Flags: []GuestPropertyFlag{GuestPropTransient, GuestPropTransReset}},
+		{Name: "motd", Value: "it's ' @ [brackets]", Timestamp: ts},
+	}
+	for name, in := range tests {
+		got, err := parseGuestProperties(in)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Errorf("%s: parseGuestProperties() = %+v; diff = %v", name, got, diff)
+		}
+	}
+	if !want[0].HasFlag(GuestPropReadOnlyGuest) || want[0].HasFlag(GuestPropReadOnly) {
+		t.Errorf("HasFlag() of %v is wrong", want[0].Flags)
+	}
+}
+
+func FuzzParseMachineReadable(f *testing.F) {
+	f.Add(ReadTestData("showvminfo_Ubuntu_--machinereadable.out"))
+	f.Add(ReadTestData("vboxmanage-showvminfo-1.out"))
+	f.Add(`name="a \"b\" = c"` + "\r\n" + `"key"=value`)
+	f.Fuzz(func(t *testing.T, in string) {
+		got := parseMachineReadable(in)
+		// Invalid numbers or NICs are reported, but must not panic.
+		_, _ = parseMachine(in)
+
+		// Printing the parsed lines in the quoted format must give the same
+		// lines back.
+		var b strings.Builder
+		for _, l := range got {
+			b.WriteString(quote(l.key) + "=" + quote(l.val) + "\n")
+		}
+		again := parseMachineReadable(b.String())
+		if diff := deep.Equal(again, got); diff != nil {
+			t.Errorf("reparsed %q differs: %v", b.String(), diff)
+		}
+	})
+}
+
+func FuzzParseRecords(f *testing.F) {
+	f.Add(ReadTestData("vboxmanage-list-hostonlyifs-1.out"))
+	f.Add(ReadTestData("vboxmanage-list-dhcpservers-1.out"))
+	f.Add(ReadTestData("vboxmanage-list-natnets-1.out"))
+	f.Fuzz(func(t *testing.T, in string) {
+		n := 0
+		for _, r := range parseRecords(in) {
+			if len(r) == 0 {
+				t.Fatal("empty record")
+			}
+			for _, l := range r {
+				if strings.TrimSpace(l) == "" || strings.ContainsAny(l, "\n") {
+					t.Fatalf("blank or multi-line line %q in record", l)
+				}
+			}
+			n += len(r)
+		}
+		if lines := strings.Count(in, "\n") + 1; n > lines {
+			t.Fatalf("%d lines in records of %d lines of input", n, lines)
+		}
+		_, _ = parseHostonlyNets(in)
+		_, _ = parseNATNets(in)
+		_, _ = parseDHCPs(in)
+	})
+}
+
+func FuzzParseGuestProperty(f *testing.F) {
+	f.Add(ReadTestData("vboxmanage-guestproperty-wait-1.out"))
+	f.Add("Value: test_val\r\n")
+	f.Add("No value set!")
+	f.Fuzz(func(t *testing.T, in string) {
+		if v, err := parseGuestPropertyValue(in); err == nil && (strings.Contains(v, "\n") || strings.HasSuffix(v, "\r")) {
+			t.Errorf("value %q contains a line ending", v)
+		}
+		if p, err := parseGuestPropertyWait(in); err == nil && strings.Contains(p.Name, "\n") {
+			t.Errorf("name %q contains a line ending", p.Name)
+		}
+		if props, err := parseGuestProperties(in); err == nil {
+			for _, p := range props {
+				if strings.Contains(p.Name+p.Value, "\n") {
+					t.Errorf("property %q contains a line ending", p.Name)
+				}
+			}
+		}
+	})
+}
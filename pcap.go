@@ -0,0 +1,112 @@
+package virtualbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The magic numbers of the pcap files with microsecond and nanosecond
+// timestamps, see https://wiki.wireshark.org/Development/LibpcapFileFormat.
+const (
+	pcapMagicMicro uint32 = 0xa1b2c3d4
+	pcapMagicNano  uint32 = 0xa1b23c4d
+)
+
+// pcapMaxRecord limits the size of a record, the snapshot length of the NIC
+// traces is 64 KiB or less.
+const pcapMaxRecord = 256 << 10
+
+// ErrInvalidPcap is returned when the data is not in the pcap format.
+var ErrInvalidPcap = errors.New("invalid pcap data")
+
+// LinkTypeEthernet is the link type of the NIC traces.
+const LinkTypeEthernet = 1
+
+// A PcapRecord is a packet of a pcap file.
+type PcapRecord struct {
+	Time    time.Time
+	OrigLen int    // the length of the packet on the wire
+	Data    []byte // the captured part of the packet
+}
+
+// A PcapReader reads the packets of a pcap file, like the ones written by
+// the NIC traces of VirtualBox.
+type PcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	SnapLen  int
+	LinkType int
+
+	closer io.Closer
+}
+
+// NewPcapReader reads the pcap header from r and returns a reader of the
+// packets which follow it.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("unable to read pcap header: %w", err)
+	}
+	pr := &PcapReader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(hdr[0:4]) {
+		case pcapMagicMicro:
+			pr.order = order
+		case pcapMagicNano:
+			pr.order, pr.nano = order, true
+		}
+		if pr.order != nil {
+			break
+		}
+	}
+	if pr.order == nil {
+		return nil, fmt.Errorf("magic %x: %w", hdr[0:4], ErrInvalidPcap)
+	}
+	if major := pr.order.Uint16(hdr[4:6]); major != 2 {
+		return nil, fmt.Errorf("version %d: %w", major, ErrInvalidPcap)
+	}
+	pr.SnapLen = int(pr.order.Uint32(hdr[16:20]))
+	pr.LinkType = int(pr.order.Uint32(hdr[20:24]))
+	return pr, nil
+}
+
+// Next returns the next packet, or io.EOF when there are no more packets.
+func (pr *PcapReader) Next() (*PcapRecord, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated record header: %w", ErrInvalidPcap)
+		}
+		return nil, err
+	}
+	sec := int64(pr.order.Uint32(hdr[0:4]))
+	frac := int64(pr.order.Uint32(hdr[4:8]))
+	inclLen := pr.order.Uint32(hdr[8:12])
+	if inclLen > pcapMaxRecord {
+		return nil, fmt.Errorf("record of %d bytes: %w", inclLen, ErrInvalidPcap)
+	}
+	if !pr.nano {
+		frac *= int64(time.Microsecond)
+	}
+	rec := &PcapRecord{
+		Time:    time.Unix(sec, frac).UTC(),
+		OrigLen: int(pr.order.Uint32(hdr[12:16])),
+		Data:    make([]byte, inclLen),
+	}
+	if _, err := io.ReadFull(pr.r, rec.Data); err != nil {
+		return nil, fmt.Errorf("truncated record: %w", ErrInvalidPcap)
+	}
+	return rec, nil
+}
+
+// Close closes the file the reader was opened from, if any.
+func (pr *PcapReader) Close() error {
+	if pr.closer == nil {
+		return nil
+	}
+	return pr.closer.Close()
+}
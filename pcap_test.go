@@ -0,0 +1,85 @@
+package virtualbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// pcapFile returns a pcap file in the byte order with the packets captured at
+// the Unix time 1 plus 5 microseconds.
+func pcapFile(order binary.ByteOrder, magic uint32, packets ...[]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	order.PutUint32(hdr[0:], magic)
+	order.PutUint16(hdr[4:], 2)
+	order.PutUint16(hdr[6:], 4)
+	order.PutUint32(hdr[16:], 65535)
+	order.PutUint32(hdr[20:], LinkTypeEthernet)
+	b.Write(hdr)
+	for _, p := range packets {
+		rec := make([]byte, 16)
+		frac := uint32(5)
+		if magic == pcapMagicNano {
+			frac *= 1000
+		}
+		order.PutUint32(rec[0:], 1)
+		order.PutUint32(rec[4:], frac)
+		order.PutUint32(rec[8:], uint32(len(p)))
+		order.PutUint32(rec[12:], uint32(len(p))+10)
+		b.Write(rec)
+		b.Write(p)
+	}
+	return b.Bytes()
+}
+
+func TestPcapReader(t *testing.T) {
+	testCases := map[string]struct {
+		order binary.ByteOrder
+		magic uint32
+	}{
+		"little endian micro": {binary.LittleEndian, pcapMagicMicro},
+		"big endian nano":     {binary.BigEndian, pcapMagicNano},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			data := pcapFile(tc.order, tc.magic, []byte{1, 2, 3}, []byte{4})
+			pr, err := NewPcapReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pr.LinkType != LinkTypeEthernet || pr.SnapLen != 65535 {
+				t.Errorf("NewPcapReader() = %+v; want Ethernet with 65535 snapshot length", pr)
+			}
+			rec, err := pr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rec.Time.Equal(time.Unix(1, 5000)) || rec.OrigLen != 13 || !bytes.Equal(rec.Data, []byte{1, 2, 3}) {
+				t.Errorf("Next() = %+v; want the first packet", rec)
+			}
+			if rec, err := pr.Next(); err != nil || !bytes.Equal(rec.Data, []byte{4}) {
+				t.Errorf("Next() = %+v, %v; want the second packet", rec, err)
+			}
+			if _, err := pr.Next(); !errors.Is(err, io.EOF) {
+				t.Errorf("Next() = %v; want %v", err, io.EOF)
+			}
+		})
+	}
+
+	if _, err := NewPcapReader(bytes.NewReader(make([]byte, 24))); !errors.Is(err, ErrInvalidPcap) {
+		t.Errorf("NewPcapReader() of a bad magic = %v; want %v", err, ErrInvalidPcap)
+	}
+	data := pcapFile(binary.LittleEndian, pcapMagicMicro, []byte{1, 2, 3})
+	pr, err := NewPcapReader(bytes.NewReader(data[:len(data)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pr.Next(); !errors.Is(err, ErrInvalidPcap) {
+		t.Errorf("Next() of a truncated record = %v; want %v", err, ErrInvalidPcap)
+	}
+}
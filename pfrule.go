@@ -7,6 +7,7 @@ import (
 
 // PFRule represents a port forwarding rule.
 type PFRule struct {
+	Name      string // used by the NAT networks, the NAT NICs get it separately
 	Proto     PFProto
 	HostIP    net.IP // can be nil to match any host interface
 	GuestIP   net.IP // can be nil if guest IP is leased from built-in DHCP
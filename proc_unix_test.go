@@ -0,0 +1,53 @@
+//go:build !windows
+// +build !windows
+
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommandRunCancel(t *testing.T) {
+	// The shell forks sleep which keeps stdout open, so the run only returns
+	// in time if the whole process group is killed.
+	cmd := command{program: "sh"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := cmd.run(ctx, "-c", "sleep 10; true")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run() = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("run() returned after %v; want the command to be killed", d)
+	}
+}
+
+func TestCommandRunCanceledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := (command{program: "true"}).run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("run() = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestCommandRunCancelAfterExit(t *testing.T) {
+	// The shell exits at once, but its background sleep keeps stdout open
+	// until the group is killed.
+	cmd := command{program: "sh"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := cmd.run(ctx, "-c", "sleep 10 & exit 0")
+	if !errors.Is(err, context.DeadlineExceeded) && err != nil {
+		t.Errorf("run() = %v; want nil or %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("run() returned after %v; want the children to be killed", d)
+	}
+}
@@ -0,0 +1,21 @@
+package virtualbox
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// waitExited blocks until the process has exited, without reaping it, so its
+// process group ID can not be reused until Wait is called.
+func waitExited(pid int) {
+	const pPID = 1 // P_PID
+	// The siginfo_t filled in by waitid, which is 128 bytes on Linux.
+	var info [128]byte
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid),
+			uintptr(unsafe.Pointer(&info)), syscall.WEXITED|syscall.WNOWAIT, 0, 0) // #nosec
+		if errno != syscall.EINTR {
+			return
+		}
+	}
+}
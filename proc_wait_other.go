@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package virtualbox
+
+// waitExited is a no-op where the exit of a process can not be waited for
+// without reaping it, the process is only reaped by Wait.
+func waitExited(pid int) {}
@@ -0,0 +1,13 @@
+package virtualbox
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows, process groups can not be signalled.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process started by the command.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,251 @@
+package virtualbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Call is a single invocation of VBoxManage, as written by the Recorder and
+// served by the Replayer.
+type Call struct {
+	Args     []string      `json:"args"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`    // set when the command could not run at all
+	ErrorIs  string        `json:"error_is,omitempty"` // the message of the sentinel error matched by Error, see replayedErrors
+	Duration time.Duration `json:"duration"`
+}
+
+// ExitError is returned for the replayed calls which exited with a non-zero
+// exit code.
+type ExitError struct {
+	Code   int
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// replayedErrors are the sentinel errors which the manager checks for with
+// errors.Is, so a replayed Error still matches them.
+var replayedErrors = []error{
+	ErrCommandNotFound,
+	ErrNotHost,
+	context.Canceled,
+	context.DeadlineExceeded,
+}
+
+// replayedError is a recorded Error, which wraps the sentinel error it
+// matched when it was recorded.
+type replayedError struct {
+	msg string
+	err error
+}
+
+func (e *replayedError) Error() string { return e.msg }
+
+func (e *replayedError) Unwrap() error { return e.err }
+
+// replayError returns the error of the call, which matches the same sentinel
+// error as the recorded one.
+func (c Call) replayError() error {
+	is := c.ErrorIs
+	if is == "" {
+		// The fixtures recorded without ErrorIs only match the sentinel
+		// errors returned unwrapped.
+		is = c.Error
+	}
+	for _, sentinel := range replayedErrors {
+		if sentinel.Error() != is {
+			continue
+		}
+		if c.Error == is {
+			return sentinel
+		}
+		return &replayedError{msg: c.Error, err: sentinel}
+	}
+	return errors.New(c.Error)
+}
+
+// Recorder records all the VBoxManage invocations of a manager, so they can be
+// stored as a fixture and replayed in tests with the Replayer.
+type Recorder struct {
+	mu    sync.Mutex
+	next  runFn
+	calls []Call
+}
+
+// Record records the commands of the manager with the given recorder, the
+// commands are still executed as usual. The output of the guest processes is
+// not streamed but buffered, so it can be recorded.
+func Record(r *Recorder) Option {
+	return func(m *Manager) {
+		r.next = m.runner
+		m.runner = r.run
+		m.streamer = nil
+	}
+}
+
+func (r *Recorder) run(ctx context.Context, args ...string) (string, string, error) {
+	start := time.Now()
+	stdout, stderr, err := r.next(ctx, args...)
+	c := Call{
+		Args:     append([]string(nil), args...),
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Duration: time.Since(start),
+	}
+	var ee *exec.ExitError
+	var re *ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &ee):
+		c.ExitCode = ee.ExitCode()
+	case errors.As(err, &re):
+		c.ExitCode = re.Code
+	default:
+		c.ExitCode = -1
+		c.Error = err.Error()
+		for _, sentinel := range replayedErrors {
+			if errors.Is(err, sentinel) {
+				c.ErrorIs = sentinel.Error()
+				break
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.calls = append(r.calls, c)
+	r.mu.Unlock()
+
+	return stdout, stderr, err
+}
+
+// Calls returns the calls recorded so far.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// WriteFile writes the recorded calls into the fixture file.
+func (r *Recorder) WriteFile(name string) error {
+	data, err := json.MarshalIndent(r.Calls(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644) // #nosec
+}
+
+// Replayer serves previously recorded calls instead of running VBoxManage.
+//
+// The calls are served in the recorded order, and any call which does not
+// match the arguments of the next recorded call fails. The commands which the
+// manager runs in parallel, e.g. for ListMachines, can only be replayed in any
+// order, see Unordered.
+type Replayer struct {
+	mu        sync.Mutex
+	calls     []Call
+	used      []bool
+	unordered bool
+}
+
+// NewReplayer returns a replayer serving the given calls.
+func NewReplayer(calls ...Call) *Replayer {
+	return &Replayer{
+		calls: calls,
+		used:  make([]bool, len(calls)),
+	}
+}
+
+// Unordered makes the replayer serve any of the remaining calls with the same
+// arguments instead of only the next one, for the commands run in parallel.
+// The calls with the same arguments are still served in the recorded order.
+func (r *Replayer) Unordered() *Replayer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unordered = true
+	return r
+}
+
+// LoadReplayer returns a replayer serving the calls from the fixture file
+// written by the Recorder.
+func LoadReplayer(name string) (*Replayer, error) {
+	data, err := os.ReadFile(name) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	var calls []Call
+	if err := json.Unmarshal(data, &calls); err != nil {
+		return nil, fmt.Errorf("unable to parse fixture %s: %w", name, err)
+	}
+	return NewReplayer(calls...), nil
+}
+
+// Replay makes the manager use the replayer instead of running VBoxManage.
+func Replay(r *Replayer) Option {
+	return func(m *Manager) {
+		m.runner = r.run
+		m.streamer = nil
+	}
+}
+
+func (r *Replayer) run(ctx context.Context, args ...string) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next *Call
+	for i := range r.calls {
+		if r.used[i] {
+			continue
+		}
+		if next == nil {
+			next = &r.calls[i]
+		} else if !r.unordered {
+			break
+		}
+		if reflect.DeepEqual(r.calls[i].Args, args) {
+			r.used[i] = true
+			c := r.calls[i]
+			switch {
+			case c.Error != "":
+				return c.Stdout, c.Stderr, c.replayError()
+			case c.ExitCode != 0:
+				return c.Stdout, c.Stderr, &ExitError{Code: c.ExitCode, Stderr: c.Stderr}
+			}
+			return c.Stdout, c.Stderr, nil
+		}
+	}
+	if next == nil {
+		return "", "", fmt.Errorf("unexpected call %q: no calls left", args)
+	}
+	return "", "", fmt.Errorf("unexpected call %q: want %q", args, next.Args)
+}
+
+// Err returns an error when some of the calls were not replayed.
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var left [][]string
+	for i, c := range r.calls {
+		if !r.used[i] {
+			left = append(left, c.Args)
+		}
+	}
+	if len(left) > 0 {
+		return fmt.Errorf("%d calls were not replayed: %q", len(left), left)
+	}
+	return nil
+}
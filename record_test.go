@@ -0,0 +1,143 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	rec := &Recorder{}
+	m := NewManager()
+	m.runner = func(_ context.Context, args ...string) (string, string, error) {
+		if args[0] == "showvminfo" {
+			return "", "VBoxManage: error: Could not find a registered machine named 'foo'\n", &ExitError{Code: 1}
+		}
+		return "6.1.38r153438\n", "", nil
+	}
+	Record(rec)(m)
+
+	if _, err := m.Version(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Machine(ctx, "foo"); !errors.Is(err, ErrMachineNotExist) {
+		t.Fatalf("Machine() = %v; want %v", err, ErrMachineNotExist)
+	}
+
+	name := filepath.Join(t.TempDir(), "fixture.json")
+	if err := rec.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReplayer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(r.calls, rec.Calls()); diff != nil {
+		t.Errorf("loaded calls differ from the recorded ones: %v", diff)
+	}
+
+	m = NewManager(Replay(r))
+	// The calls are served in the recorded order only.
+	if _, err := m.Machine(ctx, "foo"); err == nil || errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("replayed Machine() out of order = %v; want an unexpected call", err)
+	}
+	if v, err := m.Version(ctx); err != nil || v.String() != "6.1.38r153438" {
+		t.Errorf("replayed Version() = %s, %v; want 6.1.38r153438, nil", v, err)
+	}
+	if err := r.Err(); err == nil {
+		t.Error("Err() = nil with a call left; want error")
+	}
+	if _, err := m.Machine(ctx, "foo"); !errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("replayed Machine() = %v; want %v", err, ErrMachineNotExist)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() = %v; want nil", err)
+	}
+
+	// The calls with different arguments can be replayed in any order when
+	// the replayer is unordered.
+	r = NewReplayer(rec.Calls()...).Unordered()
+	m = NewManager(Replay(r))
+	if _, err := m.Machine(ctx, "foo"); !errors.Is(err, ErrMachineNotExist) {
+		t.Errorf("unordered Machine() = %v; want %v", err, ErrMachineNotExist)
+	}
+	if v, err := m.Version(ctx); err != nil || v.String() != "6.1.38r153438" {
+		t.Errorf("unordered Version() = %s, %v; want 6.1.38r153438, nil", v, err)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() = %v; want nil", err)
+	}
+}
+
+func TestRecordReplayErrors(t *testing.T) {
+	ctx := context.Background()
+	errs := map[string]error{
+		"list":       ErrCommandNotFound,
+		"--version":  fmt.Errorf("VBoxManage killed: %w", context.DeadlineExceeded),
+		"showvminfo": errors.New("fork/exec VBoxManage: permission denied"),
+	}
+	order := []string{"list", "--version", "showvminfo"}
+	rec := &Recorder{}
+	m := NewManager()
+	m.runner = func(_ context.Context, args ...string) (string, string, error) {
+		return "", "", errs[args[0]]
+	}
+	Record(rec)(m)
+	for _, arg := range order {
+		if _, _, err := m.run(ctx, arg); err == nil {
+			t.Fatalf("recorded %s succeeded", arg)
+		}
+	}
+
+	name := filepath.Join(t.TempDir(), "fixture.json")
+	if err := rec.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReplayer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = NewManager(Replay(r))
+	for _, arg := range order {
+		_, _, err := m.run(ctx, arg)
+		want := errs[arg]
+		if err == nil || err.Error() != want.Error() {
+			t.Errorf("replayed %s = %v; want %v", arg, err, want)
+		}
+		for _, sentinel := range replayedErrors {
+			if errors.Is(err, sentinel) != errors.Is(want, sentinel) {
+				t.Errorf("replayed %s = %v; errors.Is(%v) = %t, want %t", arg, err, sentinel, errors.Is(err, sentinel), errors.Is(want, sentinel))
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+
+	// The fixtures recorded before ErrorIs still match the unwrapped ones.
+	r = NewReplayer(Call{Args: []string{"list", "vms"}, ExitCode: -1, Error: ErrCommandNotFound.Error()})
+	m = NewManager(Replay(r))
+	if _, _, err := m.run(ctx, "list", "vms"); err != ErrCommandNotFound {
+		t.Errorf("replayed legacy call = %v; want %v", err, ErrCommandNotFound)
+	}
+}
+
+func TestReplayUnexpected(t *testing.T) {
+	r := NewReplayer(Call{Args: []string{"list", "vms"}})
+	m := NewManager(Replay(r))
+
+	if _, _, err := m.run(context.Background(), "list", "hostonlyifs"); err == nil {
+		t.Error("unexpected call succeeded")
+	}
+	if _, _, err := m.run(context.Background(), "list", "vms"); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := m.run(context.Background(), "list", "vms"); err == nil {
+		t.Error("call after all the calls were replayed succeeded")
+	}
+}
@@ -1,5 +1,10 @@
 package virtualbox
 
+import (
+	"context"
+	"fmt"
+)
+
 // StorageController represents a virtualized storage controller.
 type StorageController struct {
 	SysBus      SystemBus
@@ -65,6 +70,41 @@ type StorageMedium struct {
 	Device    uint
 	DriveType DriveType
 	Medium    string // none|emptydrive|<uuid>|<filename|host:<drive>|iscsi
+
+	BandwidthGroup string // the bandwidth group limiting the medium
+}
+
+// attachArgs returns the arguments of storageattach attaching the medium to
+// the storage controller of the machine.
+func (medium StorageMedium) attachArgs(vm, ctlName string) []string {
+	args := []string{"storageattach", vm, "--storagectl", ctlName,
+		"--port", fmt.Sprintf("%d", medium.Port),
+		"--device", fmt.Sprintf("%d", medium.Device),
+		"--type", string(medium.DriveType),
+		"--medium", medium.Medium,
+	}
+	if medium.BandwidthGroup != "" {
+		args = append(args, "--bandwidthgroup", medium.BandwidthGroup)
+	}
+	return args
+}
+
+// AttachStorage attaches the medium to the storage controller of the machine,
+// limited by its BandwidthGroup when it is set. Attaching the "none" medium
+// detaches the one in the same port and device.
+func (m *Manager) AttachStorage(ctx context.Context, id, ctlName string, medium StorageMedium) error {
+	m.log.Printf("attaching %q to %q of %q", medium.Medium, ctlName, id)
+	_, stderr, err := m.runMachine(ctx, id, medium.attachArgs(id, ctlName)...)
+	if err != nil {
+		switch {
+		case reMachineNotFound.MatchString(stderr):
+			return ErrMachineNotExist
+		case reBandwidthGroupNotFound.MatchString(stderr):
+			return fmt.Errorf("%s: %w", medium.BandwidthGroup, ErrBandwidthGroupNotExist)
+		}
+		return fmt.Errorf("unable to attach storage to %q: %w", id, err)
+	}
+	return nil
 }
 
 // DriveType represents the hardware type of a drive.
@@ -81,6 +121,6 @@ const (
 
 // CloneHD virtual harddrive
 func CloneHD(input, output string) error {
-	_, _, err := Manage().run("clonehd", input, output)
+	_, _, err := Manage().run(context.Background(), "clonehd", input, output)
 	return err
 }
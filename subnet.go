@@ -0,0 +1,308 @@
+package virtualbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoFreeSubnet is returned when all the private subnets of the requested
+	// size are in use.
+	ErrNoFreeSubnet = errors.New("no free subnet")
+)
+
+// procNetRoute is the routing table of the host on Linux, the tests replace it
+// with a file in testdata.
+var procNetRoute = "/proc/net/route"
+
+// privateRanges are the private IPv4 ranges searched for a free subnet, in the
+// order of preference. 192.168.56.0/24 is the default of VirtualBox and
+// therefore tried first.
+var privateRanges = []*net.IPNet{
+	{IP: net.IPv4(192, 168, 56, 0).To4(), Mask: net.CIDRMask(21, 32)},
+	{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+	{IP: net.IPv4(172, 16, 0, 0).To4(), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+}
+
+// UsedSubnets returns the IPv4 subnets used by the host-only interfaces and
+// networks, the NAT networks, the DHCP servers and the routing table of the
+// host. The routing table is only read on Linux.
+func (m *Manager) UsedSubnets(ctx context.Context) ([]*net.IPNet, error) {
+	var used []*net.IPNet
+	add := func(ip net.IP, mask net.IPMask) {
+		ip = ip.To4()
+		if ip == nil || len(mask) == 0 {
+			return
+		}
+		if ones, _ := mask.Size(); ones == 0 {
+			return
+		}
+		used = append(used, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
+	}
+
+	ifs, err := m.HostonlyNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedHostonlyNames(ifs) {
+		add(ifs[name].IPv4.IP, ifs[name].IPv4.Mask)
+	}
+	// The host-only networks of VirtualBox 7 are skipped when the version is
+	// unknown, the other subnets are still worth avoiding.
+	switch err := m.require(ctx, CapHostonlyNet); {
+	case err == nil:
+		nets, err := m.HostonlyNetworks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nets {
+			add(n.LowerIP, n.Netmask)
+		}
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case !errors.Is(err, ErrUnsupported):
+		m.log.Printf("skipping the hostonly networks: %v", err)
+	}
+	natnets, err := m.NATNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range natnets {
+		add(n.IPv4.IP, n.IPv4.Mask)
+	}
+	dhcps, err := m.DHCPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dhcps {
+		add(d.IPv4.IP, d.IPv4.Mask)
+	}
+
+	routes, err := hostRoutes()
+	if err != nil {
+		return nil, err
+	}
+	return append(used, routes...), nil
+}
+
+// FreeSubnet returns a private IPv4 subnet with the given prefix length, which
+// does not overlap any of the UsedSubnets.
+func (m *Manager) FreeSubnet(ctx context.Context, prefixLen int) (*net.IPNet, error) {
+	if prefixLen < 8 || prefixLen > 30 {
+		return nil, fmt.Errorf("invalid prefix length %d", prefixLen)
+	}
+	used, err := m.UsedSubnets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return freeSubnet(used, prefixLen)
+}
+
+// freeSubnet returns the first subnet of the private ranges with the prefix
+// length which does not overlap any of the used subnets.
+func freeSubnet(used []*net.IPNet, prefixLen int) (*net.IPNet, error) {
+	mask := net.CIDRMask(prefixLen, 32)
+	size := uint32(1) << (32 - prefixLen)
+	for _, r := range privateRanges {
+		ones, _ := r.Mask.Size()
+		if ones > prefixLen {
+			continue
+		}
+		first := binary.BigEndian.Uint32(r.IP)
+		last := first + uint32(1)<<(32-ones) - 1
+		for a := first; a >= first && a+size-1 <= last; a += size {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, a)
+			candidate := &net.IPNet{IP: ip, Mask: mask}
+			if !overlapsAny(candidate, used) {
+				return candidate, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("/%d: %w", prefixLen, ErrNoFreeSubnet)
+}
+
+// overlapsAny reports whether the subnet overlaps any of the others.
+func overlapsAny(n *net.IPNet, others []*net.IPNet) bool {
+	for _, o := range others {
+		if o.Contains(n.IP) || n.Contains(o.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostRoutes returns the subnets routed by the host, except the default route.
+func hostRoutes() ([]*net.IPNet, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(procNetRoute)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the routing table: %w", err)
+	}
+	return parseProcNetRoute(string(b))
+}
+
+// parseProcNetRoute parses the routing table in the format of /proc/net/route,
+// where the destination and the mask are little-endian hexadecimal numbers.
+func parseProcNetRoute(s string) ([]*net.IPNet, error) {
+	var routes []*net.IPNet
+	for i, line := range lines(s) {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 8 {
+			continue
+		}
+		dst, err := parseRouteAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		mask, err := parseRouteAddr(fields[7])
+		if err != nil {
+			return nil, err
+		}
+		if ones, _ := net.IPMask(mask).Size(); ones == 0 {
+			continue
+		}
+		routes = append(routes, &net.IPNet{IP: dst.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)})
+	}
+	return routes, nil
+}
+
+// parseRouteAddr parses an address of /proc/net/route, e.g. 0038A8C0 is
+// 192.168.56.0.
+func parseRouteAddr(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != net.IPv4len {
+		return nil, fmt.Errorf("invalid route address %q", s)
+	}
+	return net.IPv4(b[3], b[2], b[1], b[0]).To4(), nil
+}
+
+// EnsureHostonlyNet returns the host-only interface whose IPv4 network is the
+// given CIDR, e.g. "192.168.56.1/24". When there is none, a new interface is
+// created with the host at the given address, or the first address of the
+// network when the network address is given. A DHCP server is set up to serve
+// the rest of the network, the new interface is removed again when that
+// fails. An existing interface is returned as it is, with or without a DHCP
+// server. An empty CIDR selects a free /24 subnet.
+func (m *Manager) EnsureHostonlyNet(ctx context.Context, cidr string) (*HostonlyNet, error) {
+	var (
+		ip  net.IP
+		sub *net.IPNet
+		err error
+	)
+	if cidr == "" {
+		if sub, err = m.FreeSubnet(ctx, 24); err != nil {
+			return nil, err
+		}
+		ip = sub.IP
+	} else if ip, sub, err = net.ParseCIDR(cidr); err != nil {
+		return nil, err
+	}
+	ip = ip.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid CIDR %q: only IPv4 is supported", cidr)
+	}
+	if ones, _ := sub.Mask.Size(); ones > 29 {
+		return nil, fmt.Errorf("invalid CIDR %q: the network is too small for DHCP", cidr)
+	}
+
+	ifs, err := m.HostonlyNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedHostonlyNames(ifs) {
+		n := ifs[name]
+		if n.IPv4.IP != nil && sub.Contains(n.IPv4.IP) && n.IPv4.Mask.String() == sub.Mask.String() {
+			m.log.Printf("reusing hostonly interface %q for %s", n.Name, sub)
+			return n, nil
+		}
+	}
+
+	first := binary.BigEndian.Uint32(sub.IP)
+	ones, _ := sub.Mask.Size()
+	last := first + uint32(1)<<(32-ones) - 1
+	addr := func(a uint32) net.IP {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, a)
+		return ip
+	}
+	host := binary.BigEndian.Uint32(ip)
+	if host == first || host == last {
+		host = first + 1
+	}
+	server := first + 1
+	if server == host {
+		server++
+	}
+	// The DHCP range takes the larger part of the network beside the host.
+	lower, upper := server+1, last-1
+	if host >= lower && host <= upper {
+		if host-lower > upper-host {
+			upper = host - 1
+		} else {
+			lower = host + 1
+		}
+	}
+
+	n, err := m.CreateHostonlyNet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setupHostonlyNet(ctx, n, addr(host), addr(server), addr(lower), addr(upper), sub.Mask); err != nil {
+		// The context may have ended, the interface is removed without it.
+		if rerr := m.RemoveHostonlyNet(context.Background(), n.Name); rerr != nil {
+			m.log.Printf("unable to remove hostonly interface %q: %v", n.Name, rerr)
+		}
+		return nil, err
+	}
+	return n, nil
+}
+
+// setupHostonlyNet configures the new host-only interface with the host
+// address and sets up its DHCP server with the range of addresses.
+func (m *Manager) setupHostonlyNet(ctx context.Context, n *HostonlyNet, host, server, lower, upper net.IP, mask net.IPMask) error {
+	n.IPv4 = net.IPNet{IP: host, Mask: mask}
+	n.IPv6 = net.IPNet{}
+	n.DHCP = false
+	if err := m.ConfigHostonlyNet(ctx, n); err != nil {
+		return err
+	}
+	d := DHCP{
+		NetworkName: n.NetworkName,
+		IPv4:        net.IPNet{IP: server, Mask: mask},
+		LowerIP:     lower,
+		UpperIP:     upper,
+		Enabled:     true,
+	}
+	err := m.AddDHCP(ctx, d)
+	if errors.Is(err, ErrDHCPExist) {
+		if err = m.ModifyDHCP(ctx, d); err == nil {
+			err = m.EnableDHCP(ctx, d.NetworkName)
+		}
+	}
+	return err
+}
+
+// sortedHostonlyNames returns the names of the host-only interfaces in order.
+func sortedHostonlyNames(ifs map[string]*HostonlyNet) []string {
+	names := make([]string, 0, len(ifs))
+	for name := range ifs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
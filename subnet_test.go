@@ -0,0 +1,284 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestParseProcNetRoute(t *testing.T) {
+	in := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n" +
+		"vboxnet0\t0038A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n"
+	got, err := parseProcNetRoute(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*net.IPNet{mustParseCIDR("192.168.1.0/24"), mustParseCIDR("192.168.56.0/24")}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("parseProcNetRoute() = %v; diff = %v", got, diff)
+	}
+	if _, err := parseProcNetRoute(in + "eth1\tnothex\t00000000\t0001\t0\t0\t0\t00FFFFFF\n"); err == nil {
+		t.Error("parseProcNetRoute() of an invalid destination succeeded")
+	}
+}
+
+func TestFreeSubnet(t *testing.T) {
+	testCases := map[string]struct {
+		used      []string
+		prefixLen int
+		want      string
+		err       error
+	}{
+		"default": {
+			prefixLen: 24,
+			want:      "192.168.56.0/24",
+		},
+		"next": {
+			used:      []string{"192.168.56.0/24", "192.168.57.0/24"},
+			prefixLen: 24,
+			want:      "192.168.58.0/24",
+		},
+		"larger used": {
+			used:      []string{"192.168.56.0/22"},
+			prefixLen: 24,
+			want:      "192.168.60.0/24",
+		},
+		"smaller used": {
+			used:      []string{"192.168.56.128/25"},
+			prefixLen: 24,
+			want:      "192.168.57.0/24",
+		},
+		"next range": {
+			used:      []string{"192.168.0.0/16"},
+			prefixLen: 24,
+			want:      "172.16.0.0/24",
+		},
+		"larger than a range": {
+			prefixLen: 16,
+			want:      "192.168.0.0/16",
+		},
+		"none": {
+			used:      []string{"0.0.0.0/1", "128.0.0.0/1"},
+			prefixLen: 24,
+			err:       ErrNoFreeSubnet,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var used []*net.IPNet
+			for _, s := range tc.used {
+				used = append(used, mustParseCIDR(s))
+			}
+			got, err := freeSubnet(used, tc.prefixLen)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("freeSubnet() = %v; want %v", err, tc.err)
+			}
+			if err == nil && got.String() != tc.want {
+				t.Errorf("freeSubnet() = %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// replaceRoutes makes the manager read the routing table from testdata.
+func replaceRoutes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the routing table is only read on Linux")
+	}
+	orig := procNetRoute
+	procNetRoute = "testdata/proc-net-route"
+	t.Cleanup(func() { procNetRoute = orig })
+}
+
+func TestManagerUsedSubnets(t *testing.T) {
+	replaceRoutes(t)
+	listIfs := Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs}
+	listNATNets := Call{Args: []string{"list", "natnets"}, Stdout: listNATNets7}
+	listDHCPs := Call{Args: []string{"list", "dhcpservers"}, Stdout: ReadTestData("vboxmanage-list-dhcpservers-1.out")}
+	routes := []string{"192.168.1.0/24", "192.168.58.0/24"}
+
+	testCases := map[string]struct {
+		calls []Call
+		want  []string
+	}{
+		"7.0": {
+			calls: []Call{
+				listIfs,
+				{Args: []string{"--version"}, Stdout: "7.0.10r158379\n"},
+				{Args: []string{"list", "hostonlynets"}, Stdout: listHostonlyNets},
+				listNATNets,
+				listDHCPs,
+			},
+			want: []string{"192.168.56.0/24", "192.168.57.0/24", "192.168.56.0/24", "10.0.2.0/24", "192.168.56.0/24"},
+		},
+		"6.1": {
+			calls: []Call{
+				listIfs,
+				{Args: []string{"--version"}, Stdout: "6.1.38r153438\n"},
+				listNATNets,
+				listDHCPs,
+			},
+			want: []string{"192.168.56.0/24", "192.168.57.0/24", "10.0.2.0/24", "192.168.56.0/24"},
+		},
+		"unknown version": {
+			calls: []Call{
+				listIfs,
+				{Args: []string{"--version"}, Stderr: "VBoxManage: error: failed\n", ExitCode: 1},
+				listNATNets,
+				listDHCPs,
+			},
+			want: []string{"192.168.56.0/24", "192.168.57.0/24", "10.0.2.0/24", "192.168.56.0/24"},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := NewReplayer(tc.calls...)
+			m := NewManager(Replay(r))
+			used, err := m.UsedSubnets(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range used {
+				got = append(got, n.String())
+			}
+			if diff := deep.Equal(got, append(tc.want, routes...)); diff != nil {
+				t.Errorf("UsedSubnets() = %v; diff = %v", got, diff)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestManagerFreeSubnet(t *testing.T) {
+	replaceRoutes(t)
+	r := NewReplayer(
+		Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs},
+		Call{Args: []string{"--version"}, Stdout: "6.1.38r153438\n"},
+		Call{Args: []string{"list", "natnets"}, Stdout: listNATNets7},
+		Call{Args: []string{"list", "dhcpservers"}},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+	if _, err := m.FreeSubnet(ctx, 31); err == nil {
+		t.Error("FreeSubnet() of a /31 succeeded")
+	}
+	// 192.168.56.0/24 and 192.168.57.0/24 are host-only interfaces and
+	// 192.168.58.0/24 is routed by the host.
+	got, err := m.FreeSubnet(ctx, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "192.168.59.0/24" {
+		t.Errorf("FreeSubnet() = %s; want 192.168.59.0/24", got)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerEnsureHostonlyNetFreeSubnet(t *testing.T) {
+	replaceRoutes(t)
+	list := Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs}
+	r := NewReplayer(
+		list,
+		Call{Args: []string{"--version"}, Stdout: "6.1.38r153438\n"},
+		Call{Args: []string{"list", "natnets"}},
+		Call{Args: []string{"list", "dhcpservers"}},
+		list,
+		Call{Args: []string{"hostonlyif", "create"},
+			Stdout: "Interface 'vboxnet1' was successfully created\n"},
+		list,
+		Call{Args: []string{"hostonlyif", "ipconfig", "vboxnet1", "--ip", "192.168.59.1", "--netmask", "255.255.255.0"}},
+		Call{Args: []string{"dhcpserver", "add", "--network", "HostInterfaceNetworking-vboxnet1",
+			"--ip", "192.168.59.2", "--netmask", "255.255.255.0",
+			"--lowerip", "192.168.59.3", "--upperip", "192.168.59.254", "--enable"}},
+	)
+	m := NewManager(Replay(r))
+	n, err := m.EnsureHostonlyNet(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.IPv4.IP.Equal(net.IPv4(192, 168, 59, 1)) {
+		t.Errorf("EnsureHostonlyNet() = %+v; want 192.168.59.1", n)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerEnsureHostonlyNet(t *testing.T) {
+	list := Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs}
+	r := NewReplayer(
+		list,
+		list,
+		Call{Args: []string{"hostonlyif", "create"},
+			Stdout: "Interface 'vboxnet1' was successfully created\n"},
+		list,
+		Call{Args: []string{"hostonlyif", "ipconfig", "vboxnet1", "--ip", "192.168.60.1", "--netmask", "255.255.255.0"}},
+		Call{Args: []string{"dhcpserver", "add", "--network", "HostInterfaceNetworking-vboxnet1",
+			"--ip", "192.168.60.2", "--netmask", "255.255.255.0",
+			"--lowerip", "192.168.60.3", "--upperip", "192.168.60.254", "--enable"}},
+	)
+	m := NewManager(Replay(r))
+	ctx := context.Background()
+
+	n, err := m.EnsureHostonlyNet(ctx, "192.168.57.0/24")
+	if err != nil || n.Name != "vboxnet1" {
+		t.Errorf("EnsureHostonlyNet() = %+v, %v; want the existing vboxnet1", n, err)
+	}
+	n, err = m.EnsureHostonlyNet(ctx, "192.168.60.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.IPv4.IP.Equal(net.IPv4(192, 168, 60, 1)) {
+		t.Errorf("EnsureHostonlyNet() = %+v; want 192.168.60.1", n)
+	}
+	if _, err := m.EnsureHostonlyNet(ctx, "fd00::/64"); err == nil {
+		t.Error("EnsureHostonlyNet() of an IPv6 network succeeded")
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerEnsureHostonlyNetCleanup(t *testing.T) {
+	list := Call{Args: []string{"list", "hostonlyifs"}, Stdout: listHostonlyIfs}
+	r := NewReplayer(
+		list,
+		Call{Args: []string{"hostonlyif", "create"},
+			Stdout: "Interface 'vboxnet1' was successfully created\n"},
+		list,
+		Call{Args: []string{"hostonlyif", "ipconfig", "vboxnet1", "--ip", "192.168.60.1", "--netmask", "255.255.255.0"}},
+		Call{Args: []string{"dhcpserver", "add", "--network", "HostInterfaceNetworking-vboxnet1",
+			"--ip", "192.168.60.2", "--netmask", "255.255.255.0",
+			"--lowerip", "192.168.60.3", "--upperip", "192.168.60.254", "--enable"},
+			Stderr: "VBoxManage: error: Failed to create the DHCP server\n", ExitCode: 1},
+		Call{Args: []string{"hostonlyif", "remove", "vboxnet1"}},
+	)
+	m := NewManager(Replay(r))
+	if n, err := m.EnsureHostonlyNet(context.Background(), "192.168.60.0/24"); err == nil {
+		t.Errorf("EnsureHostonlyNet() = %+v; want an error", n)
+	}
+	if err := r.Err(); err != nil {
+		t.Error(err)
+	}
+}
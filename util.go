@@ -18,8 +18,8 @@ func ParseIPv4Mask(s string) net.IPMask {
 // Run is a helper method used to execute the commands using the configured
 // VBoxManage path. The command should be omitted and only the arguments
 // should be passed. It will return the stdout, stderr and error if one
-// occured during command execution.
-func Run(_ context.Context, args ...string) (string, string, error) {
-	// TODO: Convert the function so you can pass in the context.
-	return Manage().run(args...)
+// occured during command execution. Cancelling the context kills the
+// running command.
+func Run(ctx context.Context, args ...string) (string, string, error) {
+	return Manage().run(ctx, args...)
 }
@@ -5,9 +5,15 @@ package virtualbox
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"os/exec"
 	"runtime"
+	"sync"
 )
 
 type option func(Command)
@@ -18,7 +24,7 @@ type Command interface {
 	setOpts(opts ...option) Command
 	isGuest() bool
 	path() string
-	run(args ...string) (string, string, error)
+	run(ctx context.Context, args ...string) (string, string, error)
 }
 
 var (
@@ -37,6 +43,9 @@ type command struct {
 	sudoer  bool // Is current user a sudoer?
 	sudo    bool // Is current command expected to be run under sudo?
 	guest   bool
+	wrapper []string // Privilege escalation command always prepended, e.g. sudo -n
+	env     []string // Additional environment in the form of KEY=VALUE
+	dir     string
 }
 
 func (vbcmd command) setOpts(opts ...option) Command {
@@ -67,27 +76,171 @@ func (vbcmd command) prepare(args []string) *exec.Cmd {
 	program := vbcmd.program
 	argv := []string{}
 	Debug("Command: '%+v', runtime.GOOS: '%s'", vbcmd, runtime.GOOS)
-	if vbcmd.sudoer && vbcmd.sudo && runtime.GOOS != osWindows {
+	if len(vbcmd.wrapper) > 0 {
+		program = vbcmd.wrapper[0]
+		argv = append(argv, vbcmd.wrapper[1:]...)
+		argv = append(argv, vbcmd.program)
+	} else if vbcmd.sudoer && vbcmd.sudo && runtime.GOOS != osWindows {
 		program = "sudo"
 		argv = append(argv, vbcmd.program)
 	}
 	argv = append(argv, args...)
 	Debug("executing: %v %v", program, argv)
-	return exec.Command(program, argv...) // #nosec
+	cmd := exec.Command(program, argv...) // #nosec
+	if len(vbcmd.env) > 0 {
+		cmd.Env = append(os.Environ(), vbcmd.env...)
+	}
+	cmd.Dir = vbcmd.dir
+	setProcessGroup(cmd)
+	return cmd
 }
 
-func (vbcmd command) run(args ...string) (string, string, error) {
+// run executes the command and waits for it to finish. When the context is
+// done before that, the process group of the command is killed, including the
+// children of VBoxManage, and the context error is returned. An unprivileged
+// caller can not signal the processes of a sudo wrapper running as root: the
+// kill fails with EPERM, which is only logged, and run waits until the
+// privileged command exits on its own.
+func (vbcmd command) run(ctx context.Context, args ...string) (string, string, error) {
 	defer vbcmd.setOpts(sudo(false))
+	if err := ctx.Err(); err != nil {
+		return "", "", fmt.Errorf("not running %s: %w", vbcmd.program, err)
+	}
 	cmd := vbcmd.prepare(args)
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
-		if ee, ok := err.(*exec.Error); ok && ee == exec.ErrNotFound {
-			err = ErrCommandNotFound
-		}
+		return "", "", err
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return "", "", err
+	}
+	if err := cmd.Start(); err != nil {
+		err = startError(cmd, err)
+		return "", "", err
+	}
+
+	k := killOnDone(ctx, cmd)
+	// The output is read before the command is reaped, so the children of
+	// VBoxManage which keep it open are still killed with the group.
+	var stdout, stderr bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(&stdout, stdoutPipe)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(&stderr, stderrPipe)
+	}()
+	wg.Wait()
+	err = k.wait()
+
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%s killed: %w", vbcmd.program, ctx.Err())
 	}
 	return stdout.String(), stderr.String(), err
 }
+
+// groupKiller kills the process group of a started command when the context
+// is done, until the command is reaped: the group ID can be reused afterwards.
+type groupKiller struct {
+	cmd    *exec.Cmd
+	mu     sync.Mutex
+	reaped bool
+	done   chan struct{}
+}
+
+// killOnDone kills the process group of the started command when the context
+// is done before the command is reaped by the wait of the returned killer.
+func killOnDone(ctx context.Context, cmd *exec.Cmd) *groupKiller {
+	k := &groupKiller{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			if k.reaped {
+				return
+			}
+			if err := killProcessGroup(cmd); err != nil {
+				Debug("unable to kill %v: %v", cmd.Args, err)
+			}
+		case <-k.done:
+		}
+	}()
+	return k
+}
+
+// wait waits for the command, once all its output was read. No kill can
+// happen after the command is reaped, waitExited holds the group ID until then.
+func (k *groupKiller) wait() error {
+	waitExited(k.cmd.Process.Pid)
+	k.mu.Lock()
+	k.reaped = true
+	k.mu.Unlock()
+	close(k.done)
+	return k.cmd.Wait()
+}
+
+// startError returns ErrCommandNotFound when the command failed to start
+// because its program does not exist. Other errors are returned unchanged.
+// A missing working directory is reported by the child process like a missing
+// program, when it runs in its own process group, so it is checked first.
+func startError(cmd *exec.Cmd, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrCommandNotFound
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != cmd.Path || !errors.Is(pathErr.Err, fs.ErrNotExist) {
+		return err
+	}
+	if cmd.Dir != "" {
+		if _, statErr := os.Stat(cmd.Dir); statErr != nil {
+			return &fs.PathError{Op: "chdir", Path: cmd.Dir, Err: errors.Unwrap(statErr)}
+		}
+	}
+	return ErrCommandNotFound
+}
+
+// process is a command started with its output streamed, see command.start.
+type process struct {
+	stdout io.Reader
+	stderr io.Reader
+	// wait waits for the command to exit, it must be called once all the
+	// output was read.
+	wait func() error
+}
+
+// start starts the command with its stdout and stderr streamed through pipes.
+// When the context is done before the command exits, its whole process group
+// is killed like by run.
+func (vbcmd command) start(ctx context.Context, args ...string) (*process, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("not running %s: %w", vbcmd.program, err)
+	}
+	cmd := vbcmd.prepare(args)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		err = startError(cmd, err)
+		return nil, err
+	}
+
+	k := killOnDone(ctx, cmd)
+	wait := func() error {
+		err := k.wait()
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("%s killed: %w", vbcmd.program, ctx.Err())
+		}
+		return err
+	}
+	return &process{stdout: stdout, stderr: stderr, wait: wait}, nil
+}
@@ -5,6 +5,7 @@
 package virtualbox
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -62,9 +63,9 @@ func (mr *MockCommandMockRecorder) path() *gomock.Call {
 }
 
 // run mocks base method.
-func (m *MockCommand) run(args ...string) (string, string, error) {
+func (m *MockCommand) run(ctx context.Context, args ...string) (string, string, error) {
 	m.ctrl.T.Helper()
-	varargs := []interface{}{}
+	varargs := []interface{}{ctx}
 	for _, a := range args {
 		varargs = append(varargs, a)
 	}
@@ -76,9 +77,10 @@ func (m *MockCommand) run(args ...string) (string, string, error) {
 }
 
 // run indicates an expected call of run.
-func (mr *MockCommandMockRecorder) run(args ...interface{}) *gomock.Call {
+func (mr *MockCommandMockRecorder) run(ctx interface{}, args ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "run", reflect.TypeOf((*MockCommand)(nil).run), args...)
+	varargs := append([]interface{}{ctx}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "run", reflect.TypeOf((*MockCommand)(nil).run), varargs...)
 }
 
 // setOpts mocks base method.
@@ -15,8 +15,6 @@ var (
 
 var (
 	reVMNameUUID      = regexp.MustCompile(`"(.+)" {([0-9a-f-]+)}`)
-	reVMInfoLine      = regexp.MustCompile(`(?:"(.+)"|(.+))=(?:"(.*)"|(.*))`)
-	reColonLine       = regexp.MustCompile(`(.+):\s+(.*)`)
 	reMachineNotFound = regexp.MustCompile(`Could not find a registered machine named '(.+)'`)
 )
 
@@ -46,7 +44,9 @@ func Manage() Command {
 func lookupVBoxProgram(vbprog string) (string, error) {
 
 	if runtime.GOOS == osWindows {
-		if p := os.Getenv("VBOX_INSTALL_PATH"); p != "" {
+		if p := os.Getenv("VBOX_MSI_INSTALL_PATH"); p != "" {
+			vbprog = filepath.Join(p, vbprog+".exe")
+		} else if p := os.Getenv("VBOX_INSTALL_PATH"); p != "" {
 			vbprog = filepath.Join(p, vbprog+".exe")
 		} else {
 			vbprog = filepath.Join("C:\\", "Program Files", "Oracle", "VirtualBox", vbprog+".exe")
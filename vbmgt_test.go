@@ -1,6 +1,7 @@
 package virtualbox
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path"
@@ -47,9 +48,9 @@ func TestVBMOut(t *testing.T) {
 	if ManageMock != nil {
 		var out = "\"Ubuntu\" {2e16b1fc-aaaa-4a7a-a9a1-e89a8bde7874}\n" +
 			"\"go-virtualbox\" {def44546-aaaa-4902-8d15-b91c99c80cbc}"
-		ManageMock.EXPECT().run("list", "vms").Return(out, "", nil)
+		ManageMock.EXPECT().run(gomock.Any(), "list", "vms").Return(out, "", nil)
 	}
-	b, _, err := Manage().run("list", "vms")
+	b, _, err := Manage().run(context.Background(), "list", "vms")
 	if err != nil {
 		t.Fatal(err)
 	}
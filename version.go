@@ -0,0 +1,200 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reVersion = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)(?:_(.+?))?(?:r(\d+))?$`)
+)
+
+var (
+	// ErrUnsupported is returned when the operation is not supported by the
+	// installed version of VirtualBox.
+	ErrUnsupported = errors.New("unsupported by this VirtualBox version")
+)
+
+// Version of VirtualBox as reported by VBoxManage --version, e.g.
+// "7.0.10r158379" or "6.1.38_Ubuntur153438".
+type Version struct {
+	Major    int
+	Minor    int
+	Patch    int
+	Edition  string // e.g. Ubuntu, BETA1 or empty for the Oracle builds
+	Revision int
+}
+
+// ParseVersion parses the output of VBoxManage --version. Any warnings
+// printed before the version, e.g. about missing kernel modules, are skipped.
+func ParseVersion(s string) (Version, error) {
+	var line string
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+		}
+	}
+	res := reVersion.FindStringSubmatch(line)
+	if res == nil {
+		return Version{}, fmt.Errorf("unable to parse version %q", line)
+	}
+	v := Version{Edition: res[4]}
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch, &v.Revision} {
+		s := res[i+1]
+		if i == 3 {
+			s = res[5]
+		}
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return Version{}, fmt.Errorf("unable to parse version %q: %w", line, err)
+		}
+		*p = n
+	}
+	return v, nil
+}
+
+// String returns the version in the format used by VBoxManage.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Edition != "" {
+		s += "_" + v.Edition
+	}
+	if v.Revision != 0 {
+		s += fmt.Sprintf("r%d", v.Revision)
+	}
+	return s
+}
+
+// AtLeast reports whether the version is the same as or newer than the
+// given major, minor and patch version. The edition and revision are ignored.
+func (v Version) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+// Supports reports whether the version provides the given capability.
+func (v Version) Supports(c Capability) bool {
+	first, ok := capabilities[c]
+	return ok && v.AtLeast(first.Major, first.Minor, first.Patch)
+}
+
+// Capability is a feature of VBoxManage which is not available in all the
+// versions of VirtualBox.
+type Capability int
+
+const (
+	// CapHostonlyNet is the 'hostonlynet' command family.
+	CapHostonlyNet Capability = iota
+	// CapEncryptVM is the 'encryptvm' command.
+	CapEncryptVM
+	// CapHyphenatedFlags is the hyphenated spelling of the modifyvm and
+	// controlvm flags, e.g. --host-only-adapter instead of --hostonlyadapter.
+	CapHyphenatedFlags
+	// CapDHCPFindLease is the 'dhcpserver findlease' command.
+	CapDHCPFindLease
+	// CapNATNetIPv6Prefix is the --ipv6-prefix flag of 'natnetwork'.
+	CapNATNetIPv6Prefix
+	// CapNATNetIPv6Default is the --ipv6-default flag of 'natnetwork'.
+	CapNATNetIPv6Default
+)
+
+// capabilities holds the first version of VirtualBox providing the capability.
+var capabilities = map[Capability]Version{
+	CapHostonlyNet:       {Major: 7},
+	CapEncryptVM:         {Major: 7},
+	CapHyphenatedFlags:   {Major: 7},
+	CapDHCPFindLease:     {Major: 6, Minor: 1},
+	CapNATNetIPv6Prefix:  {Major: 7},
+	CapNATNetIPv6Default: {Major: 7},
+}
+
+var capabilityNames = map[Capability]string{
+	CapHostonlyNet:       "hostonlynet",
+	CapEncryptVM:         "encryptvm",
+	CapHyphenatedFlags:   "hyphenated flags",
+	CapDHCPFindLease:     "dhcpserver findlease",
+	CapNATNetIPv6Prefix:  "natnetwork --ipv6-prefix",
+	CapNATNetIPv6Default: "natnetwork --ipv6-default",
+}
+
+// String returns the name of the capability.
+func (c Capability) String() string {
+	if name, ok := capabilityNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Capability(%d)", int(c))
+}
+
+// Version returns the version of VirtualBox used by the manager. The version
+// is detected only once and reused afterwards.
+func (m *Manager) Version(ctx context.Context) (Version, error) {
+	m.versionLock.Lock()
+	defer m.versionLock.Unlock()
+	if m.version != nil {
+		return *m.version, nil
+	}
+
+	stdout, stderr, err := m.run(ctx, "--version")
+	if err != nil {
+		return Version{}, fmt.Errorf("unable to get version: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	v, err := ParseVersion(stdout)
+	if err != nil {
+		return Version{}, err
+	}
+	m.log.Printf("detected VirtualBox %s", v)
+	m.version = &v
+	return v, nil
+}
+
+// require returns ErrUnsupported when the VirtualBox used by the manager
+// does not provide the capability.
+func (m *Manager) require(ctx context.Context, c Capability) error {
+	v, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if !v.Supports(c) {
+		first := capabilities[c]
+		return fmt.Errorf("%s requires VirtualBox %d.%d or newer, found %s: %w",
+			c, first.Major, first.Minor, v, ErrUnsupported)
+	}
+	return nil
+}
+
+// hyphenatedFlags maps the legacy spelling of the modifyvm and controlvm flags
+// to the one used since VirtualBox 7.0. The legacy spelling is still
+// accepted, but deprecated.
+var hyphenatedFlags = map[string]string{
+	"nictype":           "nic-type",
+	"cableconnected":    "cable-connected",
+	"hostonlyadapter":   "host-only-adapter",
+	"bridgeadapter":     "bridge-adapter",
+	"nicpromisc":        "nic-promisc",
+	"nicproperty":       "nic-property",
+	"nictrace":          "nic-trace",
+	"nictracefile":      "nic-trace-file",
+	"natpf":             "nat-pf",
+	"nicbandwidthgroup": "nic-bandwidth-group",
+}
+
+// flag returns the flag with the given legacy name for the n-th device, in
+// the spelling understood by the version.
+func (v Version) flag(name string, n int) string {
+	if h, ok := hyphenatedFlags[name]; ok && v.Supports(CapHyphenatedFlags) {
+		name = h
+	}
+	return fmt.Sprintf("--%s%d", name, n)
+}
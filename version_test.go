@@ -0,0 +1,119 @@
+package virtualbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := map[string]struct {
+		in   string
+		want Version
+		err  bool
+	}{
+		"oracle": {
+			in:   "7.0.10r158379\n",
+			want: Version{Major: 7, Minor: 0, Patch: 10, Revision: 158379},
+		},
+		"distribution": {
+			in:   "6.1.38_Ubuntur153438\n",
+			want: Version{Major: 6, Minor: 1, Patch: 38, Edition: "Ubuntu", Revision: 153438},
+		},
+		"edition with r": {
+			in:   "6.1.40_Fedorar154048",
+			want: Version{Major: 6, Minor: 1, Patch: 40, Edition: "Fedora", Revision: 154048},
+		},
+		"no revision": {
+			in:   "5.2.44",
+			want: Version{Major: 5, Minor: 2, Patch: 44},
+		},
+		"warnings": {
+			in: "WARNING: The vboxdrv kernel module is not loaded.\n" +
+				"         Please run /sbin/vboxconfig.\n\n" +
+				"7.0.6_BETA1r155176\n",
+			want: Version{Major: 7, Minor: 0, Patch: 6, Edition: "BETA1", Revision: 155176},
+		},
+		"garbage": {
+			in:  "VBoxManage: error: unknown option",
+			err: true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseVersion(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("ParseVersion(%q) error = %v; want error %v", tc.in, err, tc.err)
+			}
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Errorf("ParseVersion(%q) = %+v; want %+v; diff = %v", tc.in, got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, s := range []string{"7.0.10r158379", "6.1.38_Ubuntur153438", "5.2.44"} {
+		v, err := ParseVersion(s)
+		if err != nil || v.String() != s {
+			t.Errorf("ParseVersion(%q).String() = %q, %v; want %q, nil", s, v, err, s)
+		}
+	}
+}
+
+func TestVersionSupports(t *testing.T) {
+	testCases := []struct {
+		v    Version
+		c    Capability
+		want bool
+	}{
+		{Version{Major: 6, Minor: 1, Patch: 38}, CapHostonlyNet, false},
+		{Version{Major: 7}, CapHostonlyNet, true},
+		{Version{Major: 6, Minor: 0, Patch: 24}, CapDHCPFindLease, false},
+		{Version{Major: 6, Minor: 1}, CapDHCPFindLease, true},
+		{Version{Major: 7, Minor: 1}, Capability(-1), false},
+	}
+	for _, tc := range testCases {
+		if got := tc.v.Supports(tc.c); got != tc.want {
+			t.Errorf("%s.Supports(%s) = %v; want %v", tc.v, tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestManagerVersion(t *testing.T) {
+	calls := 0
+	m := NewManager()
+	m.runner = func(_ context.Context, args ...string) (string, string, error) {
+		calls++
+		return "6.1.38r153438\n", "", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := m.Version(context.Background())
+		if err != nil || v.String() != "6.1.38r153438" {
+			t.Errorf("Version() = %s, %v; want 6.1.38r153438, nil", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("VBoxManage --version was called %d times; want 1", calls)
+	}
+
+	if err := m.require(context.Background(), CapHostonlyNet); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("require(%s) = %v; want %v", CapHostonlyNet, err, ErrUnsupported)
+	}
+	if err := m.require(context.Background(), CapDHCPFindLease); err != nil {
+		t.Errorf("require(%s) = %v; want nil", CapDHCPFindLease, err)
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	if got := (Version{Major: 6, Minor: 1}).flag("hostonlyadapter", 2); got != "--hostonlyadapter2" {
+		t.Errorf("flag() = %s; want --hostonlyadapter2", got)
+	}
+	if got := (Version{Major: 7}).flag("hostonlyadapter", 2); got != "--host-only-adapter2" {
+		t.Errorf("flag() = %s; want --host-only-adapter2", got)
+	}
+}
@@ -0,0 +1,260 @@
+package virtualboxtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+func copyDHCP(d *virtualbox.DHCP) *virtualbox.DHCP {
+	c := *d
+	c.Options = copyDHCPOptions(d.Options)
+	c.Groups = make([]virtualbox.DHCPGroupConfig, len(d.Groups))
+	for i, g := range d.Groups {
+		g.MACs = append([]string(nil), g.MACs...)
+		g.DHCPOptions = copyDHCPOptions(g.DHCPOptions)
+		c.Groups[i] = g
+	}
+	c.Machines = make([]virtualbox.DHCPMachineConfig, len(d.Machines))
+	for i, m := range d.Machines {
+		m.DHCPOptions = copyDHCPOptions(m.DHCPOptions)
+		c.Machines[i] = m
+	}
+	return &c
+}
+
+func copyDHCPOptions(o virtualbox.DHCPOptions) virtualbox.DHCPOptions {
+	o.DNSServers = append([]net.IP(nil), o.DNSServers...)
+	o.Routers = append([]net.IP(nil), o.Routers...)
+	if o.Other != nil {
+		other := make(map[int]string, len(o.Other))
+		for k, v := range o.Other {
+			other[k] = v
+		}
+		o.Other = other
+	}
+	return o
+}
+
+// mergeDHCPOptions sets the options of o which are set in n.
+func mergeDHCPOptions(o *virtualbox.DHCPOptions, n virtualbox.DHCPOptions) {
+	n = copyDHCPOptions(n)
+	if len(n.DNSServers) > 0 {
+		o.DNSServers = n.DNSServers
+	}
+	if len(n.Routers) > 0 {
+		o.Routers = n.Routers
+	}
+	if n.DomainName != "" {
+		o.DomainName = n.DomainName
+	}
+	if n.LeaseTime > 0 {
+		o.LeaseTime = n.LeaseTime
+	}
+	for k, v := range n.Other {
+		if o.Other == nil {
+			o.Other = map[int]string{}
+		}
+		o.Other[k] = v
+	}
+}
+
+// AddDHCP adds the DHCP server to the network.
+func (f *Fake) AddDHCP(_ context.Context, d virtualbox.DHCP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("AddDHCP", d.NetworkName); err != nil {
+		return err
+	}
+	if _, ok := f.dhcps[d.NetworkName]; ok {
+		return fmt.Errorf("DHCP server of %q: %w", d.NetworkName, virtualbox.ErrDHCPExist)
+	}
+	f.dhcps[d.NetworkName] = copyDHCP(&d)
+	return nil
+}
+
+// ModifyDHCP changes the DHCP server like VBoxManage does: the addresses and
+// options which are not set are left unchanged, and the groups and machines
+// are added or merged with the existing ones. The enabled state is left
+// unchanged.
+func (f *Fake) ModifyDHCP(_ context.Context, d virtualbox.DHCP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("ModifyDHCP", d.NetworkName); err != nil {
+		return err
+	}
+	cur, ok := f.dhcps[d.NetworkName]
+	if !ok {
+		return fmt.Errorf("DHCP server of %q: %w", d.NetworkName, virtualbox.ErrDHCPNotExist)
+	}
+	n := copyDHCP(&d)
+	if n.IPv4.IP != nil {
+		cur.IPv4.IP = n.IPv4.IP
+	}
+	if n.IPv4.Mask != nil {
+		cur.IPv4.Mask = n.IPv4.Mask
+	}
+	if n.LowerIP != nil {
+		cur.LowerIP = n.LowerIP
+	}
+	if n.UpperIP != nil {
+		cur.UpperIP = n.UpperIP
+	}
+	mergeDHCPOptions(&cur.Options, n.Options)
+groups:
+	for _, g := range n.Groups {
+		for i := range cur.Groups {
+			if cur.Groups[i].Name == g.Name {
+				for _, mac := range g.MACs {
+					if !containsFold(cur.Groups[i].MACs, mac) {
+						cur.Groups[i].MACs = append(cur.Groups[i].MACs, mac)
+					}
+				}
+				mergeDHCPOptions(&cur.Groups[i].DHCPOptions, g.DHCPOptions)
+				continue groups
+			}
+		}
+		cur.Groups = append(cur.Groups, g)
+	}
+machines:
+	for _, m := range n.Machines {
+		for i := range cur.Machines {
+			c := &cur.Machines[i]
+			if strings.EqualFold(c.MAC, m.MAC) && c.VM == m.VM && c.NIC == m.NIC {
+				if m.FixedAddress != nil {
+					c.FixedAddress = m.FixedAddress
+				}
+				mergeDHCPOptions(&c.DHCPOptions, m.DHCPOptions)
+				continue machines
+			}
+		}
+		cur.Machines = append(cur.Machines, m)
+	}
+	return nil
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnableDHCP enables the DHCP server of the network.
+func (f *Fake) EnableDHCP(_ context.Context, network string) error {
+	return f.setDHCPEnabled("EnableDHCP", network, true)
+}
+
+// DisableDHCP disables the DHCP server of the network.
+func (f *Fake) DisableDHCP(_ context.Context, network string) error {
+	return f.setDHCPEnabled("DisableDHCP", network, false)
+}
+
+func (f *Fake) setDHCPEnabled(op, network string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault(op, network); err != nil {
+		return err
+	}
+	d, ok := f.dhcps[network]
+	if !ok {
+		return fmt.Errorf("DHCP server of %q: %w", network, virtualbox.ErrDHCPNotExist)
+	}
+	d.Enabled = enabled
+	return nil
+}
+
+// DHCPs returns the DHCP servers keyed by their network name.
+func (f *Fake) DHCPs(_ context.Context) (map[string]*virtualbox.DHCP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("DHCPs", ""); err != nil {
+		return nil, err
+	}
+	m := make(map[string]*virtualbox.DHCP, len(f.dhcps))
+	for name, d := range f.dhcps {
+		m[name] = copyDHCP(d)
+	}
+	return m, nil
+}
+
+// RemoveDHCP removes the DHCP server and its leases.
+func (f *Fake) RemoveDHCP(_ context.Context, network string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("RemoveDHCP", network); err != nil {
+		return err
+	}
+	if _, ok := f.dhcps[network]; !ok {
+		return fmt.Errorf("DHCP server of %q: %w", network, virtualbox.ErrDHCPNotExist)
+	}
+	delete(f.dhcps, network)
+	delete(f.leases, network)
+	return nil
+}
+
+// StartDHCP checks only that the DHCP server exists.
+func (f *Fake) StartDHCP(_ context.Context, network string) error {
+	return f.checkDHCP("StartDHCP", network)
+}
+
+// StopDHCP checks only that the DHCP server exists.
+func (f *Fake) StopDHCP(_ context.Context, network string) error {
+	return f.checkDHCP("StopDHCP", network)
+}
+
+// RestartDHCP checks only that the DHCP server exists.
+func (f *Fake) RestartDHCP(_ context.Context, network string) error {
+	return f.checkDHCP("RestartDHCP", network)
+}
+
+func (f *Fake) checkDHCP(op, network string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault(op, network); err != nil {
+		return err
+	}
+	if _, ok := f.dhcps[network]; !ok {
+		return fmt.Errorf("DHCP server of %q: %w", network, virtualbox.ErrDHCPNotExist)
+	}
+	return nil
+}
+
+// SetLease makes the DHCP server of the network return the lease for its MAC
+// address, as if the guest had requested it.
+func (f *Fake) SetLease(network string, lease virtualbox.DHCPLease) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.leases[network] == nil {
+		f.leases[network] = make(map[string]virtualbox.DHCPLease)
+	}
+	f.leases[network][normalizeMAC(lease.MAC)] = lease
+}
+
+// FindLease returns the lease set with SetLease.
+func (f *Fake) FindLease(_ context.Context, network, mac string) (*virtualbox.DHCPLease, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("FindLease", network); err != nil {
+		return nil, err
+	}
+	if _, ok := f.dhcps[network]; !ok {
+		return nil, fmt.Errorf("DHCP server of %q: %w", network, virtualbox.ErrDHCPNotExist)
+	}
+	lease, ok := f.leases[network][normalizeMAC(mac)]
+	if !ok {
+		return nil, fmt.Errorf("lease of %s: %w", mac, virtualbox.ErrLeaseNotFound)
+	}
+	return &lease, nil
+}
+
+// normalizeMAC returns the MAC address in upper case without separators, the
+// form used by NIC.MacAddr.
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+}
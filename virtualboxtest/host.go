@@ -0,0 +1,115 @@
+package virtualboxtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+func copyHostInterface(hi *virtualbox.HostInterface) *virtualbox.HostInterface {
+	c := *hi
+	c.HwAddr = append(net.HardwareAddr(nil), hi.HwAddr...)
+	return &c
+}
+
+// AddBridgedInterface adds the network interface to the host, the Fake has
+// none by default.
+func (f *Fake) AddBridgedInterface(hi *virtualbox.HostInterface) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bridged[hi.Name] = copyHostInterface(hi)
+}
+
+// BridgedInterfaces returns the network interfaces added to the host.
+func (f *Fake) BridgedInterfaces(_ context.Context) (map[string]*virtualbox.HostInterface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("BridgedInterfaces", ""); err != nil {
+		return nil, err
+	}
+	m := make(map[string]*virtualbox.HostInterface, len(f.bridged))
+	for name, hi := range f.bridged {
+		m[name] = copyHostInterface(hi)
+	}
+	return m, nil
+}
+
+// BridgedInterface returns the network interface of the host with the name.
+func (f *Fake) BridgedInterface(_ context.Context, name string) (*virtualbox.HostInterface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("BridgedInterface", name); err != nil {
+		return nil, err
+	}
+	hi, ok := f.bridged[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, virtualbox.ErrHostInterfaceNotExist)
+	}
+	return copyHostInterface(hi), nil
+}
+
+// DefaultBridgedInterface returns the first interface by name which is up.
+func (f *Fake) DefaultBridgedInterface(_ context.Context) (*virtualbox.HostInterface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("DefaultBridgedInterface", ""); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(f.bridged))
+	for name := range f.bridged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if hi := f.bridged[name]; hi.Status == "Up" {
+			return copyHostInterface(hi), nil
+		}
+	}
+	return nil, fmt.Errorf("no host interface is up: %w", virtualbox.ErrHostInterfaceNotExist)
+}
+
+// IntNets returns the internal networks the NICs of the machines are
+// attached to, NIC.HostInterface names the network.
+func (f *Fake) IntNets(_ context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("IntNets", ""); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range f.machines {
+		for _, nic := range m.vm.NICs {
+			if nic.Network == virtualbox.NICNetInternal && nic.HostInterface != "" && !seen[nic.HostInterface] {
+				seen[nic.HostInterface] = true
+				names = append(names, nic.HostInterface)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HostInfo describes the host running the test.
+func (f *Fake) HostInfo(_ context.Context) (*virtualbox.HostInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("HostInfo", ""); err != nil {
+		return nil, err
+	}
+	return &virtualbox.HostInfo{
+		OS:               runtime.GOOS,
+		CPUs:             runtime.NumCPU(),
+		OnlineCPUs:       runtime.NumCPU(),
+		Cores:            runtime.NumCPU(),
+		HWVirtualization: true,
+		NestedPaging:     true,
+		LongMode:         true,
+		Memory:           16384,
+		MemoryAvailable:  8192,
+	}, nil
+}
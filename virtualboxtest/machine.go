@@ -0,0 +1,436 @@
+package virtualboxtest
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+type machine struct {
+	vm        virtualbox.Machine
+	created   int
+	storage   map[string]*storageCtl
+	snapshots []Snapshot
+	props     map[string]string
+}
+
+type storageCtl struct {
+	ctl   virtualbox.StorageController
+	media []virtualbox.StorageMedium
+}
+
+// Snapshot of a machine.
+type Snapshot struct {
+	Name        string
+	UUID        string
+	Description string
+
+	vm virtualbox.Machine
+}
+
+// copyMachine returns a deep copy of the machine.
+func copyMachine(vm virtualbox.Machine) *virtualbox.Machine {
+	vm.BootOrder = append(make([]string, 0, len(vm.BootOrder)), vm.BootOrder...)
+	vm.NICs = append(make([]virtualbox.NIC, 0, len(vm.NICs)), vm.NICs...)
+	for i := range vm.NICs {
+		vm.NICs[i].Forwarding = append([]virtualbox.PFRule(nil), vm.NICs[i].Forwarding...)
+	}
+	return &vm
+}
+
+// machine finds the machine by its name or UUID, it must be called with the
+// lock held.
+func (f *Fake) machine(id string) (*machine, error) {
+	if m, ok := f.machines[id]; ok {
+		return m, nil
+	}
+	for _, m := range f.machines {
+		if m.vm.Name == id {
+			return m, nil
+		}
+	}
+	return nil, virtualbox.ErrMachineNotExist
+}
+
+// lookup finds the machine after checking the injected faults, and checks that
+// it is in one of the given states. It must be called with the lock held.
+func (f *Fake) lookup(op, id string, states ...virtualbox.MachineState) (*machine, error) {
+	if err := f.fault(op, id); err != nil {
+		return nil, err
+	}
+	m, err := f.machine(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return m, nil
+	}
+	for _, s := range states {
+		if m.vm.State == s {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: machine %q is %s: %w", op, m.vm.Name, m.vm.State, ErrInvalidState)
+}
+
+// mutable are the states in which the settings of a machine can be changed.
+var mutable = []virtualbox.MachineState{virtualbox.Poweroff, virtualbox.Aborted}
+
+// Machine returns the machine identified by its name or UUID.
+func (f *Fake) Machine(_ context.Context, id string) (*virtualbox.Machine, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("Machine", id)
+	if err != nil {
+		return nil, err
+	}
+	return copyMachine(m.vm), nil
+}
+
+// ListMachines returns all the machines in the order they were created.
+func (f *Fake) ListMachines(_ context.Context) ([]*virtualbox.Machine, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("ListMachines", ""); err != nil {
+		return nil, err
+	}
+	ms := make([]*machine, 0, len(f.machines))
+	for _, m := range f.machines {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].created < ms[j].created })
+	vms := make([]*virtualbox.Machine, 0, len(ms))
+	for _, m := range ms {
+		vms = append(vms, copyMachine(m.vm))
+	}
+	return vms, nil
+}
+
+// CreateMachine creates a powered off machine with the name, base folder, OS
+// type and UUID of the given one, and updates it with the created machine.
+func (f *Fake) CreateMachine(_ context.Context, vm *virtualbox.Machine) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("CreateMachine", vm.Name); err != nil {
+		return err
+	}
+	if vm.Name == "" {
+		return fmt.Errorf("machine name is empty")
+	}
+	if _, err := f.machine(vm.Name); err == nil {
+		return virtualbox.ErrMachineExist
+	}
+	uuid := vm.UUID
+	if uuid == "" {
+		uuid = f.uuid()
+	} else if _, err := f.machine(uuid); err == nil {
+		return virtualbox.ErrMachineExist
+	}
+	folder := vm.BaseFolder
+	if folder == "" {
+		folder = "/VirtualBox VMs"
+	}
+	cfg := path.Join(folder, vm.Name, vm.Name+".vbox")
+
+	m := &machine{
+		vm: virtualbox.Machine{
+			Name:       vm.Name,
+			Firmware:   "BIOS",
+			UUID:       uuid,
+			State:      virtualbox.Poweroff,
+			CPUs:       1,
+			Memory:     128,
+			VRAM:       8,
+			CfgFile:    cfg,
+			BaseFolder: path.Dir(cfg),
+			OSType:     vm.OSType,
+			BootOrder:  []string{},
+			NICs:       []virtualbox.NIC{},
+		},
+		created: f.next(),
+		storage: make(map[string]*storageCtl),
+		props:   make(map[string]string),
+	}
+	f.machines[uuid] = m
+	*vm = *copyMachine(m.vm)
+	return nil
+}
+
+// ModifyMachine changes the settings of the machine, which must not be
+// running, and updates the given machine with the result. The NICs without
+// a MAC address are assigned a generated one.
+func (f *Fake) ModifyMachine(_ context.Context, vm *virtualbox.Machine) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("ModifyMachine", vm.Name, mutable...)
+	if err != nil {
+		return err
+	}
+	m.vm.Firmware = vm.Firmware
+	m.vm.OSType = vm.OSType
+	m.vm.CPUs = vm.CPUs
+	m.vm.Memory = vm.Memory
+	m.vm.VRAM = vm.VRAM
+	m.vm.Flag = vm.Flag
+	m.vm.BootOrder = append([]string{}, vm.BootOrder...)
+	if len(m.vm.BootOrder) > 4 {
+		m.vm.BootOrder = m.vm.BootOrder[:4]
+	}
+	m.vm.NICs = append([]virtualbox.NIC{}, vm.NICs...)
+	for i := range m.vm.NICs {
+		if m.vm.NICs[i].MacAddr == "" {
+			m.vm.NICs[i].MacAddr = f.mac()
+		}
+	}
+	*vm = *copyMachine(m.vm)
+	return nil
+}
+
+// StartMachine starts a powered off, saved or aborted machine, and resumes a
+// paused one.
+func (f *Fake) StartMachine(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("StartMachine", id,
+		virtualbox.Poweroff, virtualbox.Saved, virtualbox.Aborted, virtualbox.Paused)
+	if err != nil {
+		return err
+	}
+	m.vm.State = virtualbox.Running
+	return nil
+}
+
+// PauseMachine pauses a running machine.
+func (f *Fake) PauseMachine(_ context.Context, id string) error {
+	return f.transition("PauseMachine", id, virtualbox.Paused, virtualbox.Running)
+}
+
+// SaveMachine saves the state of a running or paused machine.
+func (f *Fake) SaveMachine(_ context.Context, id string) error {
+	return f.transition("SaveMachine", id, virtualbox.Saved, virtualbox.Running, virtualbox.Paused)
+}
+
+// StopMachine powers off a running or paused machine.
+func (f *Fake) StopMachine(_ context.Context, id string) error {
+	return f.transition("StopMachine", id, virtualbox.Poweroff, virtualbox.Running, virtualbox.Paused)
+}
+
+// AbortMachine simulates the crash of a running or paused machine.
+func (f *Fake) AbortMachine(_ context.Context, id string) error {
+	return f.transition("AbortMachine", id, virtualbox.Aborted, virtualbox.Running, virtualbox.Paused)
+}
+
+func (f *Fake) transition(op, id string, to virtualbox.MachineState, from ...virtualbox.MachineState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup(op, id, from...)
+	if err != nil {
+		return err
+	}
+	m.vm.State = to
+	return nil
+}
+
+// DeleteMachine deletes the machine, which must not be running.
+func (f *Fake) DeleteMachine(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("DeleteMachine", id, virtualbox.Poweroff, virtualbox.Saved, virtualbox.Aborted)
+	if err != nil {
+		return err
+	}
+	delete(f.machines, m.vm.UUID)
+	return nil
+}
+
+// AddStorageCtl adds a storage controller to the machine, which must not be
+// running.
+func (f *Fake) AddStorageCtl(_ context.Context, id, name string, ctl virtualbox.StorageController) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("AddStorageCtl", id, mutable...)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.storage[name]; ok {
+		return fmt.Errorf("storage controller %q: %w", name, ErrExist)
+	}
+	m.storage[name] = &storageCtl{ctl: ctl}
+	return nil
+}
+
+// DelStorageCtl removes the storage controller, together with the attached
+// media, from the machine, which must not be running.
+func (f *Fake) DelStorageCtl(_ context.Context, id, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("DelStorageCtl", id, mutable...)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.storage[name]; !ok {
+		return fmt.Errorf("storage controller %q: %w", name, ErrNotExist)
+	}
+	delete(m.storage, name)
+	return nil
+}
+
+// AttachStorage attaches the medium to the storage controller of the machine,
+// which must not be running. Attaching the "none" medium detaches the one in
+// the same port and device.
+func (f *Fake) AttachStorage(_ context.Context, id, ctlName string, medium virtualbox.StorageMedium) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("AttachStorage", id, mutable...)
+	if err != nil {
+		return err
+	}
+	ctl, ok := m.storage[ctlName]
+	if !ok {
+		return fmt.Errorf("storage controller %q: %w", ctlName, ErrNotExist)
+	}
+	if ctl.ctl.Ports > 0 && medium.Port >= ctl.ctl.Ports {
+		return fmt.Errorf("port %d is out of range of %q with %d ports", medium.Port, ctlName, ctl.ctl.Ports)
+	}
+	media := ctl.media[:0]
+	for _, md := range ctl.media {
+		if md.Port != medium.Port || md.Device != medium.Device {
+			media = append(media, md)
+		}
+	}
+	if medium.Medium != "none" {
+		media = append(media, medium)
+	}
+	ctl.media = media
+	return nil
+}
+
+// StorageMedia returns the media attached to the storage controller of the
+// machine.
+func (f *Fake) StorageMedia(_ context.Context, id, ctlName string) ([]virtualbox.StorageMedium, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("StorageMedia", id)
+	if err != nil {
+		return nil, err
+	}
+	ctl, ok := m.storage[ctlName]
+	if !ok {
+		return nil, fmt.Errorf("storage controller %q: %w", ctlName, ErrNotExist)
+	}
+	return append([]virtualbox.StorageMedium(nil), ctl.media...), nil
+}
+
+// TakeSnapshot takes a snapshot of the settings and the state of the machine.
+func (f *Fake) TakeSnapshot(_ context.Context, id, name, description string) (*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("TakeSnapshot", id)
+	if err != nil {
+		return nil, err
+	}
+	s := Snapshot{
+		Name:        name,
+		UUID:        f.uuid(),
+		Description: description,
+		vm:          *copyMachine(m.vm),
+	}
+	m.snapshots = append(m.snapshots, s)
+	return &s, nil
+}
+
+// Snapshots returns the snapshots of the machine, the oldest one first.
+func (f *Fake) Snapshots(_ context.Context, id string) ([]Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("Snapshots", id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Snapshot(nil), m.snapshots...), nil
+}
+
+// RestoreSnapshot restores the machine, which must not be running, to the
+// snapshot identified by its name or UUID. A snapshot taken while the machine
+// was running is restored to the saved state.
+func (f *Fake) RestoreSnapshot(_ context.Context, id, snapshot string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("RestoreSnapshot", id, virtualbox.Poweroff, virtualbox.Saved, virtualbox.Aborted)
+	if err != nil {
+		return err
+	}
+	for _, s := range m.snapshots {
+		if s.Name != snapshot && s.UUID != snapshot {
+			continue
+		}
+		m.vm = *copyMachine(s.vm)
+		switch m.vm.State {
+		case virtualbox.Running, virtualbox.Paused, virtualbox.Saved:
+			m.vm.State = virtualbox.Saved
+		default:
+			m.vm.State = virtualbox.Poweroff
+		}
+		return nil
+	}
+	return fmt.Errorf("snapshot %q: %w", snapshot, ErrNotExist)
+}
+
+// DeleteSnapshot deletes the snapshot identified by its name or UUID.
+func (f *Fake) DeleteSnapshot(_ context.Context, id, snapshot string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("DeleteSnapshot", id)
+	if err != nil {
+		return err
+	}
+	for i, s := range m.snapshots {
+		if s.Name == snapshot || s.UUID == snapshot {
+			m.snapshots = append(m.snapshots[:i], m.snapshots[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("snapshot %q: %w", snapshot, ErrNotExist)
+}
+
+// SetGuestProperty sets the guest property of the machine.
+func (f *Fake) SetGuestProperty(_ context.Context, id, name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("SetGuestProperty", id)
+	if err != nil {
+		return err
+	}
+	m.props[name] = value
+	return nil
+}
+
+// GuestProperty returns the value of the guest property of the machine.
+func (f *Fake) GuestProperty(_ context.Context, id, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("GuestProperty", id)
+	if err != nil {
+		return "", err
+	}
+	v, ok := m.props[name]
+	if !ok {
+		return "", fmt.Errorf("guest property %q: %w", name, ErrNotExist)
+	}
+	return v, nil
+}
+
+// DeleteGuestProperty deletes the guest property of the machine.
+func (f *Fake) DeleteGuestProperty(_ context.Context, id, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.lookup("DeleteGuestProperty", id)
+	if err != nil {
+		return err
+	}
+	delete(m.props, name)
+	return nil
+}
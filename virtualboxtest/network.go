@@ -0,0 +1,308 @@
+package virtualboxtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+func copyHostonlyNet(n *virtualbox.HostonlyNet) *virtualbox.HostonlyNet {
+	c := *n
+	c.HwAddr = append(net.HardwareAddr(nil), n.HwAddr...)
+	return &c
+}
+
+// CreateHostonlyNet creates a host-only interface named vboxnet<N>, using the
+// lowest free N, and configured with the 192.168.<56+N>.1/24 address.
+func (f *Fake) CreateHostonlyNet(_ context.Context) (*virtualbox.HostonlyNet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("CreateHostonlyNet", ""); err != nil {
+		return nil, err
+	}
+	i := 0
+	for ; ; i++ {
+		if _, ok := f.hostonly[fmt.Sprintf("vboxnet%d", i)]; !ok {
+			break
+		}
+	}
+	name := fmt.Sprintf("vboxnet%d", i)
+	n := &virtualbox.HostonlyNet{
+		Name: name,
+		GUID: fmt.Sprintf("786f6276-656e-4%03x-8000-0a0027%06x", i, i),
+		IPv4: net.IPNet{
+			IP:   net.IPv4(192, 168, byte(56+i), 1),
+			Mask: net.CIDRMask(24, 32),
+		},
+		HwAddr:      net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, byte(i)},
+		Medium:      "Ethernet",
+		Status:      "Down",
+		NetworkName: "HostInterfaceNetworking-" + name,
+	}
+	f.hostonly[name] = n
+	return copyHostonlyNet(n), nil
+}
+
+// ConfigHostonlyNet changes the addresses and DHCP of the host-only interface.
+func (f *Fake) ConfigHostonlyNet(_ context.Context, n *virtualbox.HostonlyNet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("ConfigHostonlyNet", n.Name); err != nil {
+		return err
+	}
+	hn, ok := f.hostonly[n.Name]
+	if !ok {
+		return fmt.Errorf("host-only interface %q: %w", n.Name, virtualbox.ErrHostonlyInterfaceNotExist)
+	}
+	if n.IPv4.IP != nil && n.IPv4.Mask != nil {
+		hn.IPv4 = n.IPv4
+	}
+	if n.IPv6.IP != nil && n.IPv6.Mask != nil {
+		hn.IPv6 = n.IPv6
+	}
+	hn.DHCP = n.DHCP
+	return nil
+}
+
+// HostonlyNets returns the host-only interfaces keyed by their name.
+func (f *Fake) HostonlyNets(_ context.Context) (map[string]*virtualbox.HostonlyNet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("HostonlyNets", ""); err != nil {
+		return nil, err
+	}
+	m := make(map[string]*virtualbox.HostonlyNet, len(f.hostonly))
+	for name, n := range f.hostonly {
+		m[name] = copyHostonlyNet(n)
+	}
+	return m, nil
+}
+
+// HostonlyNet returns the host-only interface with the given name.
+func (f *Fake) HostonlyNet(_ context.Context, name string) (*virtualbox.HostonlyNet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("HostonlyNet", name); err != nil {
+		return nil, err
+	}
+	n, ok := f.hostonly[name]
+	if !ok {
+		return nil, fmt.Errorf("host-only interface %q: %w", name, virtualbox.ErrHostonlyInterfaceNotExist)
+	}
+	return copyHostonlyNet(n), nil
+}
+
+// RemoveHostonlyNet removes the host-only interface.
+func (f *Fake) RemoveHostonlyNet(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("RemoveHostonlyNet", name); err != nil {
+		return err
+	}
+	if _, ok := f.hostonly[name]; !ok {
+		return fmt.Errorf("host-only interface %q: %w", name, virtualbox.ErrHostonlyInterfaceNotExist)
+	}
+	delete(f.hostonly, name)
+	return nil
+}
+
+func copyHostonlyNetwork(n *virtualbox.HostonlyNetwork) *virtualbox.HostonlyNetwork {
+	c := *n
+	c.Netmask = append(net.IPMask(nil), n.Netmask...)
+	c.LowerIP = append(net.IP(nil), n.LowerIP...)
+	c.UpperIP = append(net.IP(nil), n.UpperIP...)
+	return &c
+}
+
+// AddHostonlyNetwork adds the host-only network, and sets its GUID and network
+// name.
+func (f *Fake) AddHostonlyNetwork(_ context.Context, n *virtualbox.HostonlyNetwork) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("AddHostonlyNetwork", n.Name); err != nil {
+		return err
+	}
+	if _, ok := f.hostnets[n.Name]; ok {
+		return fmt.Errorf("host-only network %q: %w", n.Name, virtualbox.ErrHostonlyNetworkExist)
+	}
+	n.GUID = f.uuid()
+	n.NetworkName = "hostonly-" + n.Name
+	f.hostnets[n.Name] = copyHostonlyNetwork(n)
+	return nil
+}
+
+// ModifyHostonlyNetwork changes the address range and state of the host-only
+// network. The unset addresses are left untouched.
+func (f *Fake) ModifyHostonlyNetwork(_ context.Context, n *virtualbox.HostonlyNetwork) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("ModifyHostonlyNetwork", n.Name); err != nil {
+		return err
+	}
+	hn, ok := f.hostnets[n.Name]
+	if !ok {
+		return fmt.Errorf("host-only network %q: %w", n.Name, virtualbox.ErrHostonlyNetworkNotExist)
+	}
+	c := copyHostonlyNetwork(n)
+	if n.Netmask != nil {
+		hn.Netmask = c.Netmask
+	}
+	if n.LowerIP != nil {
+		hn.LowerIP = c.LowerIP
+	}
+	if n.UpperIP != nil {
+		hn.UpperIP = c.UpperIP
+	}
+	hn.Enabled = n.Enabled
+	return nil
+}
+
+// HostonlyNetworks returns the host-only networks keyed by their name.
+func (f *Fake) HostonlyNetworks(_ context.Context) (map[string]*virtualbox.HostonlyNetwork, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("HostonlyNetworks", ""); err != nil {
+		return nil, err
+	}
+	m := make(map[string]*virtualbox.HostonlyNetwork, len(f.hostnets))
+	for name, n := range f.hostnets {
+		m[name] = copyHostonlyNetwork(n)
+	}
+	return m, nil
+}
+
+// HostonlyNetwork returns the host-only network with the given name.
+func (f *Fake) HostonlyNetwork(_ context.Context, name string) (*virtualbox.HostonlyNetwork, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("HostonlyNetwork", name); err != nil {
+		return nil, err
+	}
+	n, ok := f.hostnets[name]
+	if !ok {
+		return nil, fmt.Errorf("host-only network %q: %w", name, virtualbox.ErrHostonlyNetworkNotExist)
+	}
+	return copyHostonlyNetwork(n), nil
+}
+
+// RemoveHostonlyNetwork removes the host-only network.
+func (f *Fake) RemoveHostonlyNetwork(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("RemoveHostonlyNetwork", name); err != nil {
+		return err
+	}
+	if _, ok := f.hostnets[name]; !ok {
+		return fmt.Errorf("host-only network %q: %w", name, virtualbox.ErrHostonlyNetworkNotExist)
+	}
+	delete(f.hostnets, name)
+	return nil
+}
+
+func copyNATNet(n virtualbox.NATNet) virtualbox.NATNet {
+	n.PortForward4 = append([]virtualbox.PFRule(nil), n.PortForward4...)
+	n.PortForward6 = append([]virtualbox.PFRule(nil), n.PortForward6...)
+	n.Loopback4 = append([]virtualbox.NATLoopback(nil), n.Loopback4...)
+	n.Loopback6 = append([]virtualbox.NATLoopback(nil), n.Loopback6...)
+	return n
+}
+
+// AddNATNet adds the NAT network.
+func (f *Fake) AddNATNet(_ context.Context, n virtualbox.NATNet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("AddNATNet", n.Name); err != nil {
+		return err
+	}
+	if _, ok := f.natnets[n.Name]; ok {
+		return fmt.Errorf("NAT network %q: %w", n.Name, virtualbox.ErrNATNetExist)
+	}
+	f.natnets[n.Name] = copyNATNet(n)
+	return nil
+}
+
+// ModifyNATNet replaces the NAT network with n.
+func (f *Fake) ModifyNATNet(_ context.Context, n virtualbox.NATNet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("ModifyNATNet", n.Name); err != nil {
+		return err
+	}
+	if _, ok := f.natnets[n.Name]; !ok {
+		return fmt.Errorf("NAT network %q: %w", n.Name, virtualbox.ErrNATNetNotExist)
+	}
+	f.natnets[n.Name] = copyNATNet(n)
+	return nil
+}
+
+// RemoveNATNet removes the NAT network.
+func (f *Fake) RemoveNATNet(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("RemoveNATNet", name); err != nil {
+		return err
+	}
+	if _, ok := f.natnets[name]; !ok {
+		return fmt.Errorf("NAT network %q: %w", name, virtualbox.ErrNATNetNotExist)
+	}
+	delete(f.natnets, name)
+	return nil
+}
+
+// StartNATNet checks only that the NAT network exists, the Fake does not run
+// any services.
+func (f *Fake) StartNATNet(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("StartNATNet", name); err != nil {
+		return err
+	}
+	if _, ok := f.natnets[name]; !ok {
+		return fmt.Errorf("NAT network %q: %w", name, virtualbox.ErrNATNetNotExist)
+	}
+	return nil
+}
+
+// StopNATNet checks only that the NAT network exists, the Fake does not run
+// any services.
+func (f *Fake) StopNATNet(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("StopNATNet", name); err != nil {
+		return err
+	}
+	if _, ok := f.natnets[name]; !ok {
+		return fmt.Errorf("NAT network %q: %w", name, virtualbox.ErrNATNetNotExist)
+	}
+	return nil
+}
+
+// NATNets returns the NAT networks keyed by their name.
+func (f *Fake) NATNets(_ context.Context) (map[string]virtualbox.NATNet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("NATNets", ""); err != nil {
+		return nil, err
+	}
+	m := make(map[string]virtualbox.NATNet, len(f.natnets))
+	for name, n := range f.natnets {
+		m[name] = copyNATNet(n)
+	}
+	return m, nil
+}
+
+// NATNet returns the NAT network with the given name.
+func (f *Fake) NATNet(_ context.Context, name string) (virtualbox.NATNet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fault("NATNet", name); err != nil {
+		return virtualbox.NATNet{}, err
+	}
+	n, ok := f.natnets[name]
+	if !ok {
+		return virtualbox.NATNet{}, fmt.Errorf("NAT network %q: %w", name, virtualbox.ErrNATNetNotExist)
+	}
+	return copyNATNet(n), nil
+}
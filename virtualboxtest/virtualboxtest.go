@@ -0,0 +1,116 @@
+/*
+Package virtualboxtest provides an in-memory VirtualBox for testing the code
+which uses the virtualbox package, without a real VirtualBox installation.
+
+The Fake implements the same interfaces as *virtualbox.Manager and keeps the
+machines, their NICs, storage, snapshots and guest properties, and the host
+networks in memory. It enforces the state rules of VirtualBox, e.g. a
+running machine can not be modified, and allows to inject faults:
+
+	vb := virtualboxtest.New()
+	vb.FailOnce("StartMachine", errors.New("VERR_VMX_NO_VMX"))
+
+	var m virtualbox.Virtualbox = vb
+*/
+package virtualboxtest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+)
+
+var (
+	// ErrInvalidState is returned when the operation is not allowed in the
+	// current state of the machine.
+	ErrInvalidState = errors.New("invalid machine state")
+	// ErrNotExist is returned when a snapshot, storage controller or guest
+	// property does not exist.
+	ErrNotExist = errors.New("does not exist")
+	// ErrExist is returned when a snapshot or storage controller with the
+	// same name already exists.
+	ErrExist = errors.New("already exists")
+)
+
+var (
+	_ virtualbox.Virtualbox             = (*Fake)(nil)
+	_ virtualbox.HostonlyNetManager     = (*Fake)(nil)
+	_ virtualbox.HostonlyNetworkManager = (*Fake)(nil)
+	_ virtualbox.NATNetManager          = (*Fake)(nil)
+	_ virtualbox.DHCPManager            = (*Fake)(nil)
+	_ virtualbox.HostManager            = (*Fake)(nil)
+)
+
+// Fake is an in-memory VirtualBox. The zero value is not usable, use New
+// instead. It is safe for concurrent use.
+type Fake struct {
+	mu sync.Mutex
+
+	machines map[string]*machine // keyed by UUID
+	bridged  map[string]*virtualbox.HostInterface
+	hostonly map[string]*virtualbox.HostonlyNet
+	hostnets map[string]*virtualbox.HostonlyNetwork
+	natnets  map[string]virtualbox.NATNet
+	dhcps    map[string]*virtualbox.DHCP
+	leases   map[string]map[string]virtualbox.DHCPLease // by network and MAC
+
+	// Fault is called before every operation with the name of the method and
+	// the machine or network name it was called with. When it returns an
+	// error, the operation fails with it without any changes.
+	Fault func(op, id string) error
+
+	faults map[string][]error
+	seq    int
+}
+
+// New returns an empty in-memory VirtualBox.
+func New() *Fake {
+	return &Fake{
+		machines: make(map[string]*machine),
+		bridged:  make(map[string]*virtualbox.HostInterface),
+		hostonly: make(map[string]*virtualbox.HostonlyNet),
+		hostnets: make(map[string]*virtualbox.HostonlyNetwork),
+		natnets:  make(map[string]virtualbox.NATNet),
+		dhcps:    make(map[string]*virtualbox.DHCP),
+		leases:   make(map[string]map[string]virtualbox.DHCPLease),
+		faults:   make(map[string][]error),
+	}
+}
+
+// FailOnce makes the next call of the method with the given name, e.g.
+// "StartMachine", fail with the error. Multiple calls queue the errors.
+func (f *Fake) FailOnce(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[op] = append(f.faults[op], err)
+}
+
+// fault returns the error injected for the operation, it must be called with
+// the lock held.
+func (f *Fake) fault(op, id string) error {
+	if errs := f.faults[op]; len(errs) > 0 {
+		f.faults[op] = errs[1:]
+		return errs[0]
+	}
+	if f.Fault != nil {
+		return f.Fault(op, id)
+	}
+	return nil
+}
+
+// next returns the next value of the sequence used for the generated
+// identifiers, it must be called with the lock held.
+func (f *Fake) next() int {
+	f.seq++
+	return f.seq
+}
+
+func (f *Fake) uuid() string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012x", f.next())
+}
+
+func (f *Fake) mac() string {
+	return fmt.Sprintf("080027%06X", f.next())
+}
@@ -0,0 +1,354 @@
+package virtualboxtest_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	virtualbox "github.com/terra-farm/go-virtualbox"
+	"github.com/terra-farm/go-virtualbox/virtualboxtest"
+)
+
+func TestMachineLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	var vb virtualbox.Virtualbox = f
+
+	vm := &virtualbox.Machine{Name: "test", OSType: "Ubuntu_64"}
+	if err := vb.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if vm.UUID == "" || vm.State != virtualbox.Poweroff {
+		t.Fatalf("CreateMachine() machine = %+v; want UUID and poweroff state", vm)
+	}
+	if err := vb.CreateMachine(ctx, &virtualbox.Machine{Name: "test"}); !errors.Is(err, virtualbox.ErrMachineExist) {
+		t.Errorf("CreateMachine() duplicate = %v; want %v", err, virtualbox.ErrMachineExist)
+	}
+
+	vm.CPUs = 2
+	vm.NICs = []virtualbox.NIC{{Network: virtualbox.NICNetNAT, Hardware: virtualbox.VirtIO}}
+	if err := vb.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	got, err := vb.Machine(ctx, vm.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CPUs != 2 || len(got.NICs) != 1 || got.NICs[0].MacAddr == "" {
+		t.Errorf("Machine() = %+v; want 2 CPUs and a NIC with MAC address", got)
+	}
+
+	if err := vb.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vb.ModifyMachine(ctx, vm); !errors.Is(err, virtualboxtest.ErrInvalidState) {
+		t.Errorf("ModifyMachine() while running = %v; want %v", err, virtualboxtest.ErrInvalidState)
+	}
+	if err := vb.DeleteMachine(ctx, "test"); !errors.Is(err, virtualboxtest.ErrInvalidState) {
+		t.Errorf("DeleteMachine() while running = %v; want %v", err, virtualboxtest.ErrInvalidState)
+	}
+	if err := f.PauseMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vb.StartMachine(ctx, "test"); err != nil {
+		t.Fatalf("StartMachine() of paused machine = %v", err)
+	}
+	if err := f.StopMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vb.DeleteMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vb.Machine(ctx, "test"); !errors.Is(err, virtualbox.ErrMachineNotExist) {
+		t.Errorf("Machine() after delete = %v; want %v", err, virtualbox.ErrMachineNotExist)
+	}
+}
+
+func TestListMachines(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	for _, name := range []string{"b", "a", "c"} {
+		if err := f.CreateMachine(ctx, &virtualbox.Machine{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vms, err := f.ListMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, vm := range vms {
+		names = append(names, vm.Name)
+	}
+	if len(names) != 3 || names[0] != "b" || names[1] != "a" || names[2] != "c" {
+		t.Errorf("ListMachines() = %v; want [b a c]", names)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	if err := f.CreateMachine(ctx, &virtualbox.Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	boom := errors.New("boom")
+	f.FailOnce("StartMachine", boom)
+	if err := f.StartMachine(ctx, "test"); !errors.Is(err, boom) {
+		t.Errorf("StartMachine() = %v; want %v", err, boom)
+	}
+	if vm, _ := f.Machine(ctx, "test"); vm.State != virtualbox.Poweroff {
+		t.Errorf("state after the failed start = %s; want %s", vm.State, virtualbox.Poweroff)
+	}
+	if err := f.StartMachine(ctx, "test"); err != nil {
+		t.Errorf("StartMachine() after the fault = %v; want nil", err)
+	}
+
+	f.Fault = func(op, id string) error {
+		if op == "Machine" && id == "test" {
+			return boom
+		}
+		return nil
+	}
+	if _, err := f.Machine(ctx, "test"); !errors.Is(err, boom) {
+		t.Errorf("Machine() = %v; want %v", err, boom)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	vm := &virtualbox.Machine{Name: "test"}
+	if err := f.CreateMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.TakeSnapshot(ctx, "test", "clean", ""); err != nil {
+		t.Fatal(err)
+	}
+	vm.Memory = 2048
+	if err := f.ModifyMachine(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.StartMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RestoreSnapshot(ctx, "test", "clean"); !errors.Is(err, virtualboxtest.ErrInvalidState) {
+		t.Errorf("RestoreSnapshot() while running = %v; want %v", err, virtualboxtest.ErrInvalidState)
+	}
+	if err := f.StopMachine(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RestoreSnapshot(ctx, "test", "clean"); err != nil {
+		t.Fatal(err)
+	}
+	if vm, _ := f.Machine(ctx, "test"); vm.Memory != 128 {
+		t.Errorf("memory after restore = %d; want 128", vm.Memory)
+	}
+	if err := f.DeleteSnapshot(ctx, "test", "clean"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DeleteSnapshot(ctx, "test", "clean"); !errors.Is(err, virtualboxtest.ErrNotExist) {
+		t.Errorf("DeleteSnapshot() twice = %v; want %v", err, virtualboxtest.ErrNotExist)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	if err := f.CreateMachine(ctx, &virtualbox.Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	ctl := virtualbox.StorageController{SysBus: virtualbox.SysBusSATA, Ports: 1}
+	if err := f.AddStorageCtl(ctx, "test", "SATA", ctl); err != nil {
+		t.Fatal(err)
+	}
+	disk := virtualbox.StorageMedium{DriveType: virtualbox.DriveHDD, Medium: "disk.vdi"}
+	if err := f.AttachStorage(ctx, "test", "SATA", disk); err != nil {
+		t.Fatal(err)
+	}
+	disk.Port = 1
+	if err := f.AttachStorage(ctx, "test", "SATA", disk); err == nil {
+		t.Error("AttachStorage() to a port out of range succeeded")
+	}
+	media, err := f.StorageMedia(ctx, "test", "SATA")
+	if err != nil || len(media) != 1 {
+		t.Errorf("StorageMedia() = %v, %v; want one medium", media, err)
+	}
+}
+
+func TestGuestProperties(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	if err := f.CreateMachine(ctx, &virtualbox.Machine{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetGuestProperty(ctx, "test", "key", "val"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.GuestProperty(ctx, "test", "key"); err != nil || v != "val" {
+		t.Errorf("GuestProperty() = %q, %v; want val, nil", v, err)
+	}
+	if err := f.DeleteGuestProperty(ctx, "test", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.GuestProperty(ctx, "test", "key"); !errors.Is(err, virtualboxtest.ErrNotExist) {
+		t.Errorf("GuestProperty() after delete = %v; want %v", err, virtualboxtest.ErrNotExist)
+	}
+}
+
+func TestHostonlyNets(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	for i := 0; i < 2; i++ {
+		if _, err := f.CreateHostonlyNet(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.RemoveHostonlyNet(ctx, "vboxnet0"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.CreateHostonlyNet(ctx)
+	if err != nil || n.Name != "vboxnet0" {
+		t.Errorf("CreateHostonlyNet() = %+v, %v; want vboxnet0 to be reused", n, err)
+	}
+	nets, err := f.HostonlyNets(ctx)
+	if err != nil || len(nets) != 2 {
+		t.Errorf("HostonlyNets() = %v, %v; want 2 interfaces", nets, err)
+	}
+}
+
+func TestHostonlyNetworks(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	n := &virtualbox.HostonlyNetwork{Name: "HostNetwork", Netmask: net.CIDRMask(24, 32)}
+	if err := f.AddHostonlyNetwork(ctx, n); err != nil || n.GUID == "" {
+		t.Fatalf("AddHostonlyNetwork() = %v, GUID %q; want a GUID", err, n.GUID)
+	}
+	if err := f.AddHostonlyNetwork(ctx, n); !errors.Is(err, virtualbox.ErrHostonlyNetworkExist) {
+		t.Errorf("AddHostonlyNetwork() duplicate = %v; want %v", err, virtualbox.ErrHostonlyNetworkExist)
+	}
+
+	n.Enabled = true
+	n.LowerIP = net.IPv4(192, 168, 60, 10)
+	if err := f.ModifyHostonlyNetwork(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.HostonlyNetwork(ctx, "HostNetwork")
+	if err != nil || !got.Enabled || !got.LowerIP.Equal(n.LowerIP) || got.Netmask.String() != "ffffff00" {
+		t.Errorf("HostonlyNetwork() = %+v, %v; want the modified network", got, err)
+	}
+
+	if err := f.RemoveHostonlyNetwork(ctx, "HostNetwork"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.HostonlyNetwork(ctx, "HostNetwork"); !errors.Is(err, virtualbox.ErrHostonlyNetworkNotExist) {
+		t.Errorf("HostonlyNetwork() after remove = %v; want %v", err, virtualbox.ErrHostonlyNetworkNotExist)
+	}
+}
+
+func TestNATNets(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	n := virtualbox.NATNet{
+		Name: "NatNetwork",
+		IPv4: net.IPNet{IP: net.IPv4(10, 0, 2, 1), Mask: net.CIDRMask(24, 32)},
+		PortForward4: []virtualbox.PFRule{
+			{Name: "ssh", Proto: virtualbox.PFTCP, HostPort: 2222, GuestIP: net.IPv4(10, 0, 2, 15), GuestPort: 22},
+		},
+	}
+	if err := f.AddNATNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddNATNet(ctx, n); !errors.Is(err, virtualbox.ErrNATNetExist) {
+		t.Errorf("AddNATNet() duplicate = %v; want %v", err, virtualbox.ErrNATNetExist)
+	}
+
+	n.PortForward4 = nil
+	n.DHCP = true
+	if err := f.ModifyNATNet(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.NATNet(ctx, n.Name); err != nil || !got.DHCP || len(got.PortForward4) != 0 {
+		t.Errorf("NATNet() = %+v, %v; want the modified network", got, err)
+	}
+	if err := f.StartNATNet(ctx, n.Name); err != nil {
+		t.Error(err)
+	}
+
+	if err := f.RemoveNATNet(ctx, n.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.StopNATNet(ctx, n.Name); !errors.Is(err, virtualbox.ErrNATNetNotExist) {
+		t.Errorf("StopNATNet() after remove = %v; want %v", err, virtualbox.ErrNATNetNotExist)
+	}
+}
+
+func TestDHCPs(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	const network = "HostInterfaceNetworking-vboxnet0"
+	d := virtualbox.DHCP{
+		NetworkName: network,
+		IPv4:        net.IPNet{IP: net.IPv4(192, 168, 56, 100), Mask: net.CIDRMask(24, 32)},
+		Enabled:     true,
+		Options:     virtualbox.DHCPOptions{DomainName: "example.com"},
+	}
+	if err := f.AddDHCP(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddDHCP(ctx, d); !errors.Is(err, virtualbox.ErrDHCPExist) {
+		t.Errorf("AddDHCP() duplicate = %v; want %v", err, virtualbox.ErrDHCPExist)
+	}
+
+	err := f.ModifyDHCP(ctx, virtualbox.DHCP{
+		NetworkName: network,
+		Enabled:     true,
+		Options:     virtualbox.DHCPOptions{DNSServers: []net.IP{net.IPv4(8, 8, 8, 8)}},
+		Machines:    []virtualbox.DHCPMachineConfig{{MAC: "08:00:27:00:00:01", FixedAddress: net.IPv4(192, 168, 56, 50)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhcps, err := f.DHCPs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dhcps[network]
+	if got == nil || got.Options.DomainName != "example.com" || len(got.Options.DNSServers) != 1 ||
+		!got.IPv4.IP.Equal(d.IPv4.IP) || len(got.Machines) != 1 {
+		t.Errorf("DHCPs() = %+v; want the merged settings", got)
+	}
+
+	if _, err := f.FindLease(ctx, network, "080027000001"); !errors.Is(err, virtualbox.ErrLeaseNotFound) {
+		t.Errorf("FindLease() = %v; want %v", err, virtualbox.ErrLeaseNotFound)
+	}
+	f.SetLease(network, virtualbox.DHCPLease{IP: net.IPv4(192, 168, 56, 50), MAC: "08:00:27:00:00:01", State: "acked"})
+	if lease, err := f.FindLease(ctx, network, "080027000001"); err != nil || !lease.IP.Equal(net.IPv4(192, 168, 56, 50)) {
+		t.Errorf("FindLease() = %+v, %v; want 192.168.56.50", lease, err)
+	}
+
+	if err := f.RemoveDHCP(ctx, network); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RestartDHCP(ctx, network); !errors.Is(err, virtualbox.ErrDHCPNotExist) {
+		t.Errorf("RestartDHCP() after remove = %v; want %v", err, virtualbox.ErrDHCPNotExist)
+	}
+}
+
+func TestBridgedInterfaces(t *testing.T) {
+	ctx := context.Background()
+	f := virtualboxtest.New()
+	if _, err := f.DefaultBridgedInterface(ctx); !errors.Is(err, virtualbox.ErrHostInterfaceNotExist) {
+		t.Errorf("DefaultBridgedInterface() = %v; want %v", err, virtualbox.ErrHostInterfaceNotExist)
+	}
+	f.AddBridgedInterface(&virtualbox.HostInterface{Name: "eth1", Status: "Down"})
+	f.AddBridgedInterface(&virtualbox.HostInterface{Name: "eth0", Status: "Up"})
+	if hi, err := f.DefaultBridgedInterface(ctx); err != nil || hi.Name != "eth0" {
+		t.Errorf("DefaultBridgedInterface() = %+v, %v; want eth0", hi, err)
+	}
+	if _, err := f.BridgedInterface(ctx, "eth9"); !errors.Is(err, virtualbox.ErrHostInterfaceNotExist) {
+		t.Errorf("BridgedInterface() = %v; want %v", err, virtualbox.ErrHostInterfaceNotExist)
+	}
+}